@@ -0,0 +1,149 @@
+// Command all runs Service A and Service B in one process sharing a single
+// tracer provider, for local development and small deployments.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/admin"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/runner"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/servicea"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/serviceb"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/soak"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tlsconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tracing"
+)
+
+func main() {
+	// Log lines quote URLs and errors, so privacy mode scrubs them as a whole.
+	cepHasher, err := privacy.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid privacy settings: %v", err)
+	}
+	if cepHasher != nil {
+		log.SetOutput(cepHasher.Writer(os.Stderr))
+	}
+	zipkinURL := envconfig.String("OTEL_EXPORTER_ZIPKIN_ENDPOINT", "http://localhost:9411/api/v2/spans")
+	portA := envconfig.String("SERVICE_A_PORT", "8080")
+	portB := envconfig.String("SERVICE_B_PORT", "8081")
+	addrA := envconfig.String("SERVICE_A_LISTEN_ADDR", ":"+portA)
+	addrB := envconfig.String("SERVICE_B_LISTEN_ADDR", ":"+portB)
+	prefixB := strings.TrimRight(os.Getenv("SERVICE_B_PREFIX"), "/")
+	embedded := os.Getenv("SERVICE_B_EMBEDDED") == "true"
+	if embedded && prefixB != "" {
+		log.Fatalf("SERVICE_B_PREFIX cannot be combined with SERVICE_B_EMBEDDED")
+	}
+
+	fmt.Println("Starting Service A and Service B in one process...")
+	stats := shutdownreport.NewCollector()
+	svcB, err := serviceb.New(serviceb.Options{Stats: stats})
+	if err != nil {
+		log.Fatalf("Failed to start Service B: %v", err)
+	}
+	defer svcB.Close()
+
+	shutdown, err := tracing.Init("cep-weather", zipkinURL, svcB.Sampler(), stats, svcB.SpanProcessor())
+	if err != nil {
+		log.Fatalf("Failed to initialize tracer: %v", err)
+	}
+
+	// Embedded, Service A calls Service B's handler directly, and with
+	// Service B on a Unix socket it dials that; the URL only names it in
+	// spans and logs.
+	optsA := servicea.Options{ServiceBURL: "http://localhost:" + portB, Stats: stats}
+	socketA, onSocketA := strings.CutPrefix(addrA, "unix:")
+	socketB, onSocketB := strings.CutPrefix(addrB, "unix:")
+	switch {
+	case embedded:
+		optsA.ServiceBURL, optsA.ServiceB = "http://service-b", svcB.Handler()
+	case prefixB != "" && onSocketA:
+		optsA.ServiceBURL, optsA.ServiceBSocket = "http://service-a"+prefixB, socketA
+	case prefixB != "":
+		optsA.ServiceBURL = "http://localhost:" + portA + prefixB
+	case onSocketB:
+		optsA.ServiceBURL, optsA.ServiceBSocket = "http://service-b", socketB
+	}
+	svcA, err := servicea.New(optsA)
+	if err != nil {
+		log.Fatalf("Failed to start Service A: %v", err)
+	}
+	defer svcA.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	svcB.Start(ctx)
+	soak.FromEnv("cep-weather").Start(ctx)
+
+	// TLS only covers the listeners clients reach. Service A keeps talking to
+	// Service B over plain HTTP on localhost, which is why TLS cannot be
+	// combined with SERVICE_B_PREFIX.
+	tlsConfig, err := tlsconfig.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	if tlsConfig != nil && prefixB != "" {
+		log.Fatalf("SERVICE_B_PREFIX cannot be combined with TLS")
+	}
+
+	// With SERVICE_B_PREFIX set, Service B is mounted under that prefix on
+	// Service A's port instead of listening on its own; embedded, it does
+	// not listen at all.
+	limits := runner.LimitsFromEnv()
+	var listeners []runner.Listener
+	switch {
+	case embedded:
+		listeners = append(listeners, runner.Listener{Name: "Service A (Service B embedded)", Addr: addrA, Handler: svcA.Handler(), TLS: tlsConfig, OnShutdown: svcA.CloseStreams, Limits: limits})
+	case prefixB != "":
+		mux := http.NewServeMux()
+		mux.Handle("/", svcA.Handler())
+		mux.Handle(prefixB+"/", http.StripPrefix(prefixB, svcB.Handler()))
+		listeners = append(listeners, runner.Listener{Name: "Service A and Service B (under " + prefixB + ")", Addr: addrA, Handler: mux, OnShutdown: svcA.CloseStreams, Limits: limits})
+	default:
+		listeners = append(listeners,
+			runner.Listener{Name: "Service A", Addr: addrA, Handler: svcA.Handler(), TLS: tlsConfig, OnShutdown: svcA.CloseStreams, Limits: limits},
+			runner.Listener{Name: "Service B", Addr: addrB, Handler: svcB.Handler(), Limits: limits, H2C: os.Getenv("HTTP2_CLEARTEXT") == "true"},
+		)
+	}
+	// Service B's admin surface is a superset of Service A's.
+	if adminPort := os.Getenv("ADMIN_PORT"); adminPort != "" {
+		listeners = append(listeners, runner.Listener{Name: "Admin endpoints", Addr: ":" + adminPort, Handler: svcB.AdminHandler(), TLS: tlsConfig, Limits: limits})
+	}
+	if debugPort := os.Getenv("DEBUG_PORT"); debugPort != "" {
+		listeners = append(listeners, runner.Listener{Name: "pprof/expvar endpoints", Addr: ":" + debugPort, Handler: admin.DebugMux()})
+	}
+
+	if embedded {
+		fmt.Printf("Service A calling Service B in-process, exporting traces to %s\n", zipkinURL)
+	} else {
+		fmt.Printf("Service A forwarding to Service B at %s, exporting traces to %s\n", optsA.ServiceBURL, zipkinURL)
+	}
+	shutdownTimeout := envconfig.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	runner.Run(ctx, shutdownTimeout, listeners...)
+	fmt.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shutdown tracer: %v", err)
+	}
+
+	report := stats.Report("cep-weather")
+	report.Cache = svcB.CacheStats()
+	report.Log()
+	if webhook := os.Getenv("SHUTDOWN_REPORT_WEBHOOK"); webhook != "" {
+		if err := report.Send(shutdownCtx, svcB.Client(), webhook); err != nil {
+			log.Printf("Failed to send shutdown report: %v", err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type WeatherResponse struct {
+	City  string  `json:"city"`
+	TempC float64 `json:"temp_C"`
+	TempF float64 `json:"temp_F"`
+	TempK float64 `json:"temp_K"`
+}
+
+type options struct {
+	serviceURL string
+	zipkinURL  string
+	units      []string
+	jsonOutput bool
+	file       string
+	timeout    time.Duration
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	opts := options{
+		serviceURL: envOr("CEPWEATHER_URL", "http://localhost:8080"),
+		zipkinURL:  envOr("OTEL_EXPORTER_ZIPKIN_ENDPOINT", "http://localhost:9411/api/v2/spans"),
+	}
+
+	cmd := &cobra.Command{
+		Use:           "cepweather [cep...]",
+		Short:         "Query the current weather for one or more CEPs through Service A",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), opts, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.serviceURL, "url", opts.serviceURL, "Service A base URL (env CEPWEATHER_URL)")
+	flags.StringVar(&opts.zipkinURL, "zipkin", opts.zipkinURL, "Zipkin endpoint for the CLI span, empty to disable (env OTEL_EXPORTER_ZIPKIN_ENDPOINT)")
+	flags.StringSliceVar(&opts.units, "units", []string{"C", "F", "K"}, "temperature units to print (C, F, K)")
+	flags.BoolVar(&opts.jsonOutput, "json", false, "print the raw JSON response of each lookup")
+	flags.StringVarP(&opts.file, "file", "f", "", "read CEPs from a file, one per line (\"-\" for stdin)")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout for each lookup")
+
+	return cmd
+}
+
+func run(ctx context.Context, stdout, stderr io.Writer, opts options, args []string) error {
+	for _, unit := range opts.units {
+		switch strings.ToUpper(unit) {
+		case "C", "F", "K":
+		default:
+			return fmt.Errorf("unknown unit %q", unit)
+		}
+	}
+
+	ceps := append([]string{}, args...)
+	if opts.file != "" {
+		fromFile, err := readCEPs(opts.file)
+		if err != nil {
+			return err
+		}
+		ceps = append(ceps, fromFile...)
+	}
+	if len(ceps) == 0 {
+		return fmt.Errorf("no CEP given")
+	}
+
+	if opts.zipkinURL != "" {
+		shutdown, err := initTracer("cepweather-cli", opts.zipkinURL)
+		if err != nil {
+			return err
+		}
+		defer shutdown(context.Background())
+	}
+
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: opts.timeout}
+
+	failed := 0
+	for _, cep := range ceps {
+		body, status, err := lookup(ctx, client, opts.serviceURL, cep)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", cep, err)
+			failed++
+			continue
+		}
+		if status != http.StatusOK {
+			fmt.Fprintf(stderr, "%s: %d %s\n", cep, status, strings.TrimSpace(string(body)))
+			failed++
+			continue
+		}
+		if opts.jsonOutput {
+			fmt.Fprintln(stdout, strings.TrimSpace(string(body)))
+			continue
+		}
+		var weather WeatherResponse
+		if err := json.Unmarshal(body, &weather); err != nil {
+			fmt.Fprintf(stderr, "%s: malformed response: %v\n", cep, err)
+			failed++
+			continue
+		}
+		printWeather(stdout, cep, weather, opts.units)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d lookups failed", failed, len(ceps))
+	}
+	return nil
+}
+
+func lookup(ctx context.Context, client *http.Client, serviceURL, cep string) ([]byte, int, error) {
+	tracer := otel.Tracer("cepweather-cli")
+	ctx, span := tracer.Start(ctx, "cepweather-lookup",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("cep.input", cep)),
+	)
+	defer span.End()
+
+	payload, err := json.Marshal(map[string]string{"cep": cep})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(serviceURL, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create request")
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to reach Service A")
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to read response")
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return body, resp.StatusCode, nil
+}
+
+func printWeather(w io.Writer, cep string, weather WeatherResponse, units []string) {
+	fmt.Fprintf(w, "%s (%s)\n", weather.City, cep)
+	for _, unit := range units {
+		switch strings.ToUpper(unit) {
+		case "C":
+			fmt.Fprintf(w, "  %.1f °C\n", weather.TempC)
+		case "F":
+			fmt.Fprintf(w, "  %.1f °F\n", weather.TempF)
+		case "K":
+			fmt.Fprintf(w, "  %.1f K\n", weather.TempK)
+		}
+	}
+}
+
+func readCEPs(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var ceps []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ceps = append(ceps, line)
+	}
+	return ceps, scanner.Err()
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
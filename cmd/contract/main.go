@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/contract"
+	"github.com/spf13/cobra"
+)
+
+type options struct {
+	serviceB   string
+	knownCEP   string
+	unknownCEP string
+	timeout    time.Duration
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	opts := options{serviceB: os.Getenv("SERVICE_B_URL")}
+	if opts.serviceB == "" {
+		opts.serviceB = "http://localhost:8081"
+	}
+
+	cmd := &cobra.Command{
+		Use:           "contract",
+		Short:         "Verify that a running Service B honours the contract Service A relies on",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.serviceB, "service-b", opts.serviceB, "base URL of the Service B to verify (env SERVICE_B_URL)")
+	flags.StringVar(&opts.knownCEP, "known-cep", contract.KnownCEP, "CEP that must resolve to a city with weather")
+	flags.StringVar(&opts.unknownCEP, "unknown-cep", contract.UnknownCEP, "CEP that must not exist")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout for each request")
+
+	return cmd
+}
+
+func run(ctx context.Context, out io.Writer, opts options) error {
+	client := &http.Client{Timeout: opts.timeout}
+	base := strings.TrimRight(opts.serviceB, "/")
+
+	interactions := contract.Interactions(opts.knownCEP, opts.unknownCEP)
+	failed := 0
+	for _, in := range interactions {
+		errs := verify(ctx, client, base, in)
+		if len(errs) == 0 {
+			fmt.Fprintf(out, "PASS  %s\n", in.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL  %s (%s %s)\n", in.Name, in.Method, in.Path)
+		for _, err := range errs {
+			fmt.Fprintf(out, "      %v\n", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d interactions broke the contract", failed, len(interactions))
+	}
+	return nil
+}
+
+func verify(ctx context.Context, client *http.Client, base string, in contract.Interaction) []error {
+	req, err := http.NewRequestWithContext(ctx, in.Method, base+in.Path, nil)
+	if err != nil {
+		return []error{err}
+	}
+	req.Header = in.Header.Clone()
+	resp, err := client.Do(req)
+	if err != nil {
+		return []error{err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return []error{err}
+	}
+	return in.Check(resp.StatusCode, resp.Header, body)
+}
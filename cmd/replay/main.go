@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/journal"
+	"github.com/spf13/cobra"
+)
+
+type options struct {
+	target  string
+	from    string
+	to      string
+	route   string
+	limit   int
+	delay   time.Duration
+	timeout time.Duration
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	opts := options{target: os.Getenv("REPLAY_TARGET")}
+	if opts.target == "" {
+		opts.target = "http://localhost:8080"
+	}
+
+	cmd := &cobra.Command{
+		Use:           "replay journal-file...",
+		Short:         "Re-issue requests recorded in Service A's request journal against a target environment",
+		Args:          cobra.MinimumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), cmd.OutOrStdout(), opts, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.target, "target", opts.target, "base URL of the Service A to replay against (env REPLAY_TARGET)")
+	flags.StringVar(&opts.from, "from", "", "only replay records at or after this RFC 3339 time")
+	flags.StringVar(&opts.to, "to", "", "only replay records before this RFC 3339 time")
+	flags.StringVar(&opts.route, "route", "", "only replay records for this route pattern, e.g. \"GET /weather/{cep}\"")
+	flags.IntVar(&opts.limit, "limit", 0, "replay at most this many records (0 for all)")
+	flags.DurationVar(&opts.delay, "delay", 0, "pause between requests")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout for each request")
+
+	return cmd
+}
+
+type window struct {
+	from, to time.Time
+}
+
+func parseWindow(from, to string) (window, error) {
+	var w window
+	var err error
+	if from != "" {
+		if w.from, err = time.Parse(time.RFC3339, from); err != nil {
+			return w, fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if to != "" {
+		if w.to, err = time.Parse(time.RFC3339, to); err != nil {
+			return w, fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+	return w, nil
+}
+
+func (w window) contains(t time.Time) bool {
+	return (w.from.IsZero() || !t.Before(w.from)) && (w.to.IsZero() || t.Before(w.to))
+}
+
+func run(ctx context.Context, stdout io.Writer, opts options, files []string) error {
+	win, err := parseWindow(opts.from, opts.to)
+	if err != nil {
+		return err
+	}
+
+	var records []journal.Record
+	for _, file := range files {
+		recs, err := journal.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		records = append(records, recs...)
+	}
+
+	client := &http.Client{Timeout: opts.timeout}
+	target := strings.TrimRight(opts.target, "/")
+	var replayed, matched, skipped, failed int
+	for _, rec := range records {
+		if !win.contains(rec.Time) || (opts.route != "" && rec.Route != opts.route) {
+			continue
+		}
+		if opts.limit > 0 && replayed+skipped >= opts.limit {
+			break
+		}
+
+		req, err := buildRequest(ctx, target, rec)
+		if err != nil {
+			skipped++
+			fmt.Fprintf(stdout, "SKIP  %s %s: %v\n", rec.Route, rec.CEP, err)
+			continue
+		}
+		if replayed > 0 && opts.delay > 0 {
+			time.Sleep(opts.delay)
+		}
+		replayed++
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			failed++
+			fmt.Fprintf(stdout, "ERROR %s %s: %v\n", rec.Route, rec.CEP, err)
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		verdict := "OK   "
+		if resp.StatusCode == rec.Status {
+			matched++
+		} else {
+			verdict = "DIFF "
+		}
+		fmt.Fprintf(stdout, "%s %s %s: recorded %d, got %d in %s (original trace %s)\n",
+			verdict, rec.Route, rec.CEP, rec.Status, resp.StatusCode, time.Since(start).Round(time.Millisecond), rec.TraceID)
+	}
+
+	fmt.Fprintf(stdout, "\nreplayed %d, matching status %d, differing %d, errors %d, skipped %d\n",
+		replayed, matched, replayed-matched-failed, failed, skipped)
+	return nil
+}
+
+// buildRequest recreates the request a journal record describes. Records
+// without a CEP, such as lookups rejected as invalid, cannot be replayed
+// because the raw input was never journaled.
+func buildRequest(ctx context.Context, target string, rec journal.Record) (*http.Request, error) {
+	if rec.CEP == "" {
+		return nil, fmt.Errorf("no CEP recorded")
+	}
+
+	var req *http.Request
+	var err error
+	switch rec.Route {
+	case "/", "POST /weather/async":
+		path := "/"
+		if rec.Route != "/" {
+			path = "/weather/async"
+		}
+		body, _ := json.Marshal(map[string]string{"cep": rec.CEP})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, target+path, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	case "GET /weather":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, target+"/weather?cep="+url.QueryEscape(rec.CEP), nil)
+	case "GET /weather/{cep}":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, target+"/weather/"+url.PathEscape(rec.CEP), nil)
+	default:
+		return nil, fmt.Errorf("route %q is not replayable", rec.Route)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rec.TraceID != "" {
+		req.Header.Set("X-Replay-Of", rec.TraceID)
+	}
+	return req, nil
+}
@@ -0,0 +1,89 @@
+// Package admin holds the handlers served on the services' admin port, which
+// is kept off the public listener.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+type Module struct {
+	Path    string  `json:"path"`
+	Version string  `json:"version"`
+	Sum     string  `json:"sum,omitempty"`
+	Replace *Module `json:"replace,omitempty"`
+}
+
+type BuildInfo struct {
+	GoVersion    string            `json:"go_version"`
+	Path         string            `json:"path"`
+	Main         Module            `json:"main"`
+	Dependencies []Module          `json:"dependencies"`
+	Settings     map[string]string `json:"settings"`
+}
+
+func toModule(m *debug.Module) Module {
+	mod := Module{Path: m.Path, Version: m.Version, Sum: m.Sum}
+	if m.Replace != nil {
+		replace := toModule(m.Replace)
+		mod.Replace = &replace
+	}
+	return mod
+}
+
+// BuildInfoHandler serves the module versions and VCS metadata compiled into
+// the running binary.
+func BuildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info not available", http.StatusNotFound)
+		return
+	}
+
+	resp := BuildInfo{
+		GoVersion:    info.GoVersion,
+		Path:         info.Path,
+		Main:         toModule(&info.Main),
+		Dependencies: make([]Module, 0, len(info.Deps)),
+		Settings:     make(map[string]string, len(info.Settings)),
+	}
+	for _, dep := range info.Deps {
+		resp.Dependencies = append(resp.Dependencies, toModule(dep))
+	}
+	for _, setting := range info.Settings {
+		resp.Settings[setting.Key] = setting.Value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// RequireToken guards admin routes with a bearer token. Without a configured
+// token, admin routes are read-only: GET and HEAD pass and anything else is
+// refused.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "Forbidden: ADMIN_TOKEN is not configured", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,165 @@
+// Package apiversion negotiates the version of the response shape, from a
+// /vN path prefix or an Accept: application/vnd.cepweather.vN+json header.
+// Requests naming no version get Default, the shape existing integrations
+// were built against. Version 2 names every field in snake_case, as in
+// temp_c where version 1 has temp_C.
+package apiversion
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+)
+
+const (
+	Default = 1
+	Latest  = 2
+)
+
+// Supported lists the versions served, oldest first.
+var Supported = []int{1, 2}
+
+// MediaType is the vendor media type of version v.
+func MediaType(v int) string { return "application/vnd.cepweather.v" + strconv.Itoa(v) + "+json" }
+
+type contextKey struct{}
+
+// FromContext returns the version negotiated for the request, and whether
+// the client asked for it rather than getting Default.
+func FromContext(ctx context.Context) (v int, explicit bool) {
+	if v, ok := ctx.Value(contextKey{}).(int); ok {
+		return v, true
+	}
+	return Default, false
+}
+
+// NewContext returns ctx carrying version v, as if the client had asked for
+// it. Work done later on behalf of a request, such as a replay, uses it to
+// keep the request's version.
+func NewContext(ctx context.Context, v int) context.Context {
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// Middleware strips a /vN prefix from the path before next routes the
+// request, and records the version picked by the prefix or the Accept
+// header. Unknown path versions are answered with 404 and Accept headers
+// naming no supported version with 406. Responses to clients that asked for
+// a version carry its vendor media type instead of application/json.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, fromPath := 0, false
+		if segment, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/"); ok {
+			if v, ok := parseSegment(segment); ok {
+				if !slices.Contains(Supported, v) {
+					i18n.Error(w, r, http.StatusNotFound, "Not Found: unknown API version %d (available: %v)", v, Supported)
+					return
+				}
+				version, fromPath = v, true
+				r = stripPrefix(r, "/"+segment, "/"+rest)
+			}
+		}
+
+		if accepted := acceptedVersions(r.Header.Get("Accept")); len(accepted) > 0 {
+			switch {
+			case fromPath && !slices.Contains(accepted, version):
+				w.Header().Add("Vary", "Accept")
+				i18n.Error(w, r, http.StatusNotAcceptable, "Not Acceptable: %s is served as %s", r.URL.Path, MediaType(version))
+				return
+			case !fromPath:
+				for _, v := range accepted {
+					if slices.Contains(Supported, v) {
+						version = max(version, v)
+					}
+				}
+				if version == 0 {
+					w.Header().Add("Vary", "Accept")
+					i18n.Error(w, r, http.StatusNotAcceptable, "Not Acceptable: unsupported API version (available: %v)", Supported)
+					return
+				}
+			}
+		}
+
+		vw := &versionedWriter{ResponseWriter: w}
+		if version != 0 {
+			vw.mediaType = MediaType(version)
+			r = r.WithContext(NewContext(r.Context(), version))
+		}
+		next.ServeHTTP(vw, r)
+	})
+}
+
+func parseSegment(segment string) (int, bool) {
+	digits, ok := strings.CutPrefix(segment, "v")
+	if !ok || digits == "" || digits[0] == '0' {
+		return 0, false
+	}
+	v, err := strconv.Atoi(digits)
+	return v, err == nil
+}
+
+// acceptedVersions lists the versions named by vendor media types in an
+// Accept header, ignoring those excluded with q=0.
+func acceptedVersions(header string) []int {
+	var versions []int
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		digits, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(mediaType)), "application/vnd.cepweather.v")
+		if !ok {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil && parsed == 0 {
+				continue
+			}
+		}
+		if v, err := strconv.Atoi(strings.TrimSuffix(digits, "+json")); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+func stripPrefix(r *http.Request, prefix, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+	return r2
+}
+
+// versionedWriter adds Vary: Accept, unless a proxied response already
+// carries it, and labels JSON responses with the negotiated media type.
+type versionedWriter struct {
+	http.ResponseWriter
+	mediaType   string
+	wroteHeader bool
+}
+
+func (vw *versionedWriter) WriteHeader(status int) {
+	if !vw.wroteHeader {
+		vw.wroteHeader = true
+		if !slices.Contains(vw.Header().Values("Vary"), "Accept") {
+			vw.Header().Add("Vary", "Accept")
+		}
+		if vw.mediaType != "" && vw.Header().Get("Content-Type") == "application/json" {
+			vw.Header().Set("Content-Type", vw.mediaType)
+		}
+	}
+	vw.ResponseWriter.WriteHeader(status)
+}
+
+func (vw *versionedWriter) Write(p []byte) (int, error) {
+	if !vw.wroteHeader {
+		vw.WriteHeader(http.StatusOK)
+	}
+	return vw.ResponseWriter.Write(p)
+}
+
+func (vw *versionedWriter) Unwrap() http.ResponseWriter { return vw.ResponseWriter }
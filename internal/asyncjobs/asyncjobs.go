@@ -0,0 +1,187 @@
+// Package asyncjobs carries asynchronous weather lookups between Service A,
+// which enqueues them, and Service B, which resolves them. Trace context
+// travels in the message headers so each job joins the enqueuing trace.
+package asyncjobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+)
+
+// LookupRequest is the message enqueued for every asynchronous lookup.
+type LookupRequest struct {
+	JobID       string    `json:"job_id"`
+	CEP         string    `json:"cep"`
+	CallbackURL string    `json:"callback_url,omitempty"`
+	EnqueuedAt  time.Time `json:"enqueued_at"`
+}
+
+// Job is the state of a lookup as returned by GET /weather/jobs/{id}.
+type Job struct {
+	ID         string          `json:"job_id"`
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+func NewJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("asyncjobs: reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+type Publisher struct {
+	writer *kafka.Writer
+}
+
+func NewPublisher(brokers []string, topic string) *Publisher {
+	return &Publisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+func (p *Publisher) Publish(ctx context.Context, req LookupRequest) error {
+	tracer := otel.Tracer("asyncjobs")
+	ctx, span := tracer.Start(ctx, "publish-lookup-job",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.writer.Topic),
+			attribute.String("job.id", req.JobID),
+		),
+	)
+	defer span.End()
+
+	value, err := json.Marshal(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to encode job")
+		return fmt.Errorf("error encoding job: %w", err)
+	}
+
+	msg := kafka.Message{Key: []byte(req.JobID), Value: value}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &msg.Headers})
+
+	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish job")
+		return fmt.Errorf("error publishing job: %w", err)
+	}
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
+
+type Consumer struct {
+	reader *kafka.Reader
+}
+
+func NewConsumer(brokers []string, topic, group string) *Consumer {
+	return &Consumer{reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   topic,
+		GroupID: group,
+	})}
+}
+
+// Run consumes lookup requests until ctx is cancelled. Each message is
+// committed after handle returns, so a crash re-delivers unfinished jobs.
+func (c *Consumer) Run(ctx context.Context, handle func(context.Context, LookupRequest)) error {
+	tracer := otel.Tracer("asyncjobs")
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error fetching job: %w", err)
+		}
+
+		msgCtx := otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &msg.Headers})
+		msgCtx, span := tracer.Start(msgCtx, "consume-lookup-job",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "kafka"),
+				attribute.String("messaging.destination.name", msg.Topic),
+				attribute.Int64("messaging.kafka.offset", msg.Offset),
+			),
+		)
+
+		var req LookupRequest
+		if err := json.Unmarshal(msg.Value, &req); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "malformed job message")
+		} else {
+			span.SetAttributes(attribute.String("job.id", req.JobID))
+			handle(msgCtx, req)
+		}
+		span.End()
+
+		if err := c.reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("error committing job: %w", err)
+		}
+	}
+}
+
+func (c *Consumer) Close() error {
+	return c.reader.Close()
+}
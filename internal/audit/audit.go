@@ -0,0 +1,103 @@
+// Package audit writes an append-only JSON lines record of every weather
+// lookup: who asked, for which CEP, how it ended and how long it took.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/rotatefile"
+)
+
+// Outcomes of a lookup.
+const (
+	OutcomeOK       = "ok"
+	OutcomeNotFound = "not_found"
+	OutcomeInvalid  = "invalid"
+	OutcomeError    = "error"
+)
+
+type Record struct {
+	Time time.Time `json:"time"`
+	// Caller is the client.id baggage set by Service A: a hash of the API
+	// key or the client IP.
+	Caller  string `json:"caller,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+	EndUser string `json:"enduser,omitempty"`
+	CEP     string `json:"cep,omitempty"`
+	// CEPHash replaces CEP when the logger hashes CEPs.
+	CEPHash   string  `json:"cep_hash,omitempty"`
+	Outcome   string  `json:"outcome"`
+	Status    int     `json:"status"`
+	Cache     string  `json:"cache,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+	RequestID string  `json:"request_id,omitempty"`
+	TraceID   string  `json:"trace_id,omitempty"`
+}
+
+// OutcomeFor maps the HTTP status of a lookup to its outcome.
+func OutcomeFor(status int) string {
+	switch {
+	case status < 300:
+		return OutcomeOK
+	case status == 404:
+		return OutcomeNotFound
+	case status == 400 || status == 422:
+		return OutcomeInvalid
+	default:
+		return OutcomeError
+	}
+}
+
+type Logger struct {
+	hasher *privacy.Hasher
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// Open logs to stdout when dest is "stdout" and otherwise to the file dest,
+// rotated like the request journal. A non-nil hasher makes records carry
+// the CEP's keyed hash instead of the CEP itself, so one CEP's lookups can
+// still be correlated.
+func Open(dest string, maxBytes int64, maxFiles int, hasher *privacy.Hasher) (*Logger, error) {
+	l := &Logger{hasher: hasher}
+	if dest == "stdout" {
+		l.out = os.Stdout
+		return l, nil
+	}
+	out, err := rotatefile.Open(dest, maxBytes, maxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %w", err)
+	}
+	l.out = out
+	return l, nil
+}
+
+func (l *Logger) Log(rec Record) error {
+	if l.hasher != nil && rec.CEP != "" {
+		rec.CEP, rec.CEPHash = "", l.hasher.Hash(rec.CEP)
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error encoding audit record: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return nil
+}
+
+func (l *Logger) Close() error {
+	if closer, ok := l.out.(io.Closer); ok && l.out != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
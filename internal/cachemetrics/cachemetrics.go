@@ -0,0 +1,113 @@
+// Package cachemetrics records the same OpenTelemetry instruments for every
+// cache implementation, so backends can be compared side by side. Every
+// measurement carries cache.name and cache.backend attributes.
+package cachemetrics
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const (
+	OperationGet    = "get"
+	OperationSet    = "set"
+	OperationDelete = "delete"
+)
+
+type Metrics struct {
+	attrs []attribute.KeyValue
+
+	duration  metric.Float64Histogram
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+
+	// hitCount and missCount feed the cache.hit_ratio gauge.
+	hitCount, missCount atomic.Int64
+}
+
+// New creates the cache instruments on meter. A nil meter disables them.
+func New(meter metric.Meter, name, backend string) *Metrics {
+	if meter == nil {
+		meter = noop.NewMeterProvider().Meter("cachemetrics")
+	}
+	m := &Metrics{attrs: []attribute.KeyValue{
+		attribute.String("cache.name", name),
+		attribute.String("cache.backend", backend),
+	}}
+
+	var err error
+	if m.duration, err = meter.Float64Histogram("cache.operation.duration",
+		metric.WithDescription("Duration of cache operations"),
+		metric.WithUnit("s"),
+	); err != nil {
+		log.Printf("Failed to create cache duration histogram: %v\n", err)
+	}
+	if m.hits, err = meter.Int64Counter("cache.hits",
+		metric.WithDescription("Cache lookups served from the cache"),
+	); err != nil {
+		log.Printf("Failed to create cache hits counter: %v\n", err)
+	}
+	if m.misses, err = meter.Int64Counter("cache.misses",
+		metric.WithDescription("Cache lookups not found in the cache"),
+	); err != nil {
+		log.Printf("Failed to create cache misses counter: %v\n", err)
+	}
+	if m.evictions, err = meter.Int64Counter("cache.evictions",
+		metric.WithDescription("Entries removed from the cache because they expired"),
+	); err != nil {
+		log.Printf("Failed to create cache evictions counter: %v\n", err)
+	}
+	if _, err = meter.Float64ObservableGauge("cache.hit_ratio",
+		metric.WithDescription("Share of cache lookups served from the cache since the process started"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			hits, misses := m.hitCount.Load(), m.missCount.Load()
+			if hits+misses > 0 {
+				o.Observe(float64(hits)/float64(hits+misses), m.with())
+			}
+			return nil
+		}),
+	); err != nil {
+		log.Printf("Failed to create cache hit ratio gauge: %v\n", err)
+	}
+	return m
+}
+
+func (m *Metrics) with(extra ...attribute.KeyValue) metric.MeasurementOption {
+	return metric.WithAttributes(append(extra, m.attrs...)...)
+}
+
+// Operation records how long op took since start.
+func (m *Metrics) Operation(ctx context.Context, op string, start time.Time) {
+	if m.duration != nil {
+		m.duration.Record(ctx, time.Since(start).Seconds(), m.with(attribute.String("cache.operation", op)))
+	}
+}
+
+// Hit records a lookup served from the cache; stale marks values served
+// past their TTL.
+func (m *Metrics) Hit(ctx context.Context, stale bool) {
+	m.hitCount.Add(1)
+	if m.hits != nil {
+		m.hits.Add(ctx, 1, m.with(attribute.Bool("cache.stale", stale)))
+	}
+}
+
+func (m *Metrics) Miss(ctx context.Context) {
+	m.missCount.Add(1)
+	if m.misses != nil {
+		m.misses.Add(ctx, 1, m.with())
+	}
+}
+
+func (m *Metrics) Evicted(ctx context.Context, n int) {
+	if m.evictions != nil && n > 0 {
+		m.evictions.Add(ctx, int64(n), m.with())
+	}
+}
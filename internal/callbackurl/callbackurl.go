@@ -0,0 +1,109 @@
+// Package callbackurl checks the callback URLs clients hand to the services
+// and guards the connections made to them, so a callback cannot be aimed at
+// the services' own network.
+package callbackurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a callback host resolves to an
+// address that is not publicly routable.
+var ErrForbiddenAddress = errors.New("callback address is not publicly routable")
+
+// Valid reports whether raw is an absolute http(s) URL. Where it points is
+// checked by Guard when the callback is delivered.
+func Valid(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// reserved lists the non-public ranges netip.Addr has no predicate for.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// Public reports whether ip is publicly routable: not loopback, private,
+// link-local, multicast, unspecified or otherwise reserved.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Guard dials callback hosts, refusing addresses that are not public. The
+// check runs on every address the host resolves to, right before
+// connecting, so a name cannot be re-pointed between check and use.
+type Guard struct {
+	dialer  net.Dialer
+	allowed map[string]bool
+}
+
+// NewGuard returns a Guard that lets the hosts in allowedHosts, such as a
+// receiver inside the cluster, resolve to any address.
+func NewGuard(allowedHosts []string) *Guard {
+	g := &Guard{
+		dialer:  net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: controlPublic},
+		allowed: make(map[string]bool),
+	}
+	for _, host := range allowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			g.allowed[host] = true
+		}
+	}
+	return g
+}
+
+func (g *Guard) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil && g.allowed[strings.ToLower(host)] {
+		dialer := g.dialer
+		dialer.Control = nil
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return g.dialer.DialContext(ctx, network, addr)
+}
+
+// Transport returns a transport dialing through g. It ignores proxy
+// settings, which would otherwise have the guard check the proxy.
+func (g *Guard) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = g.DialContext
+	return transport
+}
+
+func controlPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package callbackurl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+	}
+	for _, tt := range tests {
+		if got := Public(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Public(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"https://example.com/hook": true,
+		"http://example.com:8080":  true,
+		"ftp://example.com":        false,
+		"/hook":                    false,
+		"https://":                 false,
+	} {
+		if got := Valid(raw); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestGuardRefusesNonPublicAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	u, _ := url.Parse(receiver.URL)
+
+	client := &http.Client{Transport: NewGuard(nil).Transport()}
+	// localhost resolves to loopback, so the check must run after resolution.
+	for _, target := range []string{receiver.URL, "http://localhost:" + u.Port()} {
+		_, err := client.Post(target, "application/json", nil)
+		if !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("POST %s error = %v, want ErrForbiddenAddress", target, err)
+		}
+	}
+
+	client = &http.Client{Transport: NewGuard([]string{"127.0.0.1"}).Transport()}
+	resp, err := client.Post(receiver.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST to an allowed host: %v", err)
+	}
+	resp.Body.Close()
+}
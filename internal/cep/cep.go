@@ -0,0 +1,46 @@
+// Package cep validates and normalizes Brazilian postal codes (CEPs) so both
+// services agree on what a valid CEP is and how it is written.
+package cep
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid zipcode")
+
+// Normalize accepts a CEP written as "01310100", "01310-100" or
+// "01.310-100" and returns its canonical 8-digit form.
+func Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-' && i == len(raw)-4:
+		case r == '.' && i == len(raw)-8:
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	digits := b.String()
+	if len(digits) != 8 {
+		return "", ErrInvalid
+	}
+	// No CEP is issued below 01000-000, which also rules out 00000-000.
+	if strings.HasPrefix(digits, "00") {
+		return "", ErrInvalid
+	}
+	return digits, nil
+}
+
+// Format renders a canonical CEP as "01310-100".
+func Format(cep string) string {
+	if len(cep) != 8 {
+		return cep
+	}
+	return cep[:5] + "-" + cep[5:]
+}
@@ -0,0 +1,76 @@
+package cep
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Policy decides which spellings of a CEP a service accepts. All of them
+// normalize to the canonical 8-digit form.
+type Policy struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var (
+	// Permissive accepts the separators Normalize does.
+	Permissive = Policy{name: "permissive"}
+	// Strict accepts exactly 8 digits.
+	Strict = Policy{name: "strict"}
+)
+
+// ParsePolicy reads "strict", "permissive" or "regex:<expr>". A CEP must
+// match the whole of a custom expression; its digits are then taken as the
+// CEP. An empty spec is Permissive.
+func ParsePolicy(spec string) (Policy, error) {
+	switch spec = strings.TrimSpace(spec); spec {
+	case "", Permissive.name:
+		return Permissive, nil
+	case Strict.name:
+		return Strict, nil
+	}
+	expr, ok := strings.CutPrefix(spec, "regex:")
+	if !ok {
+		return Policy{}, fmt.Errorf("unknown CEP policy %q (available: strict, permissive, regex:<expr>)", spec)
+	}
+	pattern, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return Policy{}, fmt.Errorf("invalid CEP policy regex: %w", err)
+	}
+	return Policy{name: "regex", pattern: pattern}, nil
+}
+
+// String names the policy: strict, permissive or regex.
+func (p Policy) String() string {
+	if p.name == "" {
+		return Permissive.name
+	}
+	return p.name
+}
+
+// Normalize checks raw against the policy and returns its canonical
+// 8-digit form.
+func (p Policy) Normalize(raw string) (string, error) {
+	switch p.name {
+	case Strict.name:
+		raw = strings.TrimSpace(raw)
+		if len(raw) != 8 || strings.Trim(raw, "0123456789") != "" {
+			return "", ErrInvalid
+		}
+		return Normalize(raw)
+	case "regex":
+		raw = strings.TrimSpace(raw)
+		if !p.pattern.MatchString(raw) {
+			return "", ErrInvalid
+		}
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, raw)
+		return Normalize(digits)
+	}
+	return Normalize(raw)
+}
@@ -0,0 +1,294 @@
+// Package cepdb keeps the addresses CEPs resolved to in a SQLite database,
+// so a service can answer known CEPs without its CEP provider, across
+// restarts. Snapshots are exported and imported as JSON lines, one Record
+// per line.
+package cepdb
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS ceps (
+	cep        TEXT PRIMARY KEY,
+	city       TEXT    NOT NULL,
+	uf         TEXT    NOT NULL,
+	ibge_code  TEXT    NOT NULL,
+	ddd        TEXT    NOT NULL,
+	siafi      TEXT    NOT NULL,
+	latitude   REAL,
+	longitude  REAL,
+	updated_at INTEGER NOT NULL
+);
+`
+
+// Record is what a CEP resolved to.
+type Record struct {
+	CEP       string    `json:"cep"`
+	City      string    `json:"city"`
+	UF        string    `json:"uf,omitempty"`
+	IBGECode  string    `json:"ibge_code,omitempty"`
+	DDD       string    `json:"ddd,omitempty"`
+	SIAFI     string    `json:"siafi,omitempty"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens (creating if needed) the CEP database at path. The path
+// ":memory:" gives a database that lasts as long as the DB.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening CEP database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating CEP database schema: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Get returns the record of cep, reporting whether there is one.
+func (d *DB) Get(ctx context.Context, cep string) (Record, bool, error) {
+	var (
+		record    Record
+		lat, lon  sql.NullFloat64
+		updatedAt int64
+	)
+	err := d.db.QueryRowContext(ctx,
+		`SELECT cep, city, uf, ibge_code, ddd, siafi, latitude, longitude, updated_at FROM ceps WHERE cep = ?`, cep,
+	).Scan(&record.CEP, &record.City, &record.UF, &record.IBGECode, &record.DDD, &record.SIAFI, &lat, &lon, &updatedAt)
+	if err == sql.ErrNoRows {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("error reading CEP %s: %w", cep, err)
+	}
+	if lat.Valid && lon.Valid {
+		record.Latitude, record.Longitude = &lat.Float64, &lon.Float64
+	}
+	record.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return record, true, nil
+}
+
+// Put stores record, replacing the one of the same CEP. A zero UpdatedAt is
+// set to now.
+func (d *DB) Put(ctx context.Context, record Record) error {
+	return put(ctx, d.db, record)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func put(ctx context.Context, db execer, record Record) error {
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = time.Now()
+	}
+	var lat, lon sql.NullFloat64
+	if record.Latitude != nil && record.Longitude != nil {
+		lat = sql.NullFloat64{Float64: *record.Latitude, Valid: true}
+		lon = sql.NullFloat64{Float64: *record.Longitude, Valid: true}
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO ceps (cep, city, uf, ibge_code, ddd, siafi, latitude, longitude, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.CEP, record.City, record.UF, record.IBGECode, record.DDD, record.SIAFI, lat, lon, record.UpdatedAt.UnixNano(),
+	); err != nil {
+		return fmt.Errorf("error storing CEP %s: %w", record.CEP, err)
+	}
+	return nil
+}
+
+// Count returns how many CEPs are stored.
+func (d *DB) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM ceps`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("error counting CEPs: %w", err)
+	}
+	return n, nil
+}
+
+// Export writes every record to w, one JSON object per line, in CEP order.
+func (d *DB) Export(ctx context.Context, w io.Writer) error {
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT cep, city, uf, ibge_code, ddd, siafi, latitude, longitude, updated_at FROM ceps ORDER BY cep`)
+	if err != nil {
+		return fmt.Errorf("error exporting CEP database: %w", err)
+	}
+	defer rows.Close()
+
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		var (
+			record    Record
+			lat, lon  sql.NullFloat64
+			updatedAt int64
+		)
+		if err := rows.Scan(&record.CEP, &record.City, &record.UF, &record.IBGECode, &record.DDD, &record.SIAFI, &lat, &lon, &updatedAt); err != nil {
+			return fmt.Errorf("error exporting CEP database: %w", err)
+		}
+		if lat.Valid && lon.Valid {
+			record.Latitude, record.Longitude = &lat.Float64, &lon.Float64
+		}
+		record.UpdatedAt = time.Unix(0, updatedAt).UTC()
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import stores the records read from r, one JSON object per line, in a
+// single transaction, after passing each one to check, which may also
+// normalize it. Nothing is stored when a line is malformed or rejected.
+func (d *DB) Import(ctx context.Context, r io.Reader, check func(*Record) error) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting import: %w", err)
+	}
+	defer tx.Rollback()
+
+	scanner := bufio.NewScanner(r)
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := check(&record); err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := put(ctx, tx, record); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading import: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing import: %w", err)
+	}
+	return n, nil
+}
+
+// csvColumns are the columns ImportCSV knows; cep and city are required.
+var csvColumns = []string{"cep", "city", "uf", "ibge_code", "ddd", "siafi", "latitude", "longitude"}
+
+// ImportCSV stores the records of a CSV whose header names its columns,
+// among csvColumns, in a single transaction. Unlike Import, a row that is
+// malformed or rejected by check is skipped and counted, so a few bad rows
+// do not keep a large dataset out.
+func (d *DB) ImportCSV(ctx context.Context, r io.Reader, check func(*Record) error) (stored, skipped int, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if slices.Contains(csvColumns, name) {
+			index[name] = i
+		}
+	}
+	if _, ok := index["cep"]; !ok {
+		return 0, 0, errors.New("CSV header has no cep column")
+	}
+	if _, ok := index["city"]; !ok {
+		return 0, 0, errors.New("CSV header has no city column")
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error starting import: %w", err)
+	}
+	defer tx.Rollback()
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("error reading CSV: %w", err)
+		}
+		record, ok := csvRecord(row, index)
+		if !ok || check(&record) != nil {
+			skipped++
+			continue
+		}
+		if err := put(ctx, tx, record); err != nil {
+			return 0, 0, err
+		}
+		stored++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("error committing import: %w", err)
+	}
+	return stored, skipped, nil
+}
+
+// csvRecord reads a CSV row, reporting false when a coordinate is not a
+// number.
+func csvRecord(row []string, index map[string]int) (Record, bool) {
+	field := func(name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	record := Record{
+		CEP:      field("cep"),
+		City:     field("city"),
+		UF:       field("uf"),
+		IBGECode: field("ibge_code"),
+		DDD:      field("ddd"),
+		SIAFI:    field("siafi"),
+	}
+	if lat, lon := field("latitude"), field("longitude"); lat != "" && lon != "" {
+		latitude, err := strconv.ParseFloat(lat, 64)
+		if err != nil {
+			return Record{}, false
+		}
+		longitude, err := strconv.ParseFloat(lon, 64)
+		if err != nil {
+			return Record{}, false
+		}
+		record.Latitude, record.Longitude = &latitude, &longitude
+	}
+	return record, true
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
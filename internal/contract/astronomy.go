@@ -0,0 +1,36 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Astronomy is the part of Service B's astronomy body Service A relies on.
+type Astronomy struct {
+	City      *string `json:"city"`
+	Date      *string `json:"date"`
+	MoonPhase *string `json:"moon_phase"`
+}
+
+// ValidateAstronomy decodes an astronomy body and checks it names its city,
+// its date and the moon's phase.
+func ValidateAstronomy(body []byte) error {
+	var a Astronomy
+	if err := json.Unmarshal(body, &a); err != nil {
+		return fmt.Errorf("undecodable astronomy: %w", err)
+	}
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{{"city", a.City}, {"date", a.Date}, {"moon_phase", a.MoonPhase}} {
+		if f.value == nil || *f.value == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package contract is the golden contract between Service A, the consumer,
+// and Service B, the provider: the requests Service A sends to Service B and
+// what it relies on in the answers. Both sides are checked against the same
+// interactions, so a change on either side that breaks the other fails
+// before it is deployed:
+//
+//	func TestServiceBHonoursContract(t *testing.T) {
+//		t.Setenv("DEMO_MODE", "true")
+//		svc, _ := serviceb.New(serviceb.Options{Stats: shutdownreport.NewCollector()})
+//		contract.ProviderSuite{Handler: svc.Handler()}.Run(t)
+//	}
+//
+//	func TestServiceARelaysContract(t *testing.T) {
+//		contract.ConsumerSuite{New: func(serviceBURL string) http.Handler {
+//			svc, _ := servicea.New(servicea.Options{ServiceBURL: serviceBURL, Stats: shutdownreport.NewCollector()})
+//			return svc.Handler()
+//		}}.Run(t)
+//	}
+//
+// cmd/contract runs the provider side against a deployed Service B.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Fixtures in the DEMO_MODE data set.
+const (
+	KnownCEP   = "01001000"
+	UnknownCEP = "99999999"
+)
+
+// Interaction is one request Service A sends to Service B and the parts of
+// the answer it depends on.
+type Interaction struct {
+	Name   string
+	Method string
+	Path   string
+	Header http.Header
+
+	Status      int
+	ContentType string
+	// Headers must be present in the response; a non-empty value must match.
+	Headers map[string]string
+	// Fields are the JSON fields and their kinds ("string", "number" or
+	// "object") the body must contain.
+	Fields map[string]string
+	// Body, when set, is the whole body after trimming whitespace.
+	Body string
+	// Example is a response body satisfying the interaction, served by Stub.
+	Example string
+}
+
+// Interactions returns the contract for the given fixtures: knownCEP must
+// resolve to a city with weather and unknownCEP must not exist.
+func Interactions(knownCEP, unknownCEP string) []Interaction {
+	weatherFields := map[string]string{"city": "string", "temp_C": "number", "temp_F": "number", "temp_K": "number"}
+	return []Interaction{
+		{
+			Name:        "weather for a known CEP",
+			Method:      http.MethodGet,
+			Path:        "/weather/" + knownCEP,
+			Header:      http.Header{"Accept-Language": {"en"}},
+			Status:      http.StatusOK,
+			ContentType: "application/json",
+			Headers:     map[string]string{"X-Cache": "", "Age": ""},
+			Fields:      weatherFields,
+			Example:     `{"city":"São Paulo","temp_C":21.5,"temp_F":70.7,"temp_K":294.5}`,
+		},
+		{
+			Name:        "weather with forwarded options",
+			Method:      http.MethodGet,
+			Path:        "/weather/" + knownCEP + "?include=aqi&units=metric",
+			Header:      http.Header{"Accept-Language": {"en"}},
+			Status:      http.StatusOK,
+			ContentType: "application/json",
+			Fields:      map[string]string{"city": "string", "units": "string", "temp_C": "number", "air_quality": "object"},
+			Example:     `{"city":"São Paulo","units":"metric","temp_C":21.5,"air_quality":{"pm2_5":8.4,"pm10":15.2,"us_epa_index":1}}`,
+		},
+		{
+			Name:        "unknown option",
+			Method:      http.MethodGet,
+			Path:        "/weather/" + knownCEP + "?units=rankine",
+			Header:      http.Header{"Accept-Language": {"en"}},
+			Status:      http.StatusBadRequest,
+			ContentType: "text/plain",
+			Example:     `Bad Request: unknown units preset "rankine" (available: [imperial metric scientific])`,
+		},
+		{
+			Name:        "unknown CEP",
+			Method:      http.MethodGet,
+			Path:        "/weather/" + unknownCEP,
+			Header:      http.Header{"Accept-Language": {"en"}},
+			Status:      http.StatusNotFound,
+			ContentType: "text/plain",
+			Headers:     map[string]string{"Content-Language": "en"},
+			Body:        "can not find zipcode",
+			Example:     "can not find zipcode",
+		},
+		{
+			Name:        "unknown CEP in Portuguese",
+			Method:      http.MethodGet,
+			Path:        "/weather/" + unknownCEP,
+			Header:      http.Header{"Accept-Language": {"pt-BR"}},
+			Status:      http.StatusNotFound,
+			ContentType: "text/plain",
+			Headers:     map[string]string{"Content-Language": "pt-BR"},
+			Body:        "CEP não encontrado",
+			Example:     "CEP não encontrado",
+		},
+	}
+}
+
+// Check reports every way the response breaks the interaction.
+func (in Interaction) Check(status int, header http.Header, body []byte) []error {
+	var errs []error
+	if status != in.Status {
+		errs = append(errs, fmt.Errorf("status = %d, want %d", status, in.Status))
+	}
+	if ct := header.Get("Content-Type"); in.ContentType != "" && !strings.HasPrefix(ct, in.ContentType) {
+		errs = append(errs, fmt.Errorf("Content-Type = %q, want %s", ct, in.ContentType))
+	}
+	for name, want := range in.Headers {
+		got, ok := header[http.CanonicalHeaderKey(name)]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("missing header %s", name))
+		case want != "" && got[0] != want:
+			errs = append(errs, fmt.Errorf("%s = %q, want %q", name, got[0], want))
+		}
+	}
+	if in.Body != "" && strings.TrimSpace(string(body)) != in.Body {
+		errs = append(errs, fmt.Errorf("body = %q, want %q", strings.TrimSpace(string(body)), in.Body))
+	}
+	if len(in.Fields) > 0 {
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return append(errs, fmt.Errorf("body is not a JSON object: %w", err))
+		}
+		for name, kind := range in.Fields {
+			if err := checkKind(fields[name], kind); err != nil {
+				errs = append(errs, fmt.Errorf("field %s: %w", name, err))
+			}
+		}
+	}
+	return errs
+}
+
+func checkKind(value any, kind string) error {
+	var ok bool
+	switch kind {
+	case "string":
+		_, ok = value.(string)
+	case "number":
+		_, ok = value.(float64)
+	case "object":
+		_, ok = value.(map[string]any)
+	}
+	if !ok {
+		return fmt.Errorf("got %v, want a %s", value, kind)
+	}
+	return nil
+}
+
+// Stub serves the example answer of each interaction, standing in for
+// Service B on the consumer side. Requests outside the contract get 501.
+func Stub(interactions []Interaction) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, in := range interactions {
+			if r.Method != in.Method || r.URL.RequestURI() != in.Path || !headersMatch(r.Header, in.Header) {
+				continue
+			}
+			w.Header().Set("Content-Type", in.ContentType)
+			for name, value := range in.Headers {
+				if value == "" {
+					value = "stub"
+				}
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(in.Status)
+			fmt.Fprintln(w, in.Example)
+			return
+		}
+		http.Error(w, "request outside the Service B contract: "+r.Method+" "+r.URL.RequestURI(), http.StatusNotImplemented)
+	})
+}
+
+func headersMatch(got, want http.Header) bool {
+	for name := range want {
+		if got.Get(name) != want.Get(name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package contract
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ProviderSuite checks that a Service B handler honours the contract.
+type ProviderSuite struct {
+	Handler http.Handler
+	// KnownCEP and UnknownCEP default to the DEMO_MODE fixtures.
+	KnownCEP   string
+	UnknownCEP string
+}
+
+func (s ProviderSuite) Run(t *testing.T) {
+	t.Helper()
+	for _, in := range Interactions(or(s.KnownCEP, KnownCEP), or(s.UnknownCEP, UnknownCEP)) {
+		t.Run(in.Name, func(t *testing.T) {
+			req := httptest.NewRequest(in.Method, in.Path, nil)
+			req.Header = in.Header.Clone()
+			rec := httptest.NewRecorder()
+			s.Handler.ServeHTTP(rec, req)
+			for _, err := range in.Check(rec.Code, rec.Header(), rec.Body.Bytes()) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// ConsumerSuite checks that Service A, talking to a stub Service B that
+// answers exactly as the contract says, only sends requests the contract
+// covers and relays the answers to its own clients unchanged.
+type ConsumerSuite struct {
+	// New builds Service A's handler forwarding to serviceBURL.
+	New func(serviceBURL string) http.Handler
+}
+
+func (s ConsumerSuite) Run(t *testing.T) {
+	t.Helper()
+	interactions := Interactions(KnownCEP, UnknownCEP)
+	stub := httptest.NewServer(Stub(interactions))
+	t.Cleanup(stub.Close)
+	handler := s.New(stub.URL)
+
+	for _, in := range interactions {
+		t.Run(in.Name, func(t *testing.T) {
+			req := httptest.NewRequest(in.Method, in.Path, nil)
+			req.Header = in.Header.Clone()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code == http.StatusNotImplemented {
+				body, _ := io.ReadAll(rec.Body)
+				t.Fatalf("Service A sent a request outside the contract: %s", strings.TrimSpace(string(body)))
+			}
+			for _, err := range in.Check(rec.Code, rec.Header(), rec.Body.Bytes()) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func or(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
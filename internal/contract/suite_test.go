@@ -0,0 +1,33 @@
+package contract_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/contract"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/servicea"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/serviceb"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+)
+
+func TestServiceBHonoursContract(t *testing.T) {
+	t.Setenv("DEMO_MODE", "true")
+	t.Setenv("ALERT_CHECK_INTERVAL", "0")
+	svc, err := serviceb.New(serviceb.Options{Stats: shutdownreport.NewCollector()})
+	if err != nil {
+		t.Fatalf("starting Service B: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	contract.ProviderSuite{Handler: svc.Handler()}.Run(t)
+}
+
+func TestServiceARelaysContract(t *testing.T) {
+	contract.ConsumerSuite{New: func(serviceBURL string) http.Handler {
+		svc, err := servicea.New(servicea.Options{ServiceBURL: serviceBURL, Stats: shutdownreport.NewCollector()})
+		if err != nil {
+			t.Fatalf("starting Service A: %v", err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		return svc.Handler()
+	}}.Run(t)
+}
@@ -0,0 +1,60 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Weather is the part of Service B's weather body Service A relies on.
+// Pointers tell a missing field from a zero value; fields of the wrong JSON
+// type fail to decode.
+type Weather struct {
+	City       *string    `json:"city"`
+	Units      *string    `json:"units"`
+	TempC      *float64   `json:"temp_C"`
+	TempF      *float64   `json:"temp_F"`
+	TempK      *float64   `json:"temp_K"`
+	ObservedAt *time.Time `json:"observed_at"`
+	AirQuality *struct{}  `json:"air_quality"`
+}
+
+// ValidateWeather decodes a weather body and checks it has a city and its
+// temperatures: all three scales in the default body, at least one in the
+// body of a units preset, which names its preset in units. The ?fields=
+// list the body was requested with narrows the required fields to those it
+// asks for. Field names are matched regardless of case, so version 2's
+// temp_c is temp_C.
+func ValidateWeather(body []byte, fields []string) error {
+	var w Weather
+	if err := json.Unmarshal(body, &w); err != nil {
+		return fmt.Errorf("undecodable weather: %w", err)
+	}
+	present := map[string]bool{
+		"city":   w.City != nil && *w.City != "",
+		"temp_C": w.TempC != nil,
+		"temp_F": w.TempF != nil,
+		"temp_K": w.TempK != nil,
+	}
+	required := []string{"city", "temp_C", "temp_F", "temp_K"}
+	preset := w.Units != nil
+	if preset {
+		required = []string{"city"}
+	}
+	var missing []string
+	for _, name := range required {
+		asked := slices.ContainsFunc(fields, func(field string) bool { return strings.EqualFold(field, name) })
+		if (len(fields) == 0 || asked) && !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if preset && len(fields) == 0 && !present["temp_C"] && !present["temp_F"] && !present["temp_K"] {
+		missing = append(missing, "temperature")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
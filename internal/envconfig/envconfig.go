@@ -0,0 +1,43 @@
+// Package envconfig reads typed settings from environment variables, falling
+// back to a default when a variable is unset or malformed.
+package envconfig
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+func String(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func Duration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s (%q), using %s\n", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func Int(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s (%q), using %d\n", key, value, fallback)
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,175 @@
+package faultinject
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ChaosHeader carries per-request faults, in the CHAOS_FAULTS syntax.
+	ChaosHeader = "X-Chaos-Fault"
+	// chaosBaggage carries ChaosHeader downstream, so a fault requested
+	// from Service A can target Service B's upstreams.
+	chaosBaggage = "chaos.fault"
+)
+
+// ChaosRule fails requests to Target with Fault, each with Probability.
+type ChaosRule struct {
+	Target      string
+	Fault       Fault
+	Probability float64
+}
+
+// ParseChaos parses rules separated by ";", each written
+// "target:kind[=value][@probability]" where kind is latency (a duration),
+// status (an HTTP status code) or drop, and target is an upstream name or
+// "*". For example "weatherapi:status=503@0.5;service-b:latency=2s".
+func ParseChaos(spec string) ([]ChaosRule, error) {
+	var rules []ChaosRule
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		target, fault, ok := strings.Cut(raw, ":")
+		if !ok || target == "" {
+			return nil, fmt.Errorf("chaos rule %q: want target:kind[=value][@probability]", raw)
+		}
+		rule := ChaosRule{Target: target, Probability: 1}
+		if f, p, ok := strings.Cut(fault, "@"); ok {
+			probability, err := strconv.ParseFloat(p, 64)
+			if err != nil || probability < 0 || probability > 1 {
+				return nil, fmt.Errorf("chaos rule %q: probability must be between 0 and 1", raw)
+			}
+			fault, rule.Probability = f, probability
+		}
+		kind, value, _ := strings.Cut(fault, "=")
+		switch kind {
+		case "latency":
+			latency, err := time.ParseDuration(value)
+			if err != nil || latency <= 0 {
+				return nil, fmt.Errorf("chaos rule %q: latency must be a positive duration", raw)
+			}
+			rule.Fault.Latency = latency
+		case "status":
+			status, err := strconv.Atoi(value)
+			if err != nil || status < 100 || status > 599 {
+				return nil, fmt.Errorf("chaos rule %q: status must be an HTTP status code", raw)
+			}
+			rule.Fault.Status = status
+		case "drop":
+			rule.Fault.Err = ErrConnectionDropped
+		default:
+			return nil, fmt.Errorf("chaos rule %q: unknown fault %q (available: latency, status, drop)", raw, kind)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Chaos injects faults into outgoing requests, from CHAOS_FAULTS and from
+// the X-Chaos-Fault header of the request being served. Injected faults are
+// recorded as events on the client span.
+type Chaos struct {
+	// targets names upstream hosts; other hosts are matched by host.
+	targets map[string]string
+	rules   []ChaosRule
+}
+
+// ChaosFromEnv returns the chaos layer when CHAOS_ENABLED is true, and nil
+// otherwise. targets maps upstream hosts to the names used in rules.
+func ChaosFromEnv(targets map[string]string) (*Chaos, error) {
+	if envconfig.String("CHAOS_ENABLED", "false") != "true" {
+		return nil, nil
+	}
+	rules, err := ParseChaos(envconfig.String("CHAOS_FAULTS", ""))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("CHAOS_ENABLED: injecting %d configured faults and honouring %s\n", len(rules), ChaosHeader)
+	return &Chaos{targets: targets, rules: rules}, nil
+}
+
+// Middleware moves the X-Chaos-Fault header of incoming requests into the
+// baggage, where Wrap and downstream services find it. Without chaos
+// enabled the header is ignored.
+func (c *Chaos) Middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if spec := r.Header.Get(ChaosHeader); spec != "" {
+			member, err := baggage.NewMemberRaw(chaosBaggage, spec)
+			if err == nil {
+				var bag baggage.Baggage
+				if bag, err = baggage.FromContext(r.Context()).SetMember(member); err == nil {
+					r = r.WithContext(baggage.ContextWithBaggage(r.Context(), bag))
+				}
+			}
+			if err != nil {
+				log.Printf("Ignoring %s %q: %v\n", ChaosHeader, spec, err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Wrap returns a transport that applies the first matching rule to each
+// request before handing it to base.
+func (c *Chaos) Wrap(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		target := req.URL.Host
+		if name, ok := c.targets[target]; ok {
+			target = name
+		}
+		rules := c.rules
+		if spec := baggage.FromContext(req.Context()).Member(chaosBaggage).Value(); spec != "" {
+			requested, err := ParseChaos(spec)
+			if err != nil {
+				log.Printf("Ignoring requested chaos %q: %v\n", spec, err)
+			}
+			rules = append(requested, rules...)
+		}
+		for _, rule := range rules {
+			if rule.Target != "*" && rule.Target != target || rand.Float64() >= rule.Probability {
+				continue
+			}
+			span := trace.SpanFromContext(req.Context())
+			span.SetAttributes(attribute.Bool("chaos.injected", true))
+			span.AddEvent("chaos.fault.injected", trace.WithAttributes(
+				attribute.String("chaos.target", target),
+				attribute.String("chaos.fault", rule.Fault.String()),
+			))
+			return rule.Fault.apply(req, base)
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+func (fault Fault) String() string {
+	var parts []string
+	if fault.Latency > 0 {
+		parts = append(parts, "latency="+fault.Latency.String())
+	}
+	if fault.Status != 0 {
+		parts = append(parts, "status="+strconv.Itoa(fault.Status))
+	}
+	if fault.Err != nil {
+		parts = append(parts, "error="+fault.Err.Error())
+	}
+	return strings.Join(parts, ",")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,176 @@
+// Package faultinject provides an http.RoundTripper that injects upstream
+// failures, plus a standard set of failure scenarios, so providers and
+// middleware can be exercised with table-driven failure-mode tests.
+package faultinject
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrConnectionDropped = errors.New("faultinject: connection dropped")
+
+// Fault describes how a matching request fails. A zero Status with no Err
+// forwards the request to the base transport after Latency.
+type Fault struct {
+	Latency     time.Duration
+	Status      int
+	Body        string
+	ContentType string
+	Err         error
+}
+
+type rule struct {
+	host  string
+	fault Fault
+}
+
+// Transport applies the first fault whose host matches the request host.
+// An empty host matches every request.
+type Transport struct {
+	Base http.RoundTripper
+
+	mu    sync.Mutex
+	rules []rule
+	calls map[string]int
+}
+
+func New(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base, calls: make(map[string]int)}
+}
+
+func (t *Transport) Inject(host string, f Fault) {
+	t.mu.Lock()
+	t.rules = append(t.rules, rule{host: host, fault: f})
+	t.mu.Unlock()
+}
+
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	t.rules = nil
+	t.calls = make(map[string]int)
+	t.mu.Unlock()
+}
+
+// Calls reports how many requests were made to host.
+func (t *Transport) Calls(host string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls[host]
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.calls[req.URL.Host]++
+	fault, ok := t.match(req.URL.Host)
+	t.mu.Unlock()
+
+	if !ok {
+		return t.Base.RoundTrip(req)
+	}
+	return fault.apply(req, t.Base)
+}
+
+// apply fails req as fault describes, forwarding it to base when fault only
+// adds latency.
+func (fault Fault) apply(req *http.Request, base http.RoundTripper) (*http.Response, error) {
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if fault.Err != nil {
+		return nil, fault.Err
+	}
+	if fault.Status == 0 {
+		return base.RoundTrip(req)
+	}
+
+	body := fault.Body
+	if body == "" {
+		body = http.StatusText(fault.Status)
+	}
+	contentType := fault.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return &http.Response{
+		Status:        http.StatusText(fault.Status),
+		StatusCode:    fault.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) match(host string) (Fault, bool) {
+	for _, r := range t.rules {
+		if r.host == "" || r.host == host {
+			return r.fault, true
+		}
+	}
+	return Fault{}, false
+}
+
+// Scenario is a named failure mode for table-driven tests.
+type Scenario struct {
+	Name  string
+	Fault Fault
+}
+
+// StandardScenarios lists the upstream failures every provider and
+// middleware is expected to survive.
+func StandardScenarios() []Scenario {
+	return []Scenario{
+		{Name: "connection dropped", Fault: Fault{Err: ErrConnectionDropped}},
+		{Name: "internal server error", Fault: Fault{Status: http.StatusInternalServerError}},
+		{Name: "bad gateway", Fault: Fault{Status: http.StatusBadGateway}},
+		{Name: "service unavailable", Fault: Fault{Status: http.StatusServiceUnavailable}},
+		{Name: "rate limited", Fault: Fault{Status: http.StatusTooManyRequests, Body: "rate limit exceeded"}},
+		{Name: "html error page", Fault: Fault{Status: http.StatusOK, ContentType: "text/html", Body: "<html><body>Erro</body></html>"}},
+		{Name: "malformed json", Fault: Fault{Status: http.StatusOK, ContentType: "application/json", Body: `{"localidade":`}},
+		{Name: "empty body", Fault: Fault{Status: http.StatusOK, ContentType: "application/json", Body: " "}},
+	}
+}
+
+// Decision is a degradation decision observed through Recorder.
+type Decision struct {
+	Dependency string
+	Mode       string
+	Cause      error
+}
+
+// Recorder collects degradation decisions; its Record method matches the
+// degradation controller hook signature.
+type Recorder struct {
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+func (r *Recorder) Record(dependency, mode string, cause error) {
+	r.mu.Lock()
+	r.decisions = append(r.decisions, Decision{Dependency: dependency, Mode: mode, Cause: cause})
+	r.mu.Unlock()
+}
+
+func (r *Recorder) Decisions() []Decision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Decision(nil), r.decisions...)
+}
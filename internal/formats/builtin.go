@@ -0,0 +1,237 @@
+package formats
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+func init() {
+	Register(JSON, encodeJSON)
+	Register("application/xml", encodeXML)
+	Register("text/xml", encodeXML)
+	Register("text/csv", encodeCSV)
+	Register("application/msgpack", encodeMsgpack)
+	Register("application/x-msgpack", encodeMsgpack)
+}
+
+func encodeJSON(w io.Writer, _ string, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// encodeXML writes v as the element name, with a child element per field
+// and an <item> per list entry. Null fields are left out.
+func encodeXML(w io.Writer, name string, v any) error {
+	value, err := decode(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, name, value); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeXML(enc *xml.Encoder, name string, value any) error {
+	if value == nil {
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case object:
+		for _, f := range value {
+			if err := writeXML(enc, f.name, f.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarText(value))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// encodeCSV writes an object as a header and a single row, and a list of
+// objects as a header and a row each. Nested fields become dotted columns,
+// such as air_quality.pm10.
+func encodeCSV(w io.Writer, _ string, v any) error {
+	value, err := decode(v)
+	if err != nil {
+		return err
+	}
+	rows := []any{value}
+	if list, ok := value.([]any); ok {
+		rows = list
+	}
+
+	var columns []string
+	seen := map[string]bool{}
+	flat := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		flat[i] = map[string]string{}
+		flatten("", row, func(column, text string) {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+			flat[i][column] = text
+		})
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, row := range flat {
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func flatten(prefix string, value any, emit func(column, text string)) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+	switch value := value.(type) {
+	case object:
+		for _, f := range value {
+			flatten(join(f.name), f.value, emit)
+		}
+	case []any:
+		for i, item := range value {
+			flatten(join(strconv.Itoa(i)), item, emit)
+		}
+	case nil:
+	default:
+		emit(prefix, scalarText(value))
+	}
+}
+
+func scalarText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return fmt.Sprint(value)
+}
+
+// encodeMsgpack writes v in MessagePack, with objects as maps, integral
+// numbers as integers and the other numbers as float64.
+func encodeMsgpack(w io.Writer, _ string, v any) error {
+	value, err := decode(v)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeMsgpack(bw, value); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeMsgpack(w *bufio.Writer, value any) error {
+	switch value := value.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+	case bool:
+		if value {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return writeMsgpackInt(w, n)
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return err
+		}
+		w.WriteByte(0xcb)
+		return binary.Write(w, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeMsgpackLength(w, len(value), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		_, err := w.WriteString(value)
+		return err
+	case []any:
+		writeMsgpackLength(w, len(value), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := writeMsgpack(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case object:
+		writeMsgpackLength(w, len(value), 0x80, 15, 0, 0xde, 0xdf)
+		for _, f := range value {
+			if err := writeMsgpack(w, f.name); err != nil {
+				return err
+			}
+			if err := writeMsgpack(w, f.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("formats: cannot encode %T as MessagePack", value)
+}
+
+func writeMsgpackInt(w *bufio.Writer, n int64) error {
+	if n >= -32 && n <= 127 {
+		return w.WriteByte(byte(int8(n)))
+	}
+	w.WriteByte(0xd3)
+	return binary.Write(w, binary.BigEndian, n)
+}
+
+// writeMsgpackLength writes the header of a string, array or map of length
+// n: the fix format up to fixMax, then the 8 (when the family has one), 16
+// and 32 bit forms.
+func writeMsgpackLength(w *bufio.Writer, n int, fix byte, fixMax int, len8, len16, len32 byte) {
+	switch {
+	case n <= fixMax:
+		w.WriteByte(fix | byte(n))
+	case len8 != 0 && n <= math.MaxUint8:
+		w.WriteByte(len8)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(len16)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(len32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,158 @@
+// Package formats encodes response bodies in the format a client negotiates
+// through Accept. Encoders are registered per media type; JSON, XML, CSV and
+// MessagePack are built in.
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON is the media type served when the client has no preference.
+const JSON = "application/json"
+
+// Encoder writes v, any value encoding/json can marshal, to w. name labels
+// the value where the format needs one, such as the XML root element.
+type Encoder func(w io.Writer, name string, v any) error
+
+var (
+	registryMu sync.RWMutex
+	encoders   = map[string]Encoder{}
+)
+
+// Register makes enc available for mediaType. It panics when mediaType is
+// registered twice.
+func Register(mediaType string, enc Encoder) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if enc == nil {
+		panic("formats: Register encoder is nil")
+	}
+	if _, dup := encoders[mediaType]; dup {
+		panic("formats: Register called twice for " + mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+// MediaTypes lists the registered media types.
+func MediaTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(encoders))
+	for mediaType := range encoders {
+		types = append(types, mediaType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Negotiate picks the registered media type the Accept header prefers most.
+// An empty header, wildcards and +json types select JSON. ok is false when
+// the header rules out every registered type.
+func Negotiate(accept string) (mediaType string, enc Encoder, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		accept = JSON
+	}
+	type weighted struct {
+		mediaType string
+		q         float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, weighted{mediaType, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, pref := range prefs {
+		mediaType := pref.mediaType
+		if mediaType == "*/*" || mediaType == "application/*" || strings.HasSuffix(mediaType, "+json") {
+			mediaType = JSON
+		}
+		if enc, ok := encoders[mediaType]; ok {
+			return mediaType, enc, true
+		}
+	}
+	return "", nil, false
+}
+
+// decode turns v into the generic values its JSON encoding holds, keeping
+// the order of object fields so every format lists them as JSON does.
+func decode(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+// object is a JSON object with its fields in order.
+type object []field
+
+type field struct {
+	name  string
+	value any
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			var obj object
+			for dec.More() {
+				name, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, field{name.(string), value})
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			var list []any
+			for dec.More() {
+				value, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		return nil, fmt.Errorf("formats: unexpected %v", tok)
+	default:
+		return tok, nil
+	}
+}
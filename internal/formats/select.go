@@ -0,0 +1,138 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Select keeps only the named fields of v, an object or a list of objects,
+// in their original order. A dotted name such as air_quality.pm10 keeps one
+// field of a nested object. Names are checked against the JSON fields of
+// v's type, so a field left out by omitempty is not an error but a typo is.
+func Select(v any, names []string) (any, error) {
+	known := FieldNames(reflect.TypeOf(v))
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown field %q (available: %v)", name, known)
+		}
+	}
+	value, err := decode(v)
+	if err != nil {
+		return nil, err
+	}
+	if list, ok := value.([]any); ok {
+		for i, item := range list {
+			list[i] = selectFields(item, names)
+		}
+		return list, nil
+	}
+	return selectFields(value, names), nil
+}
+
+func selectFields(value any, names []string) any {
+	obj, ok := value.(object)
+	if !ok {
+		return value
+	}
+	var out object
+	for _, f := range obj {
+		var nested []string
+		whole := false
+		for _, name := range names {
+			if name == f.name {
+				whole = true
+			} else if rest, ok := strings.CutPrefix(name, f.name+"."); ok {
+				nested = append(nested, rest)
+			}
+		}
+		switch {
+		case whole:
+			out = append(out, f)
+		case len(nested) > 0:
+			out = append(out, field{f.name, selectFields(f.value, nested)})
+		}
+	}
+	return out
+}
+
+// Rename renames every object field of v, nested ones included, with
+// rename, keeping their order.
+func Rename(v any, rename func(string) string) (any, error) {
+	value, err := decode(v)
+	if err != nil {
+		return nil, err
+	}
+	return renameFields(value, rename), nil
+}
+
+func renameFields(value any, rename func(string) string) any {
+	switch value := value.(type) {
+	case object:
+		for i, f := range value {
+			value[i] = field{rename(f.name), renameFields(f.value, rename)}
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = renameFields(item, rename)
+		}
+	}
+	return value
+}
+
+// FieldNames lists the JSON names of the fields of t, a struct or a slice
+// or pointer to one, with nested struct fields as dotted names too.
+func FieldNames(t reflect.Type) []string {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t.PkgPath() == "time" {
+		return nil
+	}
+	var names []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+		for _, nested := range FieldNames(f.Type) {
+			names = append(names, name+"."+nested)
+		}
+	}
+	return names
+}
+
+// MarshalJSON keeps the field order, which a map would lose.
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+// Package history records every weather lookup so operators can query recent
+// traffic, error rates and upstream latency.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// UpstreamCall is one call a lookup made to a dependency such as ViaCEP or
+// WeatherAPI.
+type UpstreamCall struct {
+	Upstream string
+	Latency  time.Duration
+	OK       bool
+}
+
+type Entry struct {
+	CEP       string
+	City      string
+	TempC     float64
+	Latency   time.Duration
+	Status    int
+	TraceID   string
+	CreatedAt time.Time
+	Upstreams []UpstreamCall
+	// Events are recorded in the same transaction as the lookup, so they
+	// exist if and only if the lookup was stored.
+	Events []Event
+}
+
+// Event is a billing or notification event raised by a lookup, kept until
+// it is relayed (transactional outbox). ID stays the same across delivery
+// attempts so consumers can drop duplicates.
+type Event struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Repository stores lookup entries. Implementations must be safe for
+// concurrent use.
+type Repository interface {
+	Record(ctx context.Context, entry Entry) error
+	Since(ctx context.Context, since time.Time) ([]Entry, error)
+	Close() error
+}
+
+// EventStore is the outbox side of a Repository: the events recorded with
+// lookups that were not relayed yet, oldest first.
+type EventStore interface {
+	PendingEvents(ctx context.Context, limit int) ([]Event, error)
+	MarkRelayed(ctx context.Context, id string) error
+}
+
+type CEPCount struct {
+	CEP   string `json:"cep"`
+	Count int    `json:"count"`
+}
+
+type UpstreamStats struct {
+	Calls        int     `json:"calls"`
+	Errors       int     `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+}
+
+type Stats struct {
+	Since        time.Time                `json:"since"`
+	Lookups      int                      `json:"lookups"`
+	Errors       int                      `json:"errors"`
+	ErrorRate    float64                  `json:"error_rate"`
+	P95LatencyMs float64                  `json:"p95_latency_ms"`
+	ByStatus     map[int]int              `json:"by_status"`
+	TopCEPs      []CEPCount               `json:"top_ceps"`
+	Upstreams    map[string]UpstreamStats `json:"upstreams"`
+}
+
+// Summarize aggregates entries into Stats, keeping the topN most looked up
+// CEPs. Responses with status >= 500 count as errors.
+func Summarize(since time.Time, entries []Entry, topN int) Stats {
+	stats := Stats{
+		Since:     since.UTC(),
+		Lookups:   len(entries),
+		ByStatus:  make(map[int]int),
+		TopCEPs:   []CEPCount{},
+		Upstreams: make(map[string]UpstreamStats),
+	}
+
+	cepCounts := make(map[string]int)
+	latencies := make([]time.Duration, 0, len(entries))
+	upstreamLatencies := make(map[string][]time.Duration)
+	for _, entry := range entries {
+		stats.ByStatus[entry.Status]++
+		if entry.Status >= 500 {
+			stats.Errors++
+		}
+		cepCounts[entry.CEP]++
+		latencies = append(latencies, entry.Latency)
+
+		for _, call := range entry.Upstreams {
+			upstream := stats.Upstreams[call.Upstream]
+			upstream.Calls++
+			if !call.OK {
+				upstream.Errors++
+			}
+			stats.Upstreams[call.Upstream] = upstream
+			upstreamLatencies[call.Upstream] = append(upstreamLatencies[call.Upstream], call.Latency)
+		}
+	}
+
+	stats.ErrorRate = ratio(stats.Errors, stats.Lookups)
+	stats.P95LatencyMs = p95(latencies)
+	for name, upstream := range stats.Upstreams {
+		upstream.ErrorRate = ratio(upstream.Errors, upstream.Calls)
+		upstream.P95LatencyMs = p95(upstreamLatencies[name])
+		stats.Upstreams[name] = upstream
+	}
+
+	for cep, count := range cepCounts {
+		stats.TopCEPs = append(stats.TopCEPs, CEPCount{CEP: cep, Count: count})
+	}
+	sort.Slice(stats.TopCEPs, func(i, j int) bool {
+		if stats.TopCEPs[i].Count != stats.TopCEPs[j].Count {
+			return stats.TopCEPs[i].Count > stats.TopCEPs[j].Count
+		}
+		return stats.TopCEPs[i].CEP < stats.TopCEPs[j].CEP
+	})
+	if len(stats.TopCEPs) > topN {
+		stats.TopCEPs = stats.TopCEPs[:topN]
+	}
+	return stats
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// p95 uses the nearest-rank method and reports milliseconds.
+func p95(latencies []time.Duration) float64 {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+	return float64(latencies[rank]) / float64(time.Millisecond)
+}
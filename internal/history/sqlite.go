@@ -0,0 +1,178 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS lookups (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	cep        TEXT    NOT NULL,
+	city       TEXT    NOT NULL,
+	temp_c     REAL    NOT NULL,
+	latency_ns INTEGER NOT NULL,
+	status     INTEGER NOT NULL,
+	trace_id   TEXT    NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lookups_created_at ON lookups (created_at);
+CREATE TABLE IF NOT EXISTS upstream_calls (
+	lookup_id  INTEGER NOT NULL REFERENCES lookups (id) ON DELETE CASCADE,
+	upstream   TEXT    NOT NULL,
+	latency_ns INTEGER NOT NULL,
+	ok         INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS upstream_calls_lookup_id ON upstream_calls (lookup_id);
+CREATE TABLE IF NOT EXISTS events (
+	seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+	id         TEXT    NOT NULL UNIQUE,
+	lookup_id  INTEGER NOT NULL REFERENCES lookups (id) ON DELETE CASCADE,
+	kind       TEXT    NOT NULL,
+	payload    TEXT    NOT NULL,
+	created_at INTEGER NOT NULL,
+	relayed_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS events_pending ON events (seq) WHERE relayed_at IS NULL;
+`
+
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the history database at path.
+func OpenSQLite(path string) (*SQLiteRepository, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening history database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating history schema: %w", err)
+	}
+	return &SQLiteRepository{db: db}, nil
+}
+
+func (r *SQLiteRepository) Record(ctx context.Context, entry Entry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting history transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO lookups (cep, city, temp_c, latency_ns, status, trace_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.CEP, entry.City, entry.TempC, int64(entry.Latency), entry.Status, entry.TraceID, entry.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("error recording lookup: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error recording lookup: %w", err)
+	}
+	for _, call := range entry.Upstreams {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO upstream_calls (lookup_id, upstream, latency_ns, ok) VALUES (?, ?, ?, ?)`,
+			id, call.Upstream, int64(call.Latency), call.OK,
+		); err != nil {
+			return fmt.Errorf("error recording upstream call: %w", err)
+		}
+	}
+	for _, event := range entry.Events {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO events (id, lookup_id, kind, payload, created_at) VALUES (?, ?, ?, ?, ?)`,
+			event.ID, id, event.Kind, string(event.Payload), event.CreatedAt.UnixNano(),
+		); err != nil {
+			return fmt.Errorf("error recording event: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, kind, payload, created_at FROM events WHERE relayed_at IS NULL ORDER BY seq LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var (
+			event     Event
+			payload   string
+			createdAt int64
+		)
+		if err := rows.Scan(&event.ID, &event.Kind, &payload, &createdAt); err != nil {
+			return nil, fmt.Errorf("error reading events: %w", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		event.CreatedAt = time.Unix(0, createdAt)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (r *SQLiteRepository) MarkRelayed(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE events SET relayed_at = ? WHERE id = ?`, time.Now().UnixNano(), id); err != nil {
+		return fmt.Errorf("error marking event relayed: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) Since(ctx context.Context, since time.Time) ([]Entry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT l.id, l.cep, l.city, l.temp_c, l.latency_ns, l.status, l.trace_id, l.created_at,
+		       u.upstream, u.latency_ns, u.ok
+		FROM lookups l
+		LEFT JOIN upstream_calls u ON u.lookup_id = l.id
+		WHERE l.created_at >= ?
+		ORDER BY l.id`, since.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("error querying history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	lastID := int64(-1)
+	for rows.Next() {
+		var (
+			id, latency, createdAt int64
+			entry                  Entry
+			upstream               sql.NullString
+			upstreamLatency        sql.NullInt64
+			ok                     sql.NullBool
+		)
+		if err := rows.Scan(&id, &entry.CEP, &entry.City, &entry.TempC, &latency, &entry.Status, &entry.TraceID, &createdAt,
+			&upstream, &upstreamLatency, &ok); err != nil {
+			return nil, fmt.Errorf("error reading history: %w", err)
+		}
+		if id != lastID {
+			entry.Latency = time.Duration(latency)
+			entry.CreatedAt = time.Unix(0, createdAt)
+			entries = append(entries, entry)
+			lastID = id
+		}
+		if upstream.Valid {
+			current := &entries[len(entries)-1]
+			current.Upstreams = append(current.Upstreams, UpstreamCall{
+				Upstream: upstream.String,
+				Latency:  time.Duration(upstreamLatency.Int64),
+				OK:       ok.Bool,
+			})
+		}
+	}
+	return entries, rows.Err()
+}
+
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestRepository(t *testing.T) *SQLiteRepository {
+	t.Helper()
+	repo, err := OpenSQLite(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestEventsAreRecordedWithTheirLookup(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestRepository(t)
+	now := time.Now()
+	entry := Entry{CEP: "01001000", City: "São Paulo", Status: 200, CreatedAt: now, Events: []Event{
+		{ID: "evt_1", Kind: "billing.lookup", Payload: json.RawMessage(`{"cep":"01001000"}`), CreatedAt: now},
+		{ID: "evt_2", Kind: "notification.lookup", Payload: json.RawMessage(`{"status":200}`), CreatedAt: now},
+	}}
+	if err := repo.Record(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := repo.PendingEvents(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != "evt_1" || pending[1].ID != "evt_2" {
+		t.Fatalf("pending events = %+v, want evt_1 then evt_2", pending)
+	}
+	if string(pending[0].Payload) != `{"cep":"01001000"}` {
+		t.Errorf("payload = %s", pending[0].Payload)
+	}
+
+	if err := repo.MarkRelayed(ctx, "evt_1"); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = repo.PendingEvents(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != "evt_2" {
+		t.Fatalf("pending events after relaying evt_1 = %+v, want evt_2", pending)
+	}
+}
+
+func TestFailedRecordKeepsNeitherLookupNorEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestRepository(t)
+	now := time.Now()
+	event := Event{ID: "evt_1", Kind: "notification.lookup", Payload: json.RawMessage(`{}`), CreatedAt: now}
+	if err := repo.Record(ctx, Entry{CEP: "01001000", CreatedAt: now, Events: []Event{event}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A duplicate event ID fails the insert after the lookup row was written.
+	other := Event{ID: "evt_2", Kind: "billing.lookup", Payload: json.RawMessage(`{}`), CreatedAt: now}
+	if err := repo.Record(ctx, Entry{CEP: "20040020", CreatedAt: now, Events: []Event{other, event}}); err == nil {
+		t.Fatal("Record with a duplicate event ID succeeded")
+	}
+
+	entries, err := repo.Since(ctx, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].CEP != "01001000" {
+		t.Errorf("lookups = %+v, want only 01001000", entries)
+	}
+	pending, err := repo.PendingEvents(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != "evt_1" {
+		t.Errorf("pending events = %+v, want only evt_1", pending)
+	}
+}
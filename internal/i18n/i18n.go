@@ -0,0 +1,79 @@
+// Package i18n translates client facing error messages. Messages are looked
+// up by their English format string, gettext style: English needs no
+// catalog, and a message missing from a catalog falls back to English.
+// Machine readable error codes are never translated.
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	English    = "en"
+	Portuguese = "pt-BR"
+)
+
+var catalogs = map[string]map[string]string{
+	Portuguese: portuguese,
+}
+
+// Language picks the supported language the client prefers most, from its
+// Accept-Language header. Any Portuguese tag maps to pt-BR; everything else
+// falls back to English.
+func Language(r *http.Request) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if lang := supported(tag); lang != "" && q > 0 {
+			prefs = append(prefs, weighted{lang, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	if len(prefs) == 0 {
+		return English
+	}
+	return prefs[0].lang
+}
+
+func supported(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	switch base {
+	case "pt":
+		return Portuguese
+	case "en", "*":
+		return English
+	}
+	return ""
+}
+
+// T formats msg in lang.
+func T(lang, msg string, args ...any) string {
+	if translated, ok := catalogs[lang][msg]; ok {
+		msg = translated
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Error is http.Error with msg translated to the request's language.
+func Error(w http.ResponseWriter, r *http.Request, status int, msg string, args ...any) {
+	lang := Language(r)
+	w.Header().Set("Content-Language", lang)
+	http.Error(w, T(lang, msg, args...), status)
+}
@@ -0,0 +1,71 @@
+package i18n
+
+var portuguese = map[string]string{
+	"invalid zipcode":                           "CEP inválido",
+	"can not find zipcode":                      "CEP não encontrado",
+	"upstream returned an invalid response":     "O provedor externo retornou uma resposta inválida",
+	"upstream rejected the request":             "O provedor externo recusou a requisição",
+	"upstream rejected the service credentials": "O provedor externo recusou as credenciais do serviço",
+	"upstream quota exceeded, try again later":  "A cota do provedor externo foi excedida, tente novamente mais tarde",
+	"can not find location":                     "localização não encontrada",
+	"job not found":                             "job não encontrado",
+	"alert not found":                           "alerta não encontrado",
+	"icon not found":                            "ícone não encontrado",
+	"could not fetch icon":                      "não foi possível obter o ícone",
+
+	"Bad Request: callback_url must be an absolute http(s) URL":     "Requisição inválida: callback_url deve ser uma URL http(s) absoluta",
+	"Bad Request: unknown units preset %q (available: %v)":          "Requisição inválida: predefinição de unidades %q desconhecida (disponíveis: %v)",
+	"Bad Request: lat and lon must be decimal degrees within range": "Requisição inválida: lat e lon devem ser graus decimais dentro do intervalo válido",
+	"Bad Request: unknown include %q (available: %v)":               "Requisição inválida: include %q desconhecido (disponíveis: %v)",
+	"Bad Request: unknown field %q (available: %v)":                 "Requisição inválida: campo %q desconhecido (disponíveis: %v)",
+	"Bad Request: threshold_c is required":                          "Requisição inválida: threshold_c é obrigatório",
+	"Bad Request: direction must be %q or %q":                       "Requisição inválida: direction deve ser %q ou %q",
+	"Bad Request: date must be YYYY-MM-DD":                          "Requisição inválida: date deve estar no formato AAAA-MM-DD",
+	"Bad Request: %v":                                               "Requisição inválida: %v",
+	"Conflict: alert limit of %d reached":                           "Conflito: limite de %d alertas atingido",
+	"Method Not Allowed":                                            "Método não permitido",
+	"Not Found":                                                     "Não encontrado",
+	"Internal Server Error":                                         "Erro interno do servidor",
+
+	"Malformed JSON":                                 "JSON malformado",
+	"Malformed JSON at offset %d":                    "JSON malformado na posição %d",
+	"Request body must not exceed %d bytes":          "O corpo da requisição não pode exceder %d bytes",
+	"Request body must contain a single JSON object": "O corpo da requisição deve conter um único objeto JSON",
+	"Request body must not be empty":                 "O corpo da requisição não pode ser vazio",
+	"Field %q must be a %s":                          "O campo %q deve ser do tipo %s",
+	"Unknown field %s":                               "Campo desconhecido %s",
+	"ceps must hold between 1 and %d entries":        "ceps deve conter entre 1 e %d itens",
+	"batch job not found":                            "lote não encontrado",
+	"page and page_size must be positive integers":   "page e page_size devem ser inteiros positivos",
+	"Service Unavailable: could not save batch job":  "Serviço indisponível: não foi possível salvar o lote",
+
+	"Idempotency-Key must not exceed %d characters":             "Idempotency-Key não pode exceder %d caracteres",
+	"Idempotency-Key was already used with a different request": "Idempotency-Key já foi usada com uma requisição diferente",
+	"A request with this Idempotency-Key is still in progress":  "Uma requisição com esta Idempotency-Key ainda está em andamento",
+
+	"Too Many Requests: concurrent request limit exceeded": "Muitas requisições: limite de requisições simultâneas excedido",
+	"Forbidden":                                               "Proibido",
+	"A bearer token is required":                              "É necessário um token bearer",
+	"Invalid bearer token: %v":                                "Token bearer inválido: %v",
+	"A valid X-API-Key is required":                           "É necessária uma X-API-Key válida",
+	"Daily quota of %d requests exceeded":                     "Cota diária de %d requisições excedida",
+	"Service Unavailable: too many queued requests":           "Serviço indisponível: muitas requisições na fila",
+	"Not Found: unknown API version %d (available: %v)":       "Não encontrado: versão da API %d desconhecida (disponíveis: %v)",
+	"Not Acceptable: %s is served as %s":                      "Não aceitável: %s é servido como %s",
+	"Not Acceptable: unsupported API version (available: %v)": "Não aceitável: versão da API não suportada (disponíveis: %v)",
+	"Content-Type must be text/csv or application/x-ndjson":   "Content-Type deve ser text/csv ou application/x-ndjson",
+	"Import stopped: %v":                                      "Importação interrompida: %v",
+	"Not Acceptable: available formats are %v":                "Não aceitável: os formatos disponíveis são %v",
+	"Service Unavailable: too many weather streams":           "Serviço indisponível: muitas transmissões de clima abertas",
+	"Service Unavailable: could not enqueue lookup":           "Serviço indisponível: não foi possível enfileirar a consulta",
+	"Service Unavailable: too many pending callbacks":         "Serviço indisponível: muitos callbacks pendentes",
+
+	"Internal Server Error: Failed to create request to Service B: %v": "Erro interno do servidor: falha ao criar a requisição ao Serviço B: %v",
+	"Internal Server Error: Failed to reach Service B: %v":             "Erro interno do servidor: falha ao acessar o Serviço B: %v",
+	"Failed to reach Service B: %v":                                    "Falha ao acessar o Serviço B: %v",
+	"Bad Gateway: malformed response from Service B: %v":               "Gateway inválido: resposta malformada do Serviço B: %v",
+	"Internal server error getting location: %v":                       "Erro interno do servidor ao obter a localização: %v",
+	"Internal server error getting weather: %v":                        "Erro interno do servidor ao obter o clima: %v",
+	"Internal server error getting astronomy: %v":                      "Erro interno do servidor ao obter os dados astronômicos: %v",
+	"Internal server error: %v":                                        "Erro interno do servidor: %v",
+}
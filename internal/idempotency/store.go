@@ -0,0 +1,153 @@
+// Package idempotency replays the stored response of a request retried with
+// the same Idempotency-Key. Responses live in a Store, which can be shared by
+// every replica (Redis) or kept per process (memory).
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Response is a stored response, together with the fingerprint of the
+// request that produced it.
+type Response struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Store holds responses by key. Begin either returns the stored response,
+// reserves the key for the caller (acquired), or reports that another
+// request holding the key is still in flight (neither).
+type Store interface {
+	Begin(ctx context.Context, key string, lockTTL time.Duration) (stored *Response, acquired bool, err error)
+	Complete(ctx context.Context, key string, resp *Response, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+	Close() error
+}
+
+type memoryEntry struct {
+	resp    *Response
+	expires time.Time
+}
+
+// MemoryStore keeps up to maxEntries responses in process; it is only
+// shared by requests reaching the same replica.
+type MemoryStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{maxEntries: maxEntries, entries: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Begin(_ context.Context, key string, lockTTL time.Duration) (*Response, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if entry, ok := m.entries[key]; ok && now.Before(entry.expires) {
+		return entry.resp, false, nil
+	}
+	if m.maxEntries > 0 && len(m.entries) >= m.maxEntries {
+		m.evict(now)
+	}
+	m.entries[key] = memoryEntry{expires: now.Add(lockTTL)}
+	return nil, true, nil
+}
+
+// evict drops expired entries, and the oldest one if none had expired.
+func (m *MemoryStore) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range m.entries {
+		if !now.Before(entry.expires) {
+			delete(m.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+	if len(m.entries) >= m.maxEntries {
+		delete(m.entries, oldestKey)
+	}
+}
+
+func (m *MemoryStore) Complete(_ context.Context, key string, resp *Response, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{resp: resp, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemoryStore) Close() error { return nil }
+
+const redisPending = "pending"
+
+// RedisStore shares responses between replicas. Keys are reserved with
+// SET NX, so only one replica processes a given key at a time.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the Redis at url, e.g. redis://localhost:6379/0.
+func NewRedisStore(url, prefix string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+func (s *RedisStore) Begin(ctx context.Context, key string, lockTTL time.Duration) (*Response, bool, error) {
+	key = s.prefix + key
+	acquired, err := s.client.SetNX(ctx, key, redisPending, lockTTL).Result()
+	if err != nil || acquired {
+		return nil, acquired, err
+	}
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Released or expired between the two calls; let the caller retry.
+		return nil, false, nil
+	}
+	if err != nil || string(value) == redisPending {
+		return nil, false, err
+	}
+	var resp Response
+	if err := json.Unmarshal(value, &resp); err != nil {
+		return nil, false, err
+	}
+	return &resp, false, nil
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, resp *Response, ttl time.Duration) error {
+	value, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+func (s *RedisStore) Close() error { return s.client.Close() }
@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+)
+
+// Fixtures served by the fake upstreams.
+const (
+	KnownCEP   = "01001000"
+	KnownCity  = "São Paulo"
+	KnownTempC = 25.0
+	UnknownCEP = "99999999"
+	InvalidCEP = "123"
+	SlowCEP    = "22222222"
+	// MalformedCEP resolves to a city the fake WeatherAPI answers with
+	// broken JSON.
+	MalformedCEP = "33333333"
+	HTMLErrorCEP = "44444444"
+	// RejectedCEP is well formed but the fake ViaCEP answers it with 400.
+	RejectedCEP = "55555555"
+	// MalformedViaCEPCEP is answered by the fake ViaCEP with broken JSON.
+	MalformedViaCEPCEP = "66666666"
+
+	// malformedCity is where the fake WeatherAPI answers with broken JSON.
+	malformedCity = "Cidade Quebrada"
+)
+
+// Upstreams are fake ViaCEP and WeatherAPI servers. They remember the
+// propagation headers of the last request each received.
+type Upstreams struct {
+	ViaCEP     *httptest.Server
+	WeatherAPI *httptest.Server
+	// SlowDelay is how long the fakes take to answer for SlowCEP; it must
+	// exceed Service B's HTTP_CLIENT_TIMEOUT.
+	SlowDelay time.Duration
+
+	mu      sync.Mutex
+	headers map[string]http.Header
+}
+
+func newUpstreams(slowDelay time.Duration) *Upstreams {
+	u := &Upstreams{SlowDelay: slowDelay, headers: make(map[string]http.Header)}
+	u.ViaCEP = httptest.NewServer(http.HandlerFunc(u.viaCEP))
+	u.WeatherAPI = httptest.NewServer(http.HandlerFunc(u.weatherAPI))
+	return u
+}
+
+func (u *Upstreams) Close() {
+	u.ViaCEP.Close()
+	u.WeatherAPI.Close()
+}
+
+// LastHeaders returns the headers of the last request upstream ("viacep" or
+// "weatherapi") received.
+func (u *Upstreams) LastHeaders(upstream string) http.Header {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.headers[upstream]
+}
+
+func (u *Upstreams) record(upstream string, r *http.Request) {
+	u.mu.Lock()
+	u.headers[upstream] = r.Header.Clone()
+	u.mu.Unlock()
+}
+
+// viaCEP serves GET /ws/{cep}/json/.
+func (u *Upstreams) viaCEP(w http.ResponseWriter, r *http.Request) {
+	u.record("viacep", r)
+	cep := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/ws/"), "/json/")
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch cep {
+	case KnownCEP:
+		writeJSON(w, map[string]string{"cep": "01001-000", "localidade": KnownCity, "uf": "SP", "ibge": "3550308", "ddd": "11", "siafi": "7107"})
+	case SlowCEP:
+		select {
+		case <-time.After(u.SlowDelay):
+		case <-r.Context().Done():
+			return
+		}
+		writeJSON(w, map[string]string{"localidade": KnownCity, "uf": "SP"})
+	case MalformedCEP:
+		writeJSON(w, map[string]string{"localidade": malformedCity, "uf": "SP"})
+	case MalformedViaCEPCEP:
+		fmt.Fprint(w, `{"localidade":`)
+	case RejectedCEP:
+		w.WriteHeader(http.StatusBadRequest)
+	case HTMLErrorCEP:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body><h1>Too many requests</h1></body></html>")
+	default:
+		writeJSON(w, map[string]bool{"erro": true})
+	}
+}
+
+// weatherAPI serves GET /v1/current.json?q=..., matching the city in queries
+// such as "Sao Paulo,SP,Brazil" regardless of accents, like WeatherAPI.
+func (u *Upstreams) weatherAPI(w http.ResponseWriter, r *http.Request) {
+	u.record("weatherapi", r)
+	w.Header().Set("Content-Type", "application/json")
+	city, _, _ := strings.Cut(r.URL.Query().Get("q"), ",")
+	switch {
+	case placename.Equal(city, KnownCity):
+		writeJSON(w, map[string]any{
+			"location": map[string]string{"name": KnownCity, "region": "Sao Paulo"},
+			"current": map[string]any{"temp_c": KnownTempC, "wind_kph": 10.0, "last_updated_epoch": time.Now().Unix(),
+				"condition": map[string]any{"text": "Sunny", "icon": "//cdn.weatherapi.com/weather/64x64/day/113.png", "code": 1000}},
+		})
+	case placename.Equal(city, malformedCity):
+		fmt.Fprint(w, `{"current": {"temp_c": `)
+	default:
+		writeJSON(w, map[string]any{"error": map[string]any{"code": 1006, "message": "No matching location found."}})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic("integration: encoding fake response: " + err.Error())
+	}
+}
@@ -0,0 +1,104 @@
+// Package integration runs Service A and Service B in-process, wired to each
+// other over real HTTP and to fake ViaCEP and WeatherAPI servers, and checks
+// the whole lookup end to end, including trace and baggage propagation:
+//
+//	func TestEndToEnd(t *testing.T) {
+//		integration.Suite{}.Run(t)
+//	}
+//
+// Start gives tests the running harness for scenarios of their own.
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/servicea"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/serviceb"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// clientTimeout is Service B's upstream timeout inside the harness.
+const clientTimeout = 500 * time.Millisecond
+
+// Harness is a running pair of services and their fake upstreams.
+type Harness struct {
+	ServiceA  *httptest.Server
+	ServiceB  *httptest.Server
+	Upstreams *Upstreams
+	// Spans records every span both services end.
+	Spans *tracetest.SpanRecorder
+}
+
+// Start brings the harness up for t and tears it down when t ends. It sets
+// environment variables and the global tracer provider, so tests using it
+// must not run in parallel.
+func Start(t *testing.T) *Harness {
+	t.Helper()
+
+	h := &Harness{Spans: tracetest.NewSpanRecorder(), Upstreams: newUpstreams(4 * clientTimeout)}
+	t.Cleanup(h.Upstreams.Close)
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(h.Spans)))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	for key, value := range map[string]string{
+		"DEMO_MODE":            "",
+		"CEP_PROVIDER":         "viacep",
+		"WEATHER_API_KEY":      "integration",
+		"WEATHER_CACHE_TTL":    "0",
+		"HTTP_CLIENT_TIMEOUT":  clientTimeout.String(),
+		"ALERT_CHECK_INTERVAL": "0",
+		"VIACEP_BASE_URL":      h.Upstreams.ViaCEP.URL,
+		"WEATHERAPI_BASE_URL":  h.Upstreams.WeatherAPI.URL,
+	} {
+		t.Setenv(key, value)
+	}
+
+	svcB, err := serviceb.New(serviceb.Options{Stats: shutdownreport.NewCollector()})
+	if err != nil {
+		t.Fatalf("starting Service B: %v", err)
+	}
+	t.Cleanup(func() { svcB.Close() })
+	h.ServiceB = httptest.NewServer(svcB.Handler())
+	t.Cleanup(h.ServiceB.Close)
+
+	svcA, err := servicea.New(servicea.Options{ServiceBURL: h.ServiceB.URL, Stats: shutdownreport.NewCollector()})
+	if err != nil {
+		t.Fatalf("starting Service A: %v", err)
+	}
+	t.Cleanup(func() { svcA.Close() })
+	h.ServiceA = httptest.NewServer(svcA.Handler())
+	t.Cleanup(h.ServiceA.Close)
+
+	return h
+}
+
+// Get sends GET path to Service A with the given headers.
+func (h *Harness) Get(t *testing.T, path string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, h.ServiceA.URL+path, nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceparent is the W3C trace context the suite sends to Service A.
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+// Suite is the end-to-end scenario set.
+type Suite struct{}
+
+func (Suite) Run(t *testing.T) {
+	t.Helper()
+	h := Start(t)
+
+	t.Run("known cep", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+KnownCEP, nil)
+		requireStatus(t, resp, http.StatusOK)
+		var body struct {
+			City  string  `json:"city"`
+			TempC float64 `json:"temp_C"`
+			TempF float64 `json:"temp_F"`
+			TempK float64 `json:"temp_K"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if body.City != KnownCity || body.TempC != KnownTempC || body.TempF != KnownTempC*1.8+32 || body.TempK != KnownTempC+273 {
+			t.Fatalf("response = %+v, want %s at %.1f°C", body, KnownCity, KnownTempC)
+		}
+	})
+
+	t.Run("unknown cep", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+UnknownCEP, nil)
+		requireStatus(t, resp, http.StatusNotFound)
+		requireBody(t, resp, "can not find zipcode")
+	})
+
+	t.Run("invalid cep", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+InvalidCEP, nil)
+		requireStatus(t, resp, http.StatusUnprocessableEntity)
+		requireBody(t, resp, "invalid zipcode")
+	})
+
+	t.Run("viacep bad request", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+RejectedCEP, nil)
+		requireStatus(t, resp, http.StatusUnprocessableEntity)
+		requireBody(t, resp, "invalid zipcode")
+	})
+
+	t.Run("upstream timeout", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+SlowCEP, nil)
+		requireStatus(t, resp, http.StatusInternalServerError)
+	})
+
+	t.Run("malformed weatherapi json", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+MalformedCEP, nil)
+		requireStatus(t, resp, http.StatusBadGateway)
+	})
+
+	t.Run("malformed viacep json", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+MalformedViaCEPCEP, nil)
+		requireStatus(t, resp, http.StatusBadGateway)
+		requireBody(t, resp, "upstream returned an invalid response")
+	})
+
+	t.Run("viacep html error page", func(t *testing.T) {
+		resp := h.Get(t, "/weather/"+HTMLErrorCEP, nil)
+		requireStatus(t, resp, http.StatusBadGateway)
+		requireBody(t, resp, "upstream returned an invalid response")
+	})
+
+	t.Run("propagation", func(t *testing.T) {
+		header := http.Header{"Traceparent": {traceparent}, "X-Tenant-Id": {"acme"}}
+		resp := h.Get(t, "/weather/"+KnownCEP, header)
+		requireStatus(t, resp, http.StatusOK)
+
+		for _, upstream := range []string{"viacep", "weatherapi"} {
+			received := h.Upstreams.LastHeaders(upstream)
+			parts := strings.Split(received.Get("Traceparent"), "-")
+			if len(parts) != 4 || parts[1] != traceID {
+				t.Errorf("%s got traceparent %q, want trace %s", upstream, received.Get("Traceparent"), traceID)
+			}
+			bag, err := baggage.Parse(received.Get("Baggage"))
+			if err != nil || bag.Member("tenant").Value() != "acme" {
+				t.Errorf("%s got baggage %q, want tenant=acme", upstream, received.Get("Baggage"))
+			}
+		}
+
+		// One server span in Service A and one in Service B.
+		servers := 0
+		for _, span := range h.Spans.Ended() {
+			if span.SpanContext().TraceID().String() == traceID && span.SpanKind() == trace.SpanKindServer && span.Name() == "GET /weather/{cep}" {
+				servers++
+			}
+		}
+		if servers != 2 {
+			t.Errorf("trace %s has %d GET /weather/{cep} server spans, want 2", traceID, servers)
+		}
+	})
+}
+
+func requireStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d, want %d (body %q)", resp.StatusCode, want, strings.TrimSpace(string(body)))
+	}
+}
+
+func requireBody(t *testing.T, resp *http.Response, want string) {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	if got := strings.TrimSpace(string(body)); got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/integration"
+)
+
+func TestEndToEnd(t *testing.T) {
+	integration.Suite{}.Run(t)
+}
@@ -0,0 +1,118 @@
+// Package ipfilter rejects requests by client address, using CIDR allow and
+// deny lists checked before any other work is done for the request.
+package ipfilter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Filter decides on the connection's remote address, not on forwarding
+// headers, which any client can set.
+type Filter struct {
+	allow  []netip.Prefix
+	deny   []netip.Prefix
+	denied metric.Int64Counter
+}
+
+// New parses comma-separated lists of CIDRs or bare IPs. A deny match always
+// wins; a non-empty allow list rejects every address it does not contain.
+// With both lists empty New returns a nil Filter, whose Middleware is a
+// no-op.
+func New(allow, deny string, meter metric.Meter) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.allow, err = parsePrefixes(allow); err != nil {
+		return nil, fmt.Errorf("invalid allow list: %w", err)
+	}
+	if f.deny, err = parsePrefixes(deny); err != nil {
+		return nil, fmt.Errorf("invalid deny list: %w", err)
+	}
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return nil, nil
+	}
+	f.denied, err = meter.Int64Counter("http.server.ip_denied",
+		metric.WithDescription("Number of requests rejected by the IP allow/deny lists"),
+	)
+	if err != nil {
+		log.Printf("Failed to create IP filter counter: %v\n", err)
+	}
+	return f, nil
+}
+
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns why addr is rejected, or "" when it may pass.
+func (f *Filter) check(addr netip.Addr) string {
+	switch {
+	case contains(f.deny, addr):
+		return "denied"
+	case len(f.allow) > 0 && !contains(f.allow, addr):
+		return "not_allowed"
+	}
+	return ""
+}
+
+// Middleware answers 403 to rejected clients without calling next. Requests
+// without a remote IP, such as those arriving over a Unix socket, match no
+// deny entry, but are rejected when an allow list is set.
+func (f *Filter) Middleware(next http.Handler) http.Handler {
+	if f == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reason string
+		if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+			reason = f.check(addrPort.Addr().Unmap())
+		} else if len(f.allow) > 0 {
+			reason = "no_address"
+		}
+		if reason != "" {
+			if f.denied != nil {
+				f.denied.Add(r.Context(), 1, metric.WithAttributes(attribute.String("ipfilter.reason", reason)))
+			}
+			i18n.Error(w, r, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,42 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      string
+		deny       string
+		remoteAddr string
+		want       int
+	}{
+		{name: "allowed", allow: "10.0.0.0/8", remoteAddr: "10.1.2.3:4567", want: http.StatusOK},
+		{name: "not allowed", allow: "10.0.0.0/8", remoteAddr: "192.0.2.1:4567", want: http.StatusForbidden},
+		{name: "denied", deny: "192.0.2.1", remoteAddr: "192.0.2.1:4567", want: http.StatusForbidden},
+		{name: "mapped IPv4", deny: "192.0.2.1", remoteAddr: "[::ffff:192.0.2.1]:4567", want: http.StatusForbidden},
+		{name: "unix socket with an allow list", allow: "10.0.0.0/8", remoteAddr: "@", want: http.StatusForbidden},
+		{name: "unix socket with a deny list", deny: "192.0.2.1", remoteAddr: "@", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.allow, tt.deny, noop.NewMeterProvider().Meter("test"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := f.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("answered %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
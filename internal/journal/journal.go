@@ -0,0 +1,150 @@
+// Package journal appends a sanitized descriptor of every request to a
+// rotating JSON lines file, so production traffic can be replayed later with
+// cmd/replay. Only the route, normalized CEP, outcome and trace ID are kept;
+// bodies, headers and client addresses are never written.
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/rotatefile"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Record struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	CEP        string    `json:"cep,omitempty"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	TraceID    string    `json:"trace_id,omitempty"`
+}
+
+// Writer appends records to path, rotating it to path.1, path.2, ... once
+// it grows past maxBytes and keeping at most maxFiles rotated files.
+type Writer struct {
+	// OmitCEP keeps CEPs out of the journal, whose records then cannot be
+	// replayed.
+	OmitCEP bool
+
+	out *rotatefile.Writer
+}
+
+func Open(path string, maxBytes int64, maxFiles int) (*Writer, error) {
+	out, err := rotatefile.Open(path, maxBytes, maxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	return &Writer{out: out}, nil
+}
+
+func (w *Writer) Append(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error encoding journal record: %w", err)
+	}
+	if _, err := w.out.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) Close() error { return w.out.Close() }
+
+// ReadFile returns every record in a journal file.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+type cepKey struct{}
+
+// SetCEP attaches the normalized CEP of the current request to its journal
+// record. It is a no-op for requests the journal does not see.
+func SetCEP(ctx context.Context, cep string) {
+	if holder, ok := ctx.Value(cepKey{}).(*string); ok {
+		*holder = cep
+	}
+}
+
+// Middleware journals every request handled by next. The route is the
+// matched ServeMux pattern, never the raw URL.
+func (w *Writer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var cep string
+		r = r.WithContext(context.WithValue(r.Context(), cepKey{}, &cep))
+		rec := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+
+		defer func() {
+			record := Record{
+				Time:       start.UTC(),
+				Method:     r.Method,
+				Route:      r.Pattern,
+				Status:     rec.status,
+				DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+			}
+			if !w.OmitCEP {
+				record.CEP = cep
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				record.TraceID = sc.TraceID().String()
+			}
+			if err := w.Append(record); err != nil {
+				log.Printf("Failed to append to request journal: %v\n", err)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
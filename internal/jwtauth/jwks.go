@@ -0,0 +1,120 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey means no key of the JWKS has the token's kid, even after
+// fetching it again.
+var ErrUnknownKey = errors.New("token signed with an unknown key")
+
+// minRefetch keeps tokens with made-up kids from hammering the JWKS URL.
+const minRefetch = 30 * time.Second
+
+// JWKS holds the RSA keys published at a JSON Web Key Set URL. Keys are
+// fetched on first use, again every refresh, and early when a token names a
+// key not seen yet.
+type JWKS struct {
+	url     string
+	client  *http.Client
+	refresh time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func NewJWKS(url string, client *http.Client, refresh time.Duration) *JWKS {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &JWKS{url: url, client: client, refresh: refresh}
+}
+
+// Key returns the key with id kid. An empty kid matches the set's only key.
+func (j *JWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, ok := j.lookup(kid)
+	age := time.Since(j.fetchedAt)
+	if ok && age < j.refresh || !ok && j.keys != nil && age < minRefetch {
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	}
+	if err := j.fetch(ctx); err != nil {
+		if ok {
+			// Keep serving the keys we have while the URL is unreachable.
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok = j.lookup(kid); !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (j *JWKS) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (j *JWKS) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	j.keys, j.fetchedAt = keys, time.Now()
+	return nil
+}
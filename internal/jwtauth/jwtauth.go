@@ -0,0 +1,159 @@
+// Package jwtauth verifies JWT bearer tokens signed with HS256, using a
+// shared secret, or RS256, using the keys published at a JWKS URL.
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed token")
+	ErrSignature = errors.New("invalid token signature")
+	ErrExpired   = errors.New("token expired or not yet valid")
+	ErrClaims    = errors.New("token issuer or audience not accepted")
+)
+
+// Config selects the accepted tokens. HS256 is only accepted when Secret is
+// set and RS256 only when JWKS is, so a token cannot pick the algorithm it
+// is checked with. Empty Issuer and Audience accept any.
+type Config struct {
+	Secret   []byte
+	JWKS     *JWKS
+	Issuer   string
+	Audience string
+	// Leeway absorbs clock skew when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// Claims are the registered claims of a verified token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+}
+
+type Verifier struct {
+	cfg Config
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if len(cfg.Secret) == 0 && cfg.JWKS == nil {
+		return nil, errors.New("jwtauth: a secret or a JWKS is required")
+	}
+	return &Verifier{cfg: cfg}, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience decodes the aud claim, which is a string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+type payload struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// Verify checks the signature, validity period, issuer and audience of
+// token. Tokens without exp are rejected.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+
+	switch {
+	case h.Alg == "HS256" && len(v.cfg.Secret) > 0:
+		mac := hmac.New(sha256.New, v.cfg.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return Claims{}, ErrSignature
+		}
+	case h.Alg == "RS256" && v.cfg.JWKS != nil:
+		key, err := v.cfg.JWKS.Key(ctx, h.Kid)
+		if err != nil {
+			return Claims{}, err
+		}
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return Claims{}, ErrSignature
+		}
+	default:
+		return Claims{}, fmt.Errorf("%w: algorithm %q not accepted", ErrSignature, h.Alg)
+	}
+
+	var p payload
+	if err := decodeSegment(parts[1], &p); err != nil {
+		return Claims{}, ErrMalformed
+	}
+	now := time.Now()
+	if p.ExpiresAt == nil || now.After(time.Unix(*p.ExpiresAt, 0).Add(v.cfg.Leeway)) {
+		return Claims{}, ErrExpired
+	}
+	if p.NotBefore != nil && now.Add(v.cfg.Leeway).Before(time.Unix(*p.NotBefore, 0)) {
+		return Claims{}, ErrExpired
+	}
+	if v.cfg.Issuer != "" && p.Issuer != v.cfg.Issuer {
+		return Claims{}, ErrClaims
+	}
+	if v.cfg.Audience != "" && !slices.Contains(p.Audience, v.cfg.Audience) {
+		return Claims{}, ErrClaims
+	}
+	return Claims{Subject: p.Subject, Issuer: p.Issuer, Audience: p.Audience, ExpiresAt: time.Unix(*p.ExpiresAt, 0)}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type contextKey struct{}
+
+// ContextWithClaims records the claims a request was authenticated with.
+func ContextWithClaims(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the claims of the request, if it was authenticated.
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(contextKey{}).(Claims)
+	return c, ok
+}
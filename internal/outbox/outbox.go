@@ -0,0 +1,298 @@
+// Package outbox persists lookups that failed because an upstream was down
+// and replays them once it is back. Replays work like a circuit breaker:
+// while the oldest entry keeps failing the circuit stays open and nothing
+// else is tried; once it goes through, the rest is drained.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrFull = errors.New("outbox is full")
+
+// Circuit states reported by Status.
+const (
+	CircuitClosed = "closed"
+	CircuitOpen   = "open"
+)
+
+// Entry is a lookup waiting to be replayed. Payload is whatever the
+// service needs to redo it.
+type Entry struct {
+	ID          string            `json:"id"`
+	Kind        string            `json:"kind"`
+	Payload     json.RawMessage   `json:"payload,omitempty"`
+	Attempts    int               `json:"attempts"`
+	LastError   string            `json:"last_error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	LastAttempt *time.Time        `json:"last_attempt,omitempty"`
+	Carrier     map[string]string `json:"carrier,omitempty"`
+}
+
+// Handler replays an entry. It records the outcome itself, success or a
+// definitive failure, and returns an error only when the upstream is still
+// down and the entry must be kept.
+type Handler func(ctx context.Context, entry Entry) error
+
+// Outbox keeps entries in memory and, one JSON file each, in dir.
+type Outbox struct {
+	dir        string
+	maxEntries int
+
+	mu        sync.Mutex
+	entries   []*Entry
+	circuit   string
+	replayed  int64
+	lastProbe time.Time
+}
+
+// Open loads the entries saved in dir, which is created if needed.
+func Open(dir string, maxEntries int) (*Outbox, error) {
+	o := &Outbox{dir: dir, maxEntries: maxEntries, circuit: CircuitClosed}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error opening outbox: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error opening outbox: %w", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening outbox: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Printf("Skipping unreadable outbox entry %s: %v\n", path, err)
+			continue
+		}
+		o.entries = append(o.entries, &entry)
+	}
+	// IDs start with the creation time, so this is oldest first.
+	slices.SortFunc(o.entries, func(a, b *Entry) int { return strings.Compare(a.ID, b.ID) })
+	if len(o.entries) > 0 {
+		o.circuit = CircuitOpen
+	}
+	return o, nil
+}
+
+// Add saves a lookup that failed with cause. ctx's trace context and
+// baggage are kept, so the replay links back to the original request.
+func (o *Outbox) Add(ctx context.Context, kind string, payload any, cause error) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox entry: %w", err)
+	}
+	now := time.Now().UTC()
+	entry := &Entry{
+		ID:        fmt.Sprintf("%020d-%s", now.UnixNano(), randomHex(4)),
+		Kind:      kind,
+		Payload:   data,
+		Attempts:  1,
+		LastError: cause.Error(),
+		CreatedAt: now,
+		Carrier:   make(map[string]string),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(entry.Carrier))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.entries) >= o.maxEntries {
+		return ErrFull
+	}
+	if err := o.save(entry); err != nil {
+		return err
+	}
+	o.entries = append(o.entries, entry)
+	o.circuit = CircuitOpen
+	trace.SpanFromContext(ctx).AddEvent("outbox.added", trace.WithAttributes(
+		attribute.String("outbox.entry.id", entry.ID),
+		attribute.String("outbox.kind", kind),
+	))
+	return nil
+}
+
+// Run replays entries with handle every interval until ctx is done.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration, handle Handler) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.drain(ctx, handle)
+		}
+	}
+}
+
+// drain replays entries oldest first, stopping at the first one the
+// upstream still fails.
+func (o *Outbox) drain(ctx context.Context, handle Handler) {
+	for ctx.Err() == nil {
+		o.mu.Lock()
+		if len(o.entries) == 0 {
+			o.circuit = CircuitClosed
+			o.mu.Unlock()
+			return
+		}
+		entry := *o.entries[0]
+		o.lastProbe = time.Now().UTC()
+		o.mu.Unlock()
+
+		err := o.replay(ctx, handle, entry)
+		if ctx.Err() != nil {
+			return
+		}
+
+		o.mu.Lock()
+		if err != nil {
+			o.circuit = CircuitOpen
+			now := time.Now().UTC()
+			entry.Attempts++
+			entry.LastError, entry.LastAttempt = err.Error(), &now
+			if i := o.index(entry.ID); i >= 0 {
+				*o.entries[i] = entry
+			}
+			if err := o.save(&entry); err != nil {
+				log.Printf("Failed to save outbox entry %s: %v\n", entry.ID, err)
+			}
+			o.mu.Unlock()
+			return
+		}
+		o.circuit = CircuitClosed
+		o.replayed++
+		if i := o.index(entry.ID); i >= 0 {
+			o.entries = slices.Delete(o.entries, i, i+1)
+		}
+		if err := os.Remove(o.path(entry.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove outbox entry %s: %v\n", entry.ID, err)
+		}
+		o.mu.Unlock()
+	}
+}
+
+// replay runs handle for entry in a new trace linked to the request that
+// failed, with that request's baggage.
+func (o *Outbox) replay(ctx context.Context, handle Handler, entry Entry) error {
+	origin := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(entry.Carrier))
+	ctx = baggage.ContextWithBaggage(ctx, baggage.FromContext(origin))
+	ctx, span := otel.Tracer("outbox").Start(ctx, "outbox-replay",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(origin, attribute.String("link.reason", "outbox origin"))),
+		trace.WithAttributes(
+			attribute.String("outbox.entry.id", entry.ID),
+			attribute.String("outbox.kind", entry.Kind),
+			attribute.Int("outbox.attempt", entry.Attempts+1),
+		),
+	)
+	defer span.End()
+
+	err := handle(ctx, entry)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "upstream still unavailable")
+	}
+	return err
+}
+
+// Status is the progress report served by StatusHandler.
+type Status struct {
+	Circuit   string     `json:"circuit"`
+	Pending   int        `json:"pending"`
+	Replayed  int64      `json:"replayed"`
+	Oldest    *time.Time `json:"oldest,omitempty"`
+	LastProbe *time.Time `json:"last_probe,omitempty"`
+	Entries   []Entry    `json:"entries"`
+}
+
+// statusEntries caps how many entries Status lists.
+const statusEntries = 100
+
+func (o *Outbox) Status() Status {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	status := Status{Circuit: o.circuit, Pending: len(o.entries), Replayed: o.replayed, Entries: []Entry{}}
+	if len(o.entries) > 0 {
+		oldest := o.entries[0].CreatedAt
+		status.Oldest = &oldest
+	}
+	if !o.lastProbe.IsZero() {
+		lastProbe := o.lastProbe
+		status.LastProbe = &lastProbe
+	}
+	for _, entry := range o.entries[:min(len(o.entries), statusEntries)] {
+		e := *entry
+		e.Payload, e.Carrier = nil, nil
+		status.Entries = append(status.Entries, e)
+	}
+	return status
+}
+
+// StatusHandler serves Status as JSON.
+func (o *Outbox) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(o.Status()); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// index finds the entry with id. The caller holds o.mu.
+func (o *Outbox) index(id string) int {
+	return slices.IndexFunc(o.entries, func(e *Entry) bool { return e.ID == id })
+}
+
+func (o *Outbox) path(id string) string { return filepath.Join(o.dir, id+".json") }
+
+// save writes entry to a temporary file and renames it into place. The
+// caller holds o.mu.
+func (o *Outbox) save(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(o.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("error saving outbox entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving outbox entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving outbox entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path(entry.ID)); err != nil {
+		return fmt.Errorf("error saving outbox entry: %w", err)
+	}
+	return nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("outbox: reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
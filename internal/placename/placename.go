@@ -0,0 +1,90 @@
+// Package placename normalizes Brazilian city names so they can be sent to
+// providers that stumble on accents and compared regardless of how each
+// provider spells them.
+package placename
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// StripAccents returns name without diacritics, so "São João d'Aliança"
+// becomes "Sao Joao d'Alianca". Letters keep their case.
+func StripAccents(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, name)
+	if err != nil {
+		return name
+	}
+	return stripped
+}
+
+// Fold reduces name to the form used for comparisons: without accents, in
+// lower case and with hyphens, apostrophes and runs of spaces collapsed to
+// single spaces.
+func Fold(name string) string {
+	name = strings.ToLower(StripAccents(name))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '\'' || r == '’'
+	}), " ")
+}
+
+// Equal reports whether a and b name the same place once folded.
+func Equal(a, b string) bool {
+	return Fold(a) == Fold(b)
+}
+
+// WeatherQuery builds the location query for a city, disambiguated with its
+// state when known: "São Paulo" in SP becomes "Sao Paulo,SP,Brazil". Without
+// a city the state itself is asked for, as in "Minas Gerais,Brazil".
+func WeatherQuery(city, uf string) string {
+	city = strings.TrimSpace(StripAccents(city))
+	if city == "" {
+		if name, ok := StateName(uf); ok {
+			return StripAccents(name) + ",Brazil"
+		}
+		return ""
+	}
+	if uf = strings.ToUpper(strings.TrimSpace(uf)); uf == "" {
+		return city
+	}
+	return city + "," + uf + ",Brazil"
+}
+
+// QueryUF returns the state of a query built by WeatherQuery, or "" when the
+// query does not name one.
+func QueryUF(query string) string {
+	parts := strings.Split(query, ",")
+	if len(parts) != 3 || parts[2] != "Brazil" {
+		return ""
+	}
+	return parts[1]
+}
+
+var stateNames = map[string]string{
+	"AC": "Acre", "AL": "Alagoas", "AP": "Amapá", "AM": "Amazonas",
+	"BA": "Bahia", "CE": "Ceará", "DF": "Distrito Federal", "ES": "Espírito Santo",
+	"GO": "Goiás", "MA": "Maranhão", "MT": "Mato Grosso", "MS": "Mato Grosso do Sul",
+	"MG": "Minas Gerais", "PA": "Pará", "PB": "Paraíba", "PR": "Paraná",
+	"PE": "Pernambuco", "PI": "Piauí", "RJ": "Rio de Janeiro", "RN": "Rio Grande do Norte",
+	"RS": "Rio Grande do Sul", "RO": "Rondônia", "RR": "Roraima", "SC": "Santa Catarina",
+	"SP": "São Paulo", "SE": "Sergipe", "TO": "Tocantins",
+}
+
+// StateName returns the name of the state with code uf, such as "Minas
+// Gerais" for "MG".
+func StateName(uf string) (string, bool) {
+	name, ok := stateNames[strings.ToUpper(strings.TrimSpace(uf))]
+	return name, ok
+}
+
+// InState reports whether region, as a provider names it, is the state uf.
+// Providers spell states out, with or without accents, or use the code.
+func InState(region, uf string) bool {
+	name, ok := StateName(uf)
+	return Equal(region, uf) || ok && Equal(region, name)
+}
@@ -0,0 +1,73 @@
+// Package privacy keeps raw CEPs out of telemetry. A CEP and a timestamp can
+// be enough to identify a person, so in privacy mode spans and logs carry a
+// keyed hash instead: lookups of the same CEP still correlate, but without
+// the key the 100 million possible CEPs cannot be brute-forced back.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+)
+
+// cepPattern finds CEPs in free text such as URLs and error messages.
+var cepPattern = regexp.MustCompile(`\b\d{5}-?\d{3}\b`)
+
+type Hasher struct {
+	key []byte
+}
+
+func NewHasher(key string) *Hasher {
+	return &Hasher{key: []byte(key)}
+}
+
+// FromEnv returns the hasher of PRIVACY_MODE, or nil when it is off. Both
+// services must share PRIVACY_HASH_KEY for their hashes to match, so it is
+// required rather than generated.
+func FromEnv() (*Hasher, error) {
+	if os.Getenv("PRIVACY_MODE") != "true" {
+		return nil, nil
+	}
+	key := os.Getenv("PRIVACY_HASH_KEY")
+	if key == "" {
+		return nil, errors.New("PRIVACY_MODE requires PRIVACY_HASH_KEY")
+	}
+	return NewHasher(key), nil
+}
+
+// Hash returns "cep:" and the first 16 hex digits of the HMAC-SHA256 of the
+// normalized CEP, so "01001-000" and "01001000" hash alike.
+func (h *Hasher) Hash(value string) string {
+	if normalized, err := cep.Normalize(value); err == nil {
+		value = normalized
+	}
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return "cep:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Scrub replaces every CEP in s with its hash.
+func (h *Hasher) Scrub(s string) string {
+	return cepPattern.ReplaceAllStringFunc(s, h.Hash)
+}
+
+// Writer scrubs what is written to w. The log package writes one entry per
+// call, so CEPs are never split across writes.
+func (h *Hasher) Writer(w io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		if _, err := io.WriteString(w, h.Scrub(string(p))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	})
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
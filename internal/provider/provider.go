@@ -0,0 +1,194 @@
+// Package provider defines the contracts implemented by CEP and weather
+// upstream adapters, and the error taxonomy they report failures with.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+)
+
+var (
+	// ErrNotFound means the upstream answered and the CEP or location does
+	// not exist. Its message is what clients receive.
+	ErrNotFound = errors.New("can not find zipcode")
+
+	// ErrUnavailable means the upstream could not give an answer (network
+	// failure, 5xx, rate limiting). It must never be reported as the
+	// caller's fault.
+	ErrUnavailable = errors.New("upstream unavailable")
+
+	// ErrBadResponse means the upstream answered with something other than
+	// the JSON it documents, such as an HTML error page or rate limit text.
+	// Like ErrUnavailable it is never the caller's fault.
+	ErrBadResponse = errors.New("upstream returned an invalid response")
+
+	// ErrRejected means the upstream refused the request with a 4xx status
+	// that says nothing about the CEP or location, such as a bad key or a
+	// URL it does not serve. It is a configuration problem on our side.
+	ErrRejected = errors.New("upstream rejected the request")
+
+	// ErrUnauthorized is an ErrRejected caused by a missing, invalid or
+	// disabled API key.
+	ErrUnauthorized = fmt.Errorf("%w: credentials refused", ErrRejected)
+
+	// ErrQuotaExceeded is an ErrUnavailable caused by the account running
+	// out of calls; the upstream answers again once the quota resets.
+	ErrQuotaExceeded = fmt.Errorf("%w: quota exceeded", ErrUnavailable)
+
+	// ErrInvalidCEP means the CEP is malformed or the upstream rejected it as
+	// such. It is cep.ErrInvalid, so cep.Normalize failures match it too.
+	ErrInvalidCEP = cep.ErrInvalid
+)
+
+// StatusError is an upstream answer whose status code the provider has no
+// specific meaning for. It matches ErrUnavailable for 5xx and 429, and
+// ErrRejected for any other status.
+type StatusError struct {
+	Upstream string
+	Code     int
+	Status   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded %s", e.Upstream, e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	unavailable := e.Code >= http.StatusInternalServerError || e.Code == http.StatusTooManyRequests
+	switch target {
+	case ErrUnavailable:
+		return unavailable
+	case ErrRejected:
+		return !unavailable
+	}
+	return false
+}
+
+// NewStatusError reports resp, an answer from upstream, as a StatusError.
+func NewStatusError(upstream string, resp *http.Response) *StatusError {
+	return &StatusError{Upstream: upstream, Code: resp.StatusCode, Status: resp.Status}
+}
+
+// CEPProvider resolves a canonical 8-digit CEP to a city name.
+type CEPProvider interface {
+	Name() string
+	Locate(ctx context.Context, cep string) (string, error)
+}
+
+// Address is what a CEP resolves to. Providers fill in what their upstream
+// knows; only City is guaranteed.
+type Address struct {
+	City string
+	// UF is the two letter state code.
+	UF string
+	// IBGECode is the 7-digit IBGE municipality code.
+	IBGECode  string
+	Latitude  *float64
+	Longitude *float64
+	// DDD is the telephone area code and SIAFI the municipality code of
+	// the federal accounting system (SIAFI).
+	DDD   string
+	SIAFI string
+	// Source names where the address came from. ResolveAddress sets it to
+	// the name of the provider that answered when the provider does not.
+	Source string
+}
+
+// AddressProvider is implemented by CEP providers that know more about a CEP
+// than its city.
+type AddressProvider interface {
+	CEPProvider
+	Address(ctx context.Context, cep string) (Address, error)
+}
+
+// ResolveAddress resolves cep with p, through Address when p implements
+// AddressProvider.
+func ResolveAddress(ctx context.Context, p CEPProvider, cep string) (Address, error) {
+	var address Address
+	var err error
+	if ap, ok := p.(AddressProvider); ok {
+		address, err = ap.Address(ctx, cep)
+	} else {
+		address.City, err = p.Locate(ctx, cep)
+	}
+	if err == nil && address.Source == "" {
+		address.Source = p.Name()
+	}
+	return address, err
+}
+
+// WeatherProvider returns the current conditions for a location.
+type WeatherProvider interface {
+	Name() string
+	Current(ctx context.Context, location string) (Observation, error)
+}
+
+type Observation struct {
+	TempC float64
+	// WindKph is zero when the provider does not report wind.
+	WindKph float64
+	// AirQuality is nil when the provider does not report air quality.
+	AirQuality *AirQuality
+	// Condition is nil when the provider does not describe the weather.
+	Condition *Condition
+	// Location is the place name the provider resolved the query to, empty
+	// when it does not report one.
+	Location string
+	// Region is the state or region the provider placed Location in, empty
+	// when it does not report one.
+	Region string
+	// TimeZone is the IANA time zone of Location, such as
+	// "America/Sao_Paulo", empty when the provider does not report one.
+	TimeZone string
+	// ObservedAt is the provider's own timestamp when a provider fills it in;
+	// after stamping it is the time the observation is reported with.
+	ObservedAt      time.Time
+	TimestampSource string
+}
+
+// AstronomyProvider is implemented by weather providers that know when the
+// sun and the moon rise and set.
+type AstronomyProvider interface {
+	WeatherProvider
+	// Astronomy reports the events of date, a day in the location's own
+	// calendar.
+	Astronomy(ctx context.Context, location string, date time.Time) (Astronomy, error)
+}
+
+// Astronomy holds the sun and moon events of one day. Times are nil when
+// the event does not happen that day, as with a moon that sets after
+// midnight.
+type Astronomy struct {
+	Sunrise  *time.Time
+	Sunset   *time.Time
+	Moonrise *time.Time
+	Moonset  *time.Time
+	// MoonPhase is a name such as "Waxing Crescent".
+	MoonPhase string
+	// MoonIllumination is the lit share of the moon, in percent.
+	MoonIllumination int
+	// Location and TimeZone are as in Observation.
+	Location string
+	TimeZone string
+}
+
+// Condition describes the weather in words and as an icon, such as "Partly
+// cloudy".
+type Condition struct {
+	// Code is the provider's own condition code.
+	Code    int
+	Text    string
+	IconURL string
+}
+
+type AirQuality struct {
+	PM25 float64
+	PM10 float64
+	// USEPAIndex is the US EPA index, from 1 (good) to 6 (hazardous).
+	USEPAIndex int
+}
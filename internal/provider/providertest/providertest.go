@@ -0,0 +1,230 @@
+// Package providertest is a conformance suite for provider.CEPProvider and
+// provider.WeatherProvider implementations. Adapter tests build a suite and
+// call Run:
+//
+//	func TestViaCEPConformance(t *testing.T) {
+//		providertest.CEPSuite{
+//			New:        func(c *http.Client) provider.CEPProvider { return &viaCEPProvider{client: c} },
+//			Upstream:   fakeViaCEP(),
+//			KnownCEP:   "01001000",
+//			KnownCity:  "São Paulo",
+//			UnknownCEP: "99999999",
+//		}.Run(t)
+//	}
+package providertest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/faultinject"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// unavailableScenarios are the faults that must surface as
+// provider.ErrUnavailable rather than as a missing CEP.
+var unavailableScenarios = map[string]bool{
+	"connection dropped":    true,
+	"internal server error": true,
+	"bad gateway":           true,
+	"service unavailable":   true,
+	"rate limited":          true,
+}
+
+// badResponseScenarios are the faults that must surface as
+// provider.ErrBadResponse rather than as an invalid or missing CEP.
+var badResponseScenarios = map[string]bool{
+	"html error page": true,
+	"malformed json":  true,
+	"empty body":      true,
+}
+
+// CEPSuite checks a provider.CEPProvider against the shared contract.
+type CEPSuite struct {
+	// New builds the provider under test; it must send every upstream
+	// request through client.
+	New func(client *http.Client) provider.CEPProvider
+	// Upstream answers the provider's requests for the fixtures below, for
+	// example a transport rewriting requests to an httptest server.
+	Upstream   http.RoundTripper
+	KnownCEP   string
+	KnownCity  string
+	UnknownCEP string
+}
+
+func (s CEPSuite) Run(t *testing.T) {
+	t.Helper()
+
+	newProvider := func(rt http.RoundTripper) provider.CEPProvider {
+		return s.New(&http.Client{Transport: rt, Timeout: 5 * time.Second})
+	}
+
+	t.Run("name", func(t *testing.T) {
+		if newProvider(s.Upstream).Name() == "" {
+			t.Fatal("Name() must not be empty")
+		}
+	})
+
+	t.Run("known cep", func(t *testing.T) {
+		spans := recordSpans(t)
+		city, err := newProvider(s.Upstream).Locate(context.Background(), s.KnownCEP)
+		if err != nil {
+			t.Fatalf("Locate(%q) error: %v", s.KnownCEP, err)
+		}
+		if city != s.KnownCity {
+			t.Fatalf("Locate(%q) = %q, want %q", s.KnownCEP, city, s.KnownCity)
+		}
+		requireSpan(t, spans, false)
+	})
+
+	t.Run("unknown cep", func(t *testing.T) {
+		spans := recordSpans(t)
+		_, err := newProvider(s.Upstream).Locate(context.Background(), s.UnknownCEP)
+		if !errors.Is(err, provider.ErrNotFound) {
+			t.Fatalf("Locate(%q) error = %v, want provider.ErrNotFound", s.UnknownCEP, err)
+		}
+		requireSpan(t, spans, true)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := newProvider(s.Upstream).Locate(ctx, s.KnownCEP)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Locate with cancelled context error = %v, want context.Canceled", err)
+		}
+	})
+
+	for _, sc := range faultinject.StandardScenarios() {
+		t.Run("fault/"+sc.Name, func(t *testing.T) {
+			spans := recordSpans(t)
+			rt := faultinject.New(s.Upstream)
+			rt.Inject("", sc.Fault)
+
+			_, err := newProvider(rt).Locate(context.Background(), s.KnownCEP)
+			checkFault(t, sc.Name, err)
+			requireSpan(t, spans, true)
+		})
+	}
+}
+
+// WeatherSuite checks a provider.WeatherProvider against the shared contract.
+type WeatherSuite struct {
+	New             func(client *http.Client) provider.WeatherProvider
+	Upstream        http.RoundTripper
+	KnownLocation   string
+	UnknownLocation string
+}
+
+func (s WeatherSuite) Run(t *testing.T) {
+	t.Helper()
+
+	newProvider := func(rt http.RoundTripper) provider.WeatherProvider {
+		return s.New(&http.Client{Transport: rt, Timeout: 5 * time.Second})
+	}
+
+	t.Run("name", func(t *testing.T) {
+		if newProvider(s.Upstream).Name() == "" {
+			t.Fatal("Name() must not be empty")
+		}
+	})
+
+	t.Run("known location", func(t *testing.T) {
+		spans := recordSpans(t)
+		if _, err := newProvider(s.Upstream).Current(context.Background(), s.KnownLocation); err != nil {
+			t.Fatalf("Current(%q) error: %v", s.KnownLocation, err)
+		}
+		requireSpan(t, spans, false)
+	})
+
+	t.Run("unknown location", func(t *testing.T) {
+		spans := recordSpans(t)
+		_, err := newProvider(s.Upstream).Current(context.Background(), s.UnknownLocation)
+		if !errors.Is(err, provider.ErrNotFound) {
+			t.Fatalf("Current(%q) error = %v, want provider.ErrNotFound", s.UnknownLocation, err)
+		}
+		requireSpan(t, spans, true)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := newProvider(s.Upstream).Current(ctx, s.KnownLocation)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Current with cancelled context error = %v, want context.Canceled", err)
+		}
+	})
+
+	for _, sc := range faultinject.StandardScenarios() {
+		t.Run("fault/"+sc.Name, func(t *testing.T) {
+			spans := recordSpans(t)
+			rt := faultinject.New(s.Upstream)
+			rt.Inject("", sc.Fault)
+
+			_, err := newProvider(rt).Current(context.Background(), s.KnownLocation)
+			checkFault(t, sc.Name, err)
+			requireSpan(t, spans, true)
+		})
+	}
+}
+
+func checkFault(t *testing.T, scenario string, err error) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("%s: expected an error", scenario)
+	}
+	if errors.Is(err, provider.ErrNotFound) {
+		t.Fatalf("%s: upstream failure reported as provider.ErrNotFound: %v", scenario, err)
+	}
+	if unavailableScenarios[scenario] && !errors.Is(err, provider.ErrUnavailable) {
+		t.Fatalf("%s: error = %v, want provider.ErrUnavailable", scenario, err)
+	}
+	if badResponseScenarios[scenario] && !errors.Is(err, provider.ErrBadResponse) {
+		t.Fatalf("%s: error = %v, want provider.ErrBadResponse", scenario, err)
+	}
+}
+
+// recordSpans installs a recording tracer provider for the current test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// requireSpan checks that the provider ended at least one span carrying
+// provider.name, and that a failing call marked one as an error.
+func requireSpan(t *testing.T, recorder *tracetest.SpanRecorder, wantError bool) {
+	t.Helper()
+	ended := recorder.Ended()
+	if len(ended) == 0 {
+		t.Fatal("provider did not record a span")
+	}
+
+	named, errored := false, false
+	for _, span := range ended {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "provider.name" && attr.Value.AsString() != "" {
+				named = true
+			}
+		}
+		if span.Status().Code == codes.Error {
+			errored = true
+		}
+	}
+	if !named {
+		t.Error("no provider span carries the provider.name attribute")
+	}
+	if wantError && !errored {
+		t.Error("failed call did not set an error status on any span")
+	}
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Deps carries what a factory may use to build its provider.
+type Deps struct {
+	Client *http.Client
+	// Getenv reads provider specific settings, usually os.Getenv.
+	Getenv func(key string) string
+}
+
+type CEPFactory func(Deps) (CEPProvider, error)
+
+var (
+	registryMu   sync.RWMutex
+	cepFactories = make(map[string]CEPFactory)
+)
+
+// RegisterCEPProvider makes a CEP provider available under name, so it can
+// be selected through configuration. It is meant to be called from init
+// functions and panics if name is registered twice.
+func RegisterCEPProvider(name string, factory CEPFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("provider: RegisterCEPProvider factory is nil")
+	}
+	if _, dup := cepFactories[name]; dup {
+		panic("provider: RegisterCEPProvider called twice for " + name)
+	}
+	cepFactories[name] = factory
+}
+
+// NewCEPProvider builds the CEP provider registered under name.
+func NewCEPProvider(name string, deps Deps) (CEPProvider, error) {
+	registryMu.RLock()
+	factory, ok := cepFactories[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown CEP provider %q (registered: %v)", name, CEPProviders())
+	}
+	return factory(deps)
+}
+
+// CEPProviders lists the registered CEP provider names.
+func CEPProviders() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(cepFactories))
+	for name := range cepFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
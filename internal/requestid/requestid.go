@@ -0,0 +1,129 @@
+// Package requestid gives every request an X-Request-Id that is returned to
+// the client and carried from Service A to Service B, so a request can be
+// followed through the logs without access to the tracing backend.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const Header = "X-Request-Id"
+
+// maxLength bounds IDs accepted from callers, which end up in every log line
+// and span of the request.
+const maxLength = 128
+
+type contextKey struct{}
+
+// FromContext returns the request ID of ctx, or "" outside a request.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware keeps the caller's X-Request-Id when it is well formed and
+// generates one otherwise, stores it in the request context and echoes it
+// on the response. It must run outside the otelhttp handler for the ID to
+// reach the request span.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = newID()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, id)))
+	})
+}
+
+// Transport sets X-Request-Id on outgoing requests made on behalf of a
+// request that has one.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if id := FromContext(req.Context()); id != "" && req.Header.Get(Header) == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set(Header, id)
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+// throughSlog is set once slog's default logger exports logs, see
+// LogThroughSlog.
+var throughSlog atomic.Bool
+
+// LogThroughSlog makes Logf write through slog's default logger, which
+// passes ctx along so exported records carry the trace and span IDs.
+func LogThroughSlog() { throughSlog.Store(true) }
+
+// Logf is log.Printf prefixed with the request ID of ctx, when it has one,
+// and the authenticated caller found in the enduser.id baggage member.
+func Logf(ctx context.Context, format string, args ...any) {
+	var prefix []string
+	if id := FromContext(ctx); id != "" {
+		prefix = append(prefix, id)
+	}
+	if user := baggage.FromContext(ctx).Member("enduser.id").Value(); user != "" {
+		prefix = append(prefix, "user="+user)
+	}
+	if len(prefix) > 0 {
+		format = "[" + strings.Join(prefix, " ") + "] " + format
+	}
+	if throughSlog.Load() {
+		slog.InfoContext(ctx, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// SpanProcessor sets the request.id attribute on every span started while
+// serving a request.
+type SpanProcessor struct{}
+
+func (SpanProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	if id := FromContext(ctx); id != "" {
+		span.SetAttributes(attribute.String("request.id", id))
+	}
+}
+
+func (SpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (SpanProcessor) Shutdown(context.Context) error   { return nil }
+func (SpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// valid accepts IDs of visible ASCII characters, which are safe to log and
+// to send back in a header.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("requestid: reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
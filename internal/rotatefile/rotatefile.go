@@ -0,0 +1,83 @@
+// Package rotatefile is an append-only file that rotates by size, for JSON
+// lines logs such as the request journal and the audit log.
+package rotatefile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Writer appends to path, rotating it to path.1, path.2, ... once it grows
+// past maxBytes and keeping at most maxFiles rotated files. Each Write is
+// kept whole in one file, so callers write one record at a time.
+type Writer struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func Open(path string, maxBytes int64, maxFiles int) (*Writer, error) {
+	w := &Writer{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", w.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening %s: %w", w.path, err)
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.maxBytes > 0 && w.size+int64(len(p)) > w.maxBytes && w.size > 0 {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("error writing %s: %w", w.path, err)
+	}
+	return n, nil
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("error rotating %s: %w", w.path, err)
+	}
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if w.maxFiles > 0 {
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return fmt.Errorf("error rotating %s: %w", w.path, err)
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return fmt.Errorf("error rotating %s: %w", w.path, err)
+	}
+	return w.open()
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
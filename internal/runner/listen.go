@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+)
+
+// Listen opens the listener of addr, which is one of:
+//
+//	:8080 or 127.0.0.1:8080  a TCP address
+//	unix:/run/cep/a.sock     a Unix socket, created with UNIX_SOCKET_MODE
+//	systemd                  the next socket systemd passed (LISTEN_FDS)
+//	systemd:<name>           the socket systemd passed under FileDescriptorName
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return listenUnix(path)
+	}
+	if addr == "systemd" || strings.HasPrefix(addr, "systemd:") {
+		_, name, _ := strings.Cut(addr, ":")
+		return activated.take(name)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// listenUnix binds a Unix socket at path, replacing a socket left behind by
+// a previous run, and sets its mode.
+func listenUnix(path string) (net.Listener, error) {
+	mode, err := strconv.ParseUint(envconfig.String("UNIX_SOCKET_MODE", "0660"), 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UNIX_SOCKET_MODE: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, fs.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// listenFDsStart is the first descriptor systemd passes.
+const listenFDsStart = 3
+
+// activated holds the sockets systemd passed, read from the environment
+// the first time one is asked for.
+var activated activatedSockets
+
+type activatedSockets struct {
+	once  sync.Once
+	err   error
+	mu    sync.Mutex
+	files []*os.File
+	names []string
+}
+
+// load reads LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES, and unsets them so
+// child processes do not take the sockets for theirs.
+func (a *activatedSockets) load() {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		a.err = errors.New("no sockets passed by systemd (LISTEN_PID is not this process)")
+		return
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		a.err = errors.New("no sockets passed by systemd (LISTEN_FDS)")
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := range n {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		a.files = append(a.files, os.NewFile(uintptr(listenFDsStart+i), name))
+		a.names = append(a.names, name)
+	}
+}
+
+// take hands out the socket called name, or the next one when name is
+// empty. Each socket is handed out once.
+func (a *activatedSockets) take(name string) (net.Listener, error) {
+	a.once.Do(a.load)
+	if a.err != nil {
+		return nil, a.err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, f := range a.files {
+		if f == nil || name != "" && a.names[i] != name {
+			continue
+		}
+		a.files[i] = nil
+		ln, err := net.FileListener(f)
+		// FileListener works on a duplicate of the descriptor.
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %s: %w", a.names[i], err)
+		}
+		return ln, nil
+	}
+	if name != "" {
+		return nil, fmt.Errorf("no unused systemd socket named %q (passed: %v)", name, a.names)
+	}
+	return nil, fmt.Errorf("no unused systemd socket left (passed: %v)", a.names)
+}
@@ -0,0 +1,150 @@
+// Package runner serves a set of HTTP listeners until the process is asked to
+// stop, then drains them.
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+type Listener struct {
+	Name string
+	// Addr is a TCP address, a Unix socket or a systemd socket, as Listen
+	// reads it.
+	Addr    string
+	Handler http.Handler
+	// TLS makes the listener serve HTTPS; nil serves plain HTTP.
+	TLS *tls.Config
+	// OnShutdown, if set, runs when shutdown starts, to end long-lived
+	// responses such as streams that Shutdown would otherwise wait on.
+	OnShutdown func()
+	Limits     Limits
+	// H2C lets a plain HTTP listener also serve HTTP/2 to clients that
+	// speak it with prior knowledge. TLS listeners negotiate HTTP/2 anyway.
+	H2C bool
+}
+
+// Limits bound how long and how much a client can hold a server's
+// resources. Zero fields keep the net/http defaults, which are unlimited.
+type Limits struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxConns caps the open connections of the listener; further clients
+	// wait in the accept queue until one closes.
+	MaxConns int
+}
+
+// LimitsFromEnv reads the HTTP_* limits. Header reads time out after 10s by
+// default so idle half-open requests cannot pile up; body reads and writes
+// are unbounded by default because uploads and streams legitimately last.
+func LimitsFromEnv() Limits {
+	return Limits{
+		ReadHeaderTimeout: envconfig.Duration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       envconfig.Duration("HTTP_READ_TIMEOUT", 0),
+		WriteTimeout:      envconfig.Duration("HTTP_WRITE_TIMEOUT", 0),
+		IdleTimeout:       envconfig.Duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		MaxHeaderBytes:    envconfig.Int("HTTP_MAX_HEADER_BYTES", 64<<10),
+		MaxConns:          envconfig.Int("HTTP_MAX_CONNS", 0),
+	}
+}
+
+// limitListener holds back Accept while max connections are open.
+type limitListener struct {
+	net.Listener
+	slots chan struct{}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	l.slots <- struct{}{}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.slots }}, nil
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// Run starts every listener and blocks until ctx is done, then shuts them all
+// down, giving in-flight requests up to shutdownTimeout to finish.
+func Run(ctx context.Context, shutdownTimeout time.Duration, listeners ...Listener) {
+	servers := make([]*http.Server, 0, len(listeners))
+	for _, l := range listeners {
+		srv := &http.Server{
+			Addr:              l.Addr,
+			Handler:           l.Handler,
+			TLSConfig:         l.TLS,
+			ReadHeaderTimeout: l.Limits.ReadHeaderTimeout,
+			ReadTimeout:       l.Limits.ReadTimeout,
+			WriteTimeout:      l.Limits.WriteTimeout,
+			IdleTimeout:       l.Limits.IdleTimeout,
+			MaxHeaderBytes:    l.Limits.MaxHeaderBytes,
+		}
+		if l.H2C && l.TLS == nil {
+			srv.Handler = h2c.NewHandler(l.Handler, &http2.Server{IdleTimeout: l.Limits.IdleTimeout})
+		}
+		if l.OnShutdown != nil {
+			srv.RegisterOnShutdown(l.OnShutdown)
+		}
+		servers = append(servers, srv)
+		go func(name string, maxConns int) {
+			ln, err := Listen(srv.Addr)
+			if err != nil {
+				log.Fatalf("Error starting %s: %s\n", name, err)
+			}
+			if maxConns > 0 {
+				ln = &limitListener{Listener: ln, slots: make(chan struct{}, maxConns)}
+			}
+			if srv.TLSConfig != nil {
+				fmt.Printf("%s listening on %s (TLS)\n", name, srv.Addr)
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				fmt.Printf("%s listening on %s\n", name, srv.Addr)
+				err = srv.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Error starting %s: %s\n", name, err)
+			}
+		}(l.Name, l.Limits.MaxConns)
+	}
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, srv := range servers {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Failed to shutdown %s: %v", name, err)
+			}
+		}(listeners[i].Name)
+	}
+	wg.Wait()
+}
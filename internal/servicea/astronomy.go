@@ -0,0 +1,40 @@
+package servicea
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/journal"
+)
+
+// weatherViews routes GET /weather/{cep}/{view} by view. The routes cannot
+// be registered as /weather/{cep}/stream and so on, which would conflict
+// with /weather/jobs/{id}.
+type weatherViews map[string]http.Handler
+
+func (v weatherViews) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := v[r.PathValue("view")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// handleAstronomy forwards GET /weather/{cep}/astronomy, with its ?date=,
+// to Service B.
+func (s *server) handleAstronomy(w http.ResponseWriter, r *http.Request) {
+	normalizedCEP, err := s.cepPolicy.Normalize(r.PathValue("cep"))
+	if err != nil {
+		i18n.Error(w, r, http.StatusUnprocessableEntity, "invalid zipcode")
+		return
+	}
+	journal.SetCEP(r.Context(), normalizedCEP)
+
+	query := url.Values{}
+	if date := r.URL.Query().Get("date"); date != "" {
+		query.Set("date", date)
+	}
+	s.forward(w, r, s.astronomyProxy, "/weather/"+normalizedCEP+"/astronomy", query)
+}
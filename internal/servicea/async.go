@@ -0,0 +1,147 @@
+package servicea
+
+import (
+	"container/list"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/asyncjobs"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/callbackurl"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/journal"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const pendingJobRetention = time.Hour
+
+type AsyncLookupRequest struct {
+	CEP         string `json:"cep"`
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// pendingJobs remembers jobs this instance enqueued, so their status can be
+// reported before Service B picks them up. order holds the jobs oldest
+// first, so expired ones are dropped from its front.
+type pendingJobs struct {
+	mu    sync.Mutex
+	jobs  map[string]time.Time
+	order *list.List
+}
+
+type pendingJob struct {
+	id         string
+	enqueuedAt time.Time
+}
+
+func newPendingJobs() *pendingJobs {
+	return &pendingJobs{jobs: make(map[string]time.Time), order: list.New()}
+}
+
+func (p *pendingJobs) add(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.jobs[id] = now
+	p.order.PushBack(pendingJob{id: id, enqueuedAt: now})
+	for oldest := p.order.Front(); oldest != nil; oldest = p.order.Front() {
+		job := oldest.Value.(pendingJob)
+		if now.Sub(job.enqueuedAt) <= pendingJobRetention {
+			break
+		}
+		p.order.Remove(oldest)
+		delete(p.jobs, job.id)
+	}
+}
+
+func (p *pendingJobs) has(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.jobs[id]
+	return ok
+}
+
+func (s *server) handleAsyncLookup(w http.ResponseWriter, r *http.Request) {
+	span := trace.SpanFromContext(r.Context())
+
+	var req AsyncLookupRequest
+	if !s.decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	normalizedCEP, err := s.cepPolicy.Normalize(req.CEP)
+	if err != nil {
+		i18n.Error(w, r, http.StatusUnprocessableEntity, "invalid zipcode")
+		return
+	}
+	journal.SetCEP(r.Context(), normalizedCEP)
+
+	if req.CallbackURL != "" && !callbackurl.Valid(req.CallbackURL) {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: callback_url must be an absolute http(s) URL")
+		return
+	}
+
+	job := asyncjobs.LookupRequest{
+		JobID:       asyncjobs.NewJobID(),
+		CEP:         normalizedCEP,
+		CallbackURL: req.CallbackURL,
+		EnqueuedAt:  time.Now().UTC(),
+	}
+	if err := s.publisher.Publish(r.Context(), job); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to enqueue lookup")
+		i18n.Error(w, r, http.StatusServiceUnavailable, "Service Unavailable: could not enqueue lookup")
+		return
+	}
+	s.pending.add(job.JobID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/weather/jobs/"+job.JobID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(asyncjobs.Job{ID: job.JobID, Status: asyncjobs.StatusPending, UpdatedAt: job.EnqueuedAt}); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// handleJobStatus asks Service B for the job state, answering "pending"
+// for jobs enqueued here that B has not picked up yet.
+func (s *server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	id := r.PathValue("id")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.serviceBURLFor(ctx, "/weather/jobs/"+url.PathEscape(id)), nil)
+	if err != nil {
+		i18n.Error(w, r, http.StatusInternalServerError, "Internal Server Error: Failed to create request to Service B: %v", err)
+		return
+	}
+	req.Header.Set("Accept-Language", i18n.Language(r))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to reach Service B")
+		i18n.Error(w, r, http.StatusInternalServerError, "Internal Server Error: Failed to reach Service B: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && s.pending.has(id) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(asyncjobs.Job{ID: id, Status: asyncjobs.StatusPending}); err != nil {
+			log.Printf("Error encoding JSON response: %v\n", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("Error copying response body from Service B: %v\n", err)
+	}
+}
@@ -0,0 +1,26 @@
+package servicea
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingJobsDropExpiredJobs(t *testing.T) {
+	p := newPendingJobs()
+	expired := time.Now().Add(-pendingJobRetention - time.Minute)
+	for _, id := range []string{"job-1", "job-2"} {
+		p.jobs[id] = expired
+		p.order.PushBack(pendingJob{id: id, enqueuedAt: expired})
+	}
+
+	p.add("job-3")
+	if p.has("job-1") || p.has("job-2") {
+		t.Error("expired jobs are still pending")
+	}
+	if !p.has("job-3") {
+		t.Error("job-3 is not pending")
+	}
+	if len(p.jobs) != 1 || p.order.Len() != 1 {
+		t.Errorf("kept %d jobs (%d in order), want 1", len(p.jobs), p.order.Len())
+	}
+}
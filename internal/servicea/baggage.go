@@ -0,0 +1,63 @@
+package servicea
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/jwtauth"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tenants"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	baggageClientID = "client.id"
+	baggageTenant   = "tenant"
+	baggageEndUser  = "enduser.id"
+)
+
+// callerBaggage identifies the caller in the request baggage, which the
+// instrumented client propagates to Service B. Values sent by the client
+// under the same keys are replaced, so they cannot be spoofed. API keys are
+// hashed before they leave the process. With tenants configured, the tenant
+// is the one owning the API key rather than X-Tenant-ID, and with bearer
+// authentication the token's subject is the end user.
+func callerBaggage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		clientID := clientKey(r)
+		if key, ok := strings.CutPrefix(clientID, "key:"); ok {
+			clientID = "key:" + hashParts(key)[:16]
+		}
+		tenant := r.Header.Get("X-Tenant-ID")
+		if t, ok := tenants.FromContext(ctx); ok {
+			tenant = t.Name
+		}
+		var endUser string
+		if claims, ok := jwtauth.FromContext(ctx); ok {
+			endUser = claims.Subject
+		}
+		values := map[string]string{baggageClientID: clientID, baggageTenant: tenant, baggageEndUser: endUser}
+
+		bag := baggage.FromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		for key, value := range values {
+			bag = bag.DeleteMember(key)
+			if value == "" {
+				continue
+			}
+			member, err := baggage.NewMemberRaw(key, value)
+			if err == nil {
+				bag, err = bag.SetMember(member)
+			}
+			if err != nil {
+				log.Printf("Failed to set %s baggage: %v\n", key, err)
+				continue
+			}
+			span.SetAttributes(attribute.String(key, value))
+		}
+		next.ServeHTTP(w, r.WithContext(baggage.ContextWithBaggage(ctx, bag)))
+	})
+}
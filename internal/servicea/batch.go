@@ -0,0 +1,184 @@
+package servicea
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type BatchRequest struct {
+	CEPs []string `json:"ceps"`
+}
+
+// BatchResponse reports every item of a batch in request order. Each item
+// carries either a result or an error, so partial failures can be retried
+// item by item.
+type BatchResponse struct {
+	Items  []BatchItem `json:"items"`
+	Counts BatchCounts `json:"counts"`
+}
+
+type BatchItem struct {
+	CEP    string          `json:"cep"`
+	Status int             `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *ErrorDetail    `json:"error,omitempty"`
+}
+
+type BatchCounts struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Retryable int `json:"retryable"`
+}
+
+func (s *server) handleBatchLookup(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if !s.decodeJSONBody(w, r, &req) {
+		return
+	}
+	lang := i18n.Language(r)
+	async := s.batchAsyncThreshold > 0 && len(req.CEPs) > s.batchAsyncThreshold
+	maxSize := s.batchMaxSize
+	if async {
+		maxSize = s.batchJobMaxSize
+	}
+	if len(req.CEPs) == 0 || len(req.CEPs) > maxSize {
+		writeErrorEnvelope(w, r, http.StatusBadRequest, ErrorDetail{
+			Code:    "invalid_batch_size",
+			Message: i18n.T(lang, "ceps must hold between 1 and %d entries", maxSize),
+		})
+		return
+	}
+	if async {
+		s.handleBatchJobLookup(w, r, req.CEPs)
+		return
+	}
+
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(attribute.Int("batch.size", len(req.CEPs)))
+
+	resp := BatchResponse{Items: make([]BatchItem, len(req.CEPs))}
+	slots := make(chan struct{}, s.batchConcurrency)
+	var wg sync.WaitGroup
+	for i, rawCEP := range req.CEPs {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			resp.Items[i] = s.lookupBatchItem(r.Context(), rawCEP, serviceBQuery(r).Encode(), lang)
+		}()
+	}
+	wg.Wait()
+
+	resp.Counts = countBatchItems(resp.Items)
+	span.SetAttributes(
+		attribute.Int("batch.succeeded", resp.Counts.Succeeded),
+		attribute.Int("batch.failed", resp.Counts.Failed),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// countBatchItems totals items, leaving unresolved ones (status 0) out of
+// the succeeded and failed counts.
+func countBatchItems(items []BatchItem) BatchCounts {
+	counts := BatchCounts{Total: len(items)}
+	for _, item := range items {
+		switch {
+		case item.Status == 0:
+		case item.Error == nil:
+			counts.Succeeded++
+		default:
+			counts.Failed++
+			if item.Error.Retryable {
+				counts.Retryable++
+			}
+		}
+	}
+	return counts
+}
+
+func (s *server) lookupBatchItem(ctx context.Context, rawCEP, query, lang string) BatchItem {
+	item := BatchItem{CEP: rawCEP}
+	normalizedCEP, err := s.cepPolicy.Normalize(rawCEP)
+	if err != nil {
+		item.Status = http.StatusUnprocessableEntity
+		item.Error = &ErrorDetail{Code: "invalid_zipcode", Message: i18n.T(lang, "invalid zipcode")}
+		return item
+	}
+	item.CEP = normalizedCEP
+
+	ctx, span := otel.Tracer("service-a/handler").Start(ctx, "call-service-b", trace.WithAttributes(
+		attribute.String("cep", normalizedCEP),
+	))
+	defer span.End()
+
+	targetURL := s.serviceBURLFor(ctx, "/weather/"+normalizedCEP)
+	if query != "" {
+		targetURL += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err == nil {
+		req.Header.Set("Accept-Language", lang)
+		var resp *http.Response
+		if resp, err = s.client.Do(req); err == nil {
+			defer resp.Body.Close()
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			var body []byte
+			if body, err = io.ReadAll(resp.Body); err == nil {
+				item.Status = resp.StatusCode
+				if resp.StatusCode == http.StatusOK {
+					if err := checkWeatherBody(ctx, req.URL.Query(), body); err != nil {
+						item.Status = http.StatusBadGateway
+						item.Error = &ErrorDetail{Code: "upstream_error", Message: i18n.T(lang, "Bad Gateway: malformed response from Service B: %v", err), Retryable: true}
+						return item
+					}
+					item.Result = body
+					return item
+				}
+				item.Error = batchError(resp.StatusCode, strings.TrimSpace(string(body)))
+				span.SetStatus(codes.Error, item.Error.Code)
+				return item
+			}
+		}
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "failed to reach Service B")
+	item.Status = http.StatusBadGateway
+	item.Error = &ErrorDetail{Code: "service_unavailable", Message: i18n.T(lang, "Failed to reach Service B: %v", err), Retryable: true}
+	return item
+}
+
+// batchError types a failed Service B answer. Only rate limiting and server
+// side failures are worth retrying.
+func batchError(status int, message string) *ErrorDetail {
+	switch {
+	case status == http.StatusNotFound:
+		return &ErrorDetail{Code: "not_found", Message: message}
+	case status == http.StatusUnprocessableEntity:
+		return &ErrorDetail{Code: "invalid_zipcode", Message: message}
+	case status == http.StatusTooManyRequests:
+		return &ErrorDetail{Code: "rate_limited", Message: message, Retryable: true}
+	case status >= http.StatusInternalServerError:
+		return &ErrorDetail{Code: "upstream_error", Message: message, Retryable: true}
+	}
+	return &ErrorDetail{Code: "bad_request", Message: message}
+}
@@ -0,0 +1,417 @@
+package servicea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/asyncjobs"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// batchJobCheckpoint is how many resolved items a running job collects
+// before it is saved again. A restart repeats at most that many lookups.
+const batchJobCheckpoint = 100
+
+const (
+	defaultBatchJobPageSize = 100
+	maxBatchJobPageSize     = 1000
+)
+
+// BatchJob is a batch too large to answer synchronously, as returned by
+// GET /weather/batch/jobs/{id}. Items holds one page, in request order;
+// items not resolved yet have status 0.
+type BatchJob struct {
+	ID        string           `json:"job_id"`
+	Status    string           `json:"status"`
+	Progress  BatchJobProgress `json:"progress"`
+	Counts    BatchCounts      `json:"counts"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+
+	Items    []BatchItem `json:"items,omitempty"`
+	Page     int         `json:"page,omitempty"`
+	PageSize int         `json:"page_size,omitempty"`
+	NextPage int         `json:"next_page,omitempty"`
+}
+
+type BatchJobProgress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+}
+
+// batchJob is the stored state of a job: everything needed to resume it.
+type batchJob struct {
+	ID     string `json:"job_id"`
+	Status string `json:"status"`
+	Query  string `json:"query,omitempty"`
+	Lang   string `json:"lang"`
+	// Version is the API version the client asked for, zero for none.
+	Version   int         `json:"version,omitempty"`
+	Items     []BatchItem `json:"items"`
+	Completed int         `json:"completed"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// batchJobStore keeps batch jobs in memory and, when dir is set, one JSON
+// file per job, so a restart neither loses finished jobs nor unfinished
+// ones. Finished jobs are dropped after retention.
+type batchJobStore struct {
+	dir       string
+	retention time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*batchJob
+}
+
+// openBatchJobStore loads the jobs saved in dir, which is created if needed.
+func openBatchJobStore(dir string, retention time.Duration) (*batchJobStore, error) {
+	s := &batchJobStore{dir: dir, retention: retention, jobs: make(map[string]*batchJob)}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error opening batch jobs: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error opening batch jobs: %w", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening batch jobs: %w", err)
+		}
+		var job batchJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Printf("Skipping unreadable batch job %s: %v\n", path, err)
+			continue
+		}
+		s.jobs[job.ID] = &job
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	return s, nil
+}
+
+func (s *batchJobStore) create(job *batchJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.jobs[job.ID] = job
+	return s.save(job)
+}
+
+// unfinished lists the jobs a previous run left half done.
+func (s *batchJobStore) unfinished() []*batchJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*batchJob
+	for _, job := range s.jobs {
+		if job.Status != asyncjobs.StatusDone {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// pending lists the positions of the items of job still to be resolved.
+func (s *batchJobStore) pending(job *batchJob) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var positions []int
+	for i, item := range job.Items {
+		if item.Status == 0 {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+func (s *batchJobStore) setStatus(job *batchJob, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Status, job.UpdatedAt = status, time.Now().UTC()
+	return s.save(job)
+}
+
+func (s *batchJobStore) complete(job *batchJob, i int, item BatchItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Items[i] = item
+	job.Completed++
+	job.UpdatedAt = time.Now().UTC()
+	if job.Completed%batchJobCheckpoint != 0 {
+		return nil
+	}
+	return s.save(job)
+}
+
+// replace sets item i of the job with id to item, reporting whether the
+// job is still kept.
+func (s *batchJobStore) replace(id string, i int, item BatchItem) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || i >= len(job.Items) {
+		return false, nil
+	}
+	job.Items[i] = item
+	job.UpdatedAt = time.Now().UTC()
+	return true, s.save(job)
+}
+
+// page returns job with the items of page, or false when it is unknown. A
+// zero pageSize leaves the items out.
+func (s *batchJobStore) page(id string, page, pageSize int) (BatchJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return BatchJob{}, false
+	}
+	view := BatchJob{
+		ID:        job.ID,
+		Status:    job.Status,
+		Progress:  BatchJobProgress{Total: len(job.Items), Completed: job.Completed},
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		Counts:    countBatchItems(job.Items),
+	}
+	if pageSize == 0 {
+		return view, true
+	}
+	view.Page, view.PageSize = page, pageSize
+	start := min((page-1)*pageSize, len(job.Items))
+	end := min(start+pageSize, len(job.Items))
+	view.Items = append([]BatchItem(nil), job.Items[start:end]...)
+	if end < len(job.Items) {
+		view.NextPage = page + 1
+	}
+	return view, true
+}
+
+// prune drops finished jobs past their retention. The caller holds s.mu.
+func (s *batchJobStore) prune() {
+	for id, job := range s.jobs {
+		if job.Status == asyncjobs.StatusDone && time.Since(job.UpdatedAt) > s.retention {
+			delete(s.jobs, id)
+			if s.dir != "" {
+				os.Remove(filepath.Join(s.dir, id+".json"))
+			}
+		}
+	}
+}
+
+// save writes job to a temporary file and renames it into place, so a crash
+// leaves either the previous checkpoint or the new one. The caller holds
+// s.mu.
+func (s *batchJobStore) save(job *batchJob) error {
+	if s.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error encoding batch job: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".job-*")
+	if err != nil {
+		return fmt.Errorf("error saving batch job: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving batch job: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving batch job: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, job.ID+".json")); err != nil {
+		return fmt.Errorf("error saving batch job: %w", err)
+	}
+	return nil
+}
+
+// batchJobRunner resolves batch jobs in the background until closed.
+type batchJobRunner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBatchJobRunner() *batchJobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &batchJobRunner{ctx: ctx, cancel: cancel}
+}
+
+// close stops the running jobs, which save their progress so the next run
+// picks them up.
+func (r *batchJobRunner) close() error {
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
+
+// startBatchJob resolves the pending items of job. parent carries the
+// request's trace and baggage, without its cancellation.
+func (s *server) startBatchJob(parent context.Context, job *batchJob) {
+	s.batchJobRunner.wg.Add(1)
+	go func() {
+		defer s.batchJobRunner.wg.Done()
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+		stop := context.AfterFunc(s.batchJobRunner.ctx, cancel)
+		defer stop()
+		s.runBatchJob(ctx, job)
+	}()
+}
+
+func (s *server) runBatchJob(ctx context.Context, job *batchJob) {
+	if job.Version != 0 {
+		ctx = apiversion.NewContext(ctx, job.Version)
+	}
+	positions := s.batchJobs.pending(job)
+	ctx, span := otel.Tracer("service-a/handler").Start(ctx, "batch-job", trace.WithAttributes(
+		attribute.String("batch.job.id", job.ID),
+		attribute.Int("batch.size", len(job.Items)),
+		attribute.Int("batch.pending", len(positions)),
+	))
+	defer span.End()
+
+	if err := s.batchJobs.setStatus(job, asyncjobs.StatusProcessing); err != nil {
+		log.Printf("Failed to save batch job %s: %v\n", job.ID, err)
+	}
+	slots := make(chan struct{}, s.batchConcurrency)
+	var wg sync.WaitGroup
+	for _, i := range positions {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			item := s.lookupBatchItem(ctx, job.Items[i].CEP, job.Query, job.Lang)
+			if ctx.Err() != nil {
+				// Cut short by shutdown: leave it for the next run.
+				return
+			}
+			if err := s.batchJobs.complete(job, i, item); err != nil {
+				log.Printf("Failed to save batch job %s: %v\n", job.ID, err)
+			}
+			if item.Error != nil && item.Error.Retryable && s.outbox != nil {
+				s.deferBatchItem(ctx, job, i, item)
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := asyncjobs.StatusDone
+	if ctx.Err() != nil {
+		status = asyncjobs.StatusPending
+	}
+	if err := s.batchJobs.setStatus(job, status); err != nil {
+		log.Printf("Failed to save batch job %s: %v\n", job.ID, err)
+	}
+}
+
+// handleBatchJobLookup enqueues a large batch as a job and answers 202 with
+// where to follow it.
+func (s *server) handleBatchJobLookup(w http.ResponseWriter, r *http.Request, ceps []string) {
+	lang := i18n.Language(r)
+	now := time.Now().UTC()
+	job := &batchJob{
+		ID:        asyncjobs.NewJobID(),
+		Status:    asyncjobs.StatusPending,
+		Query:     serviceBQuery(r).Encode(),
+		Lang:      lang,
+		Items:     make([]BatchItem, len(ceps)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if v, ok := apiversion.FromContext(r.Context()); ok {
+		job.Version = v
+	}
+	for i, rawCEP := range ceps {
+		job.Items[i].CEP = rawCEP
+	}
+	if err := s.batchJobs.create(job); err != nil {
+		log.Printf("Failed to save batch job %s: %v\n", job.ID, err)
+		writeErrorEnvelope(w, r, http.StatusServiceUnavailable, ErrorDetail{
+			Code:      "job_not_saved",
+			Message:   i18n.T(lang, "Service Unavailable: could not save batch job"),
+			Retryable: true,
+		})
+		return
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("batch.job.id", job.ID))
+	s.startBatchJob(context.WithoutCancel(r.Context()), job)
+
+	view, _ := s.batchJobs.page(job.ID, 0, 0)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Location", "/weather/batch/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// handleBatchJobStatus serves GET /weather/batch/jobs/{id}, with ?page= and
+// ?page_size= choosing the slice of items returned.
+func (s *server) handleBatchJobStatus(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.Language(r)
+	page, pageErr := positiveQueryInt(r, "page", 1)
+	pageSize, sizeErr := positiveQueryInt(r, "page_size", defaultBatchJobPageSize)
+	if pageErr != nil || sizeErr != nil {
+		writeErrorEnvelope(w, r, http.StatusBadRequest, ErrorDetail{
+			Code:    "invalid_page",
+			Message: i18n.T(lang, "page and page_size must be positive integers"),
+		})
+		return
+	}
+	job, ok := s.batchJobs.page(r.PathValue("id"), page, min(pageSize, maxBatchJobPageSize))
+	if !ok {
+		writeErrorEnvelope(w, r, http.StatusNotFound, ErrorDetail{
+			Code:    "job_not_found",
+			Message: i18n.T(lang, "batch job not found"),
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+func positiveQueryInt(r *http.Request, name string, fallback int) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(name))
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err == nil && n < 1 {
+		err = errors.New(name + " must be positive")
+	}
+	return n, err
+}
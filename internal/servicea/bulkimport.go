@@ -0,0 +1,235 @@
+package servicea
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ImportItem is one line of the POST /weather/import response: a BatchItem
+// tagged with the input line it came from, since items are streamed back as
+// soon as they are resolved rather than in input order. Errors about the
+// body as a whole carry no line.
+type ImportItem struct {
+	Line int `json:"line,omitempty"`
+	BatchItem
+}
+
+// Import progress is reported in these trailers once every row is done.
+const (
+	trailerImportTotal     = "X-Import-Total"
+	trailerImportSucceeded = "X-Import-Succeeded"
+	trailerImportFailed    = "X-Import-Failed"
+)
+
+type importRow struct {
+	line int
+	cep  string
+	err  *ErrorDetail
+}
+
+// handleImport serves POST /weather/import. The body is CSV (a "cep" column,
+// or the first column without a header) or NDJSON (one {"cep": "..."} object
+// or JSON string per line). Rows are read as they arrive, resolved by
+// importConcurrency workers and written back as NDJSON, flushing each line.
+func (s *server) handleImport(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var readRows func(context.Context, io.Reader, chan<- importRow) error
+	switch mediaType {
+	case "text/csv":
+		readRows = readCSVRows
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		readRows = readNDJSONRows
+	default:
+		writeErrorEnvelope(w, r, http.StatusUnsupportedMediaType, ErrorDetail{
+			Code:    "unsupported_media_type",
+			Message: i18n.T(i18n.Language(r), "Content-Type must be text/csv or application/x-ndjson"),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	lang := i18n.Language(r)
+	query := serviceBQuery(r).Encode()
+	span := trace.SpanFromContext(ctx)
+
+	// Rows are read while results are written, which HTTP/1.x servers only
+	// allow once asked to.
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		requestid.Logf(ctx, "Failed to enable full duplex for import: %v\n", err)
+	}
+
+	rows := make(chan importRow)
+	readDone := make(chan error, 1)
+	go func() {
+		defer close(rows)
+		readDone <- readRows(ctx, http.MaxBytesReader(w, r.Body, s.importMaxBytes), rows)
+	}()
+
+	items := make(chan ImportItem)
+	var wg sync.WaitGroup
+	for range s.importConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range rows {
+				item := ImportItem{Line: row.line}
+				if row.err != nil {
+					item.BatchItem = BatchItem{CEP: row.cep, Status: http.StatusBadRequest, Error: row.err}
+				} else {
+					item.BatchItem = s.lookupBatchItem(ctx, row.cep, query, lang)
+				}
+				select {
+				case items <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(items)
+	}()
+
+	w.Header().Set("Trailer", strings.Join([]string{trailerImportTotal, trailerImportSucceeded, trailerImportFailed}, ", "))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(http.StatusOK)
+
+	var counts BatchCounts
+	enc := json.NewEncoder(w)
+	for item := range items {
+		if err := enc.Encode(item); err != nil {
+			// The client is gone: stop the workers and the reader.
+			span.RecordError(err)
+			cancel()
+			for range items {
+			}
+			return
+		}
+		rc.Flush()
+		counts.Total++
+		if item.Error == nil {
+			counts.Succeeded++
+		} else {
+			counts.Failed++
+		}
+	}
+
+	if err := <-readDone; err != nil {
+		// The rows read so far were resolved; report why the rest were not.
+		detail := ErrorDetail{Code: "malformed_body", Message: i18n.T(lang, "Import stopped: %v", err)}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			detail = ErrorDetail{Code: "body_too_large", Message: i18n.T(lang, "Request body must not exceed %d bytes", maxBytesErr.Limit)}
+		}
+		counts.Failed++
+		if err := enc.Encode(ImportItem{BatchItem: BatchItem{Status: http.StatusBadRequest, Error: &detail}}); err != nil {
+			span.RecordError(err)
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("import.total", counts.Total),
+		attribute.Int("import.succeeded", counts.Succeeded),
+		attribute.Int("import.failed", counts.Failed),
+	)
+	w.Header().Set(trailerImportTotal, strconv.Itoa(counts.Total))
+	w.Header().Set(trailerImportSucceeded, strconv.Itoa(counts.Succeeded))
+	w.Header().Set(trailerImportFailed, strconv.Itoa(counts.Failed))
+}
+
+// readCSVRows sends the CEPs of a CSV body to rows. A first record holding
+// a "cep" field is taken as the header.
+func readCSVRows(ctx context.Context, body io.Reader, rows chan<- importRow) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	column := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var row importRow
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			row.line = parseErr.Line
+		} else if err == nil {
+			row.line, _ = reader.FieldPos(0)
+		}
+		switch {
+		case parseErr != nil:
+			row.err = &ErrorDetail{Code: "malformed_row", Message: parseErr.Err.Error()}
+		case err != nil:
+			return err
+		case row.line == 1 && headerColumn(record) >= 0:
+			column = headerColumn(record)
+			continue
+		case column >= len(record):
+			row.err = &ErrorDetail{Code: "malformed_row", Message: "missing cep column"}
+		default:
+			row.cep = strings.TrimSpace(record[column])
+		}
+		select {
+		case rows <- row:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func headerColumn(record []string) int {
+	for i, field := range record {
+		if strings.EqualFold(strings.TrimSpace(field), "cep") {
+			return i
+		}
+	}
+	return -1
+}
+
+// readNDJSONRows sends the CEPs of an NDJSON body to rows, skipping blank
+// lines.
+func readNDJSONRows(ctx context.Context, body io.Reader, rows chan<- importRow) error {
+	scanner := bufio.NewScanner(body)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := importRow{line: line}
+		if strings.HasPrefix(text, `"`) {
+			if err := json.Unmarshal([]byte(text), &row.cep); err != nil {
+				row.err = &ErrorDetail{Code: "malformed_row", Message: err.Error()}
+			}
+		} else {
+			var req CEPRequest
+			if err := json.Unmarshal([]byte(text), &req); err != nil {
+				row.err = &ErrorDetail{Code: "malformed_row", Message: err.Error()}
+			}
+			row.cep = req.CEP
+		}
+		select {
+		case rows <- row:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
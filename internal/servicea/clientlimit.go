@@ -0,0 +1,85 @@
+package servicea
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// clientLimiter caps in-flight requests per client, identified by X-API-Key
+// or, for anonymous callers, by remote IP. Anonymous callers without a
+// remote IP, as over a Unix socket, are not capped. A max of zero disables
+// it.
+type clientLimiter struct {
+	max int
+
+	mu       sync.Mutex
+	inflight map[string]int
+}
+
+func newClientLimiter(max int) *clientLimiter {
+	return &clientLimiter{max: max, inflight: make(map[string]int)}
+}
+
+// clientKey identifies the caller, or returns "" when it cannot. Requests
+// over a Unix socket carry no remote IP; counting them together would cap
+// every client behind a local proxy at max.
+func clientKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return "key:" + key
+	}
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	return "ip:" + addrPort.Addr().Unmap().String()
+}
+
+func (l *clientLimiter) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[key] >= l.max {
+		return false
+	}
+	l.inflight[key]++
+	return true
+}
+
+func (l *clientLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[key] <= 1 {
+		delete(l.inflight, key)
+		return
+	}
+	l.inflight[key]--
+}
+
+func (l *clientLimiter) middleware(next http.Handler) http.Handler {
+	if l.max <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !l.acquire(key) {
+			trace.SpanFromContext(r.Context()).AddEvent("client concurrency limit exceeded",
+				trace.WithAttributes(attribute.Int("client.max_concurrent", l.max)))
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set("X-Concurrency-Limit", strconv.Itoa(l.max))
+			i18n.Error(w, r, http.StatusTooManyRequests, "Too Many Requests: concurrent request limit exceeded")
+			return
+		}
+		defer l.release(key)
+
+		next.ServeHTTP(w, r)
+	})
+}
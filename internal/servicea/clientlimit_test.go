@@ -0,0 +1,65 @@
+package servicea
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		remoteAddr string
+		want       string
+	}{
+		{name: "API key", apiKey: "k1", remoteAddr: "192.0.2.1:4567", want: "key:k1"},
+		{name: "IPv4", remoteAddr: "192.0.2.1:4567", want: "ip:192.0.2.1"},
+		{name: "mapped IPv4", remoteAddr: "[::ffff:192.0.2.1]:4567", want: "ip:192.0.2.1"},
+		{name: "unix socket", remoteAddr: "@", want: ""},
+		{name: "unix socket with an API key", apiKey: "k1", remoteAddr: "@", want: "key:k1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if got := clientKey(r); got != tt.want {
+				t.Errorf("clientKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientLimiterSkipsCallersWithoutAnAddress(t *testing.T) {
+	l := newClientLimiter(1)
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := l.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "@"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	for range 2 {
+		go func() { done <- serve() }()
+	}
+	// Both requests reach the handler at once, over a limit of one.
+	<-entered
+	<-entered
+	close(release)
+	for range 2 {
+		if w := <-done; w.Code != http.StatusOK {
+			t.Errorf("answered %d, want 200", w.Code)
+		}
+	}
+}
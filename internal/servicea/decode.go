@@ -0,0 +1,104 @@
+package servicea
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorResponse is the envelope request bodies are rejected with.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+	// RequestID is the X-Request-Id of the rejected request.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+var errTrailingData = errors.New("unexpected data after the JSON object")
+
+// decodeJSONBody strictly decodes a single JSON object from the request body
+// into dst: the body is capped at s.maxBodyBytes, unknown fields and
+// trailing data are rejected. On failure it writes a 413 or 400 error
+// envelope, records the reason on the span and returns false.
+func (s *server) decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var maxBytesErr *http.MaxBytesError
+	err := dec.Decode(dst)
+	if err == nil {
+		if err = dec.Decode(&struct{}{}); err == io.EOF {
+			return true
+		} else if !errors.As(err, &maxBytesErr) {
+			err = errTrailingData
+		}
+	}
+
+	lang := i18n.Language(r)
+	status, code, message := http.StatusBadRequest, "malformed_json", i18n.T(lang, "Malformed JSON")
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		status, code = http.StatusRequestEntityTooLarge, "body_too_large"
+		message = i18n.T(lang, "Request body must not exceed %d bytes", maxBytesErr.Limit)
+	case errors.Is(err, errTrailingData):
+		message = i18n.T(lang, "Request body must contain a single JSON object")
+	case errors.Is(err, io.EOF):
+		code, message = "empty_body", i18n.T(lang, "Request body must not be empty")
+	case errors.As(err, &syntaxErr):
+		message = i18n.T(lang, "Malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		code = "invalid_field"
+		message = i18n.T(lang, "Field %q must be a %s", typeErr.Field, typeErr.Type)
+	default:
+		if field, ok := unknownField(err); ok {
+			code, message = "unknown_field", i18n.T(lang, "Unknown field %s", field)
+		}
+	}
+
+	trace.SpanFromContext(r.Context()).RecordError(err)
+	writeErrorEnvelope(w, r, status, ErrorDetail{Code: code, Message: message})
+	return false
+}
+
+// writeErrorEnvelope rejects the request with detail, whose message must
+// already be translated, and records the reason on the request span.
+func writeErrorEnvelope(w http.ResponseWriter, r *http.Request, status int, detail ErrorDetail) {
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(attribute.String("request.rejected.reason", detail.Code))
+	span.SetStatus(codes.Error, detail.Message)
+
+	detail.RequestID = requestid.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", i18n.Language(r))
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: detail}); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// unknownField extracts the field name from the error DisallowUnknownFields
+// produces, which has no dedicated type.
+func unknownField(err error) (string, bool) {
+	var field string
+	if _, scanErr := fmt.Sscanf(err.Error(), "json: unknown field %s", &field); scanErr != nil {
+		return "", false
+	}
+	return field, true
+}
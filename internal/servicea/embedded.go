@@ -0,0 +1,88 @@
+package servicea
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// embeddedTransport serves the requests for host with handler, in-process,
+// for single-binary deployments that embed Service B instead of calling it
+// over the network. Spans stay the same: the wrappers around it still open
+// the client span and inject the trace headers, and handler opens the
+// server span from them.
+func embeddedTransport(host string, handler http.Handler) func(http.RoundTripper) http.RoundTripper {
+	return func(base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != host {
+				return base.RoundTrip(req)
+			}
+			return serveEmbedded(handler, req)
+		})
+	}
+}
+
+// serveEmbedded runs handler for req as a server would receive it. The
+// handler gets a context of its own, cancelled with req's, so nothing of
+// Service A's request context leaks into Service B other than through
+// headers.
+func serveEmbedded(handler http.Handler, req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := context.AfterFunc(req.Context(), cancel)
+	defer stop()
+
+	in := req.Clone(ctx)
+	in.RequestURI = req.URL.RequestURI()
+	in.RemoteAddr = "127.0.0.1:0"
+	in.Host = req.URL.Host
+	in.URL.Scheme, in.URL.Host = "", ""
+	if in.Body == nil {
+		in.Body = http.NoBody
+	}
+	rw := &embeddedResponse{header: make(http.Header)}
+	handler.ServeHTTP(rw, in)
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return &http.Response{
+		Status:        strconv.Itoa(rw.status) + " " + http.StatusText(rw.status),
+		StatusCode:    rw.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rw.sent,
+		Body:          io.NopCloser(&rw.body),
+		ContentLength: int64(rw.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// embeddedResponse buffers a response, keeping the headers as they were
+// when it was written, as a server would send them.
+type embeddedResponse struct {
+	header http.Header
+	sent   http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *embeddedResponse) Header() http.Header { return rw.header }
+
+func (rw *embeddedResponse) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status, rw.sent = status, rw.header.Clone()
+	}
+}
+
+func (rw *embeddedResponse) Write(p []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	return rw.body.Write(p)
+}
+
+// Flush is a no-op; the response is handed over whole.
+func (rw *embeddedResponse) Flush() {}
@@ -0,0 +1,78 @@
+package servicea
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/net/http2"
+)
+
+// h2cTransport sends plain-HTTP requests for host over HTTP/2 with prior
+// knowledge (h2c), multiplexing them on a few long-lived connections instead
+// of opening one per concurrent request. Service B must serve h2c too, which
+// it does with HTTP2_CLEARTEXT. Other requests keep using base; https ones
+// negotiate HTTP/2 through ALPN on their own. A non-empty socket is the Unix
+// socket Service B listens on.
+func h2cTransport(host, socket string) func(http.RoundTripper) http.RoundTripper {
+	dial := (&net.Dialer{}).DialContext
+	if socket != "" {
+		dial = dialUnix(socket)
+	}
+	h2c := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+		// Pings detect connections a load balancer dropped silently, which
+		// would otherwise hang every request multiplexed on them.
+		ReadIdleTimeout: 30 * time.Second,
+		PingTimeout:     5 * time.Second,
+	}
+	return func(base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Scheme == "http" && req.URL.Host == host {
+				return h2c.RoundTrip(req)
+			}
+			return base.RoundTrip(req)
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// connectionMetrics counts the connections outgoing requests got, split by
+// whether they were reused, so connection churn towards Service B shows up
+// as a growing share of new ones.
+func connectionMetrics(meter metric.Meter) func(http.RoundTripper) http.RoundTripper {
+	conns, err := meter.Int64Counter("http.client.connections",
+		metric.WithDescription("Connections obtained for outgoing requests, by whether they were reused"),
+	)
+	if err != nil {
+		log.Printf("Failed to create connection counter: %v\n", err)
+		return func(base http.RoundTripper) http.RoundTripper { return base }
+	}
+	return func(base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			host := req.URL.Host
+			trace := &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					conns.Add(ctx, 1, metric.WithAttributes(
+						attribute.String("server.address", host),
+						attribute.Bool("http.connection.reused", info.Reused),
+					))
+				},
+			}
+			return base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package servicea
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// newHTTPClient builds the instrumented client shared by every upstream call.
+// It is created once at startup so connections are pooled across requests.
+// Nearly all of them go to Service B, so the idle pool is sized per host
+// rather than overall. wrap layers transports, innermost first, below the
+// instrumentation.
+func newHTTPClient(timeout time.Duration, maxIdlePerHost int, wrap ...func(http.RoundTripper) http.RoundTripper) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = max(100, maxIdlePerHost)
+	transport.MaxIdleConnsPerHost = maxIdlePerHost
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.TLSHandshakeTimeout = 5 * time.Second
+	transport.ResponseHeaderTimeout = timeout
+
+	var base http.RoundTripper = transport
+	for _, w := range wrap {
+		base = w(base)
+	}
+	return &http.Client{
+		Transport: otelhttp.NewTransport(base),
+		Timeout:   timeout,
+	}
+}
@@ -0,0 +1,199 @@
+package servicea
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/idempotency"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxIdempotencyKeyLength     = 255
+	idempotencyFingerprintLimit = 1 << 20
+)
+
+// idempotencyGuard replays the stored response of POST requests retried with
+// the same Idempotency-Key by the same client, on any replica sharing the
+// store. Keys reused with a different request are rejected.
+type idempotencyGuard struct {
+	store            idempotency.Store
+	ttl              time.Duration
+	lockTTL          time.Duration
+	maxResponseBytes int
+	requests         metric.Int64Counter
+}
+
+func newIdempotencyGuard(store idempotency.Store, ttl, lockTTL time.Duration, maxResponseBytes int, meter metric.Meter) *idempotencyGuard {
+	g := &idempotencyGuard{store: store, ttl: ttl, lockTTL: lockTTL, maxResponseBytes: maxResponseBytes}
+	var err error
+	if g.requests, err = meter.Int64Counter("idempotency.requests",
+		metric.WithDescription("Requests carrying an Idempotency-Key, by outcome"),
+	); err != nil {
+		log.Printf("Failed to create idempotency counter: %v\n", err)
+	}
+	return g
+}
+
+func (g *idempotencyGuard) record(ctx context.Context, outcome string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("idempotency.outcome", outcome))
+	if g.requests != nil {
+		g.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("idempotency.outcome", outcome)))
+	}
+}
+
+func (g *idempotencyGuard) middleware(next http.Handler) http.Handler {
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		lang := i18n.Language(r)
+		if len(key) > maxIdempotencyKeyLength {
+			writeErrorEnvelope(w, r, http.StatusBadRequest, ErrorDetail{
+				Code:    "invalid_idempotency_key",
+				Message: i18n.T(lang, "Idempotency-Key must not exceed %d characters", maxIdempotencyKeyLength),
+			})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, idempotencyFingerprintLimit))
+		if err != nil {
+			writeErrorEnvelope(w, r, http.StatusBadRequest, ErrorDetail{Code: "malformed_json", Message: i18n.T(lang, "Malformed JSON")})
+			return
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		storeKey := hashParts(clientKey(r), key)
+		fingerprint := hashParts(r.Method, r.URL.Path, r.URL.RawQuery, string(body))
+
+		stored, acquired, err := g.store.Begin(ctx, storeKey, g.lockTTL)
+		switch {
+		case err != nil:
+			// Fail open: a broken store must not take lookups down with it.
+			log.Printf("Idempotency store unavailable: %v\n", err)
+			g.record(ctx, "error")
+			next.ServeHTTP(w, r)
+			return
+		case stored != nil && stored.Fingerprint != fingerprint:
+			g.record(ctx, "mismatch")
+			writeErrorEnvelope(w, r, http.StatusUnprocessableEntity, ErrorDetail{
+				Code:    "idempotency_key_reused",
+				Message: i18n.T(lang, "Idempotency-Key was already used with a different request"),
+			})
+			return
+		case stored != nil:
+			g.record(ctx, "hit")
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		case !acquired:
+			g.record(ctx, "conflict")
+			w.Header().Set("Retry-After", "1")
+			writeErrorEnvelope(w, r, http.StatusConflict, ErrorDetail{
+				Code:      "idempotency_key_in_use",
+				Message:   i18n.T(lang, "A request with this Idempotency-Key is still in progress"),
+				Retryable: true,
+			})
+			return
+		}
+
+		g.record(ctx, "miss")
+		rec := &capturingWriter{ResponseWriter: w, status: http.StatusOK, limit: g.maxResponseBytes}
+		completed := false
+		defer func() {
+			storeCtx := context.WithoutCancel(ctx)
+			// Server errors and panics are not stored, so a retry gets a
+			// fresh attempt.
+			if !completed || rec.status >= http.StatusInternalServerError || rec.overflow {
+				if err := g.store.Release(storeCtx, storeKey); err != nil {
+					log.Printf("Failed to release idempotency key: %v\n", err)
+				}
+				return
+			}
+			// The body is captured before compression, which sets its own
+			// headers again when the response is replayed.
+			header := w.Header().Clone()
+			for _, name := range []string{"Content-Encoding", "Content-Length", "Vary"} {
+				header.Del(name)
+			}
+			resp := &idempotency.Response{Fingerprint: fingerprint, Status: rec.status, Header: header, Body: rec.body.Bytes()}
+			if err := g.store.Complete(storeCtx, storeKey, resp, g.ttl); err != nil {
+				log.Printf("Failed to store idempotent response: %v\n", err)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		completed = true
+	})
+}
+
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter copies the response it writes, up to limit bytes.
+type capturingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	limit       int
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (c *capturingWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capturingWriter) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	if !c.overflow {
+		if c.body.Len()+len(p) > c.limit {
+			c.overflow = true
+			c.body.Reset()
+		} else {
+			c.body.Write(p)
+		}
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *capturingWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *capturingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,47 @@
+package servicea
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/jwtauth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// bearerAuth requires a valid JWT in the Authorization header and stores its
+// claims in the request context, where callerBaggage finds the subject. A
+// nil verifier lets every request through.
+func bearerAuth(verifier *jwtauth.Verifier, next http.Handler) http.Handler {
+	if verifier == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="service-a"`)
+			writeErrorEnvelope(w, r, http.StatusUnauthorized, ErrorDetail{
+				Code:    "missing_token",
+				Message: i18n.T(i18n.Language(r), "A bearer token is required"),
+			})
+			return
+		}
+		claims, err := verifier.Verify(ctx, strings.TrimSpace(token))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid bearer token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="service-a", error="invalid_token"`)
+			writeErrorEnvelope(w, r, http.StatusUnauthorized, ErrorDetail{
+				Code:    "invalid_token",
+				Message: i18n.T(i18n.Language(r), "Invalid bearer token: %v", err),
+			})
+			return
+		}
+		span.SetAttributes(attribute.String(baggageEndUser, claims.Subject))
+		next.ServeHTTP(w, r.WithContext(jwtauth.ContextWithClaims(ctx, claims)))
+	})
+}
@@ -0,0 +1,89 @@
+package servicea
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	laneInteractive = "interactive"
+	laneBatch       = "batch"
+)
+
+type lane struct {
+	slots    chan struct{}
+	queued   atomic.Int64
+	maxQueue int64
+}
+
+func newLane(concurrency, maxQueue int) *lane {
+	return &lane{slots: make(chan struct{}, concurrency), maxQueue: int64(maxQueue)}
+}
+
+// laneScheduler gives interactive and batch traffic separate concurrency
+// pools and wait queues, so bulk jobs cannot starve interactive lookups.
+type laneScheduler struct {
+	lanes     map[string]*lane
+	batchKeys map[string]bool
+}
+
+func newLaneScheduler(interactive, batch *lane, batchKeys []string) *laneScheduler {
+	keys := make(map[string]bool, len(batchKeys))
+	for _, key := range batchKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			keys[key] = true
+		}
+	}
+	return &laneScheduler{
+		lanes:     map[string]*lane{laneInteractive: interactive, laneBatch: batch},
+		batchKeys: keys,
+	}
+}
+
+// classify puts requests in the batch lane when their API key is a batch key
+// or when they ask for it through X-Request-Priority.
+func (s *laneScheduler) classify(r *http.Request) string {
+	if s.batchKeys[r.Header.Get("X-API-Key")] {
+		return laneBatch
+	}
+	if strings.EqualFold(r.Header.Get("X-Request-Priority"), laneBatch) {
+		return laneBatch
+	}
+	return laneInteractive
+}
+
+func (s *laneScheduler) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := s.classify(r)
+		l := s.lanes[name]
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(attribute.String("request.priority", name))
+
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			if l.queued.Add(1) > l.maxQueue {
+				l.queued.Add(-1)
+				span.AddEvent("lane queue full")
+				w.Header().Set("Retry-After", "1")
+				i18n.Error(w, r, http.StatusServiceUnavailable, "Service Unavailable: too many queued requests")
+				return
+			}
+			select {
+			case l.slots <- struct{}{}:
+				l.queued.Add(-1)
+			case <-r.Context().Done():
+				l.queued.Add(-1)
+				return
+			}
+		}
+		defer func() { <-l.slots }()
+
+		next.ServeHTTP(w, r)
+	})
+}
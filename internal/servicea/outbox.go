@@ -0,0 +1,63 @@
+package servicea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/outbox"
+)
+
+// outboxBatchItem is the outbox kind of batch job items that failed
+// because Service B or its upstreams were down.
+const outboxBatchItem = "batch_job_item"
+
+// deferredBatchItem is what the outbox keeps to redo a batch job item.
+type deferredBatchItem struct {
+	JobID string `json:"job_id"`
+	Index int    `json:"index"`
+	CEP   string `json:"cep"`
+	Query string `json:"query,omitempty"`
+	Lang  string `json:"lang"`
+	// Version is the API version the client asked for, zero for none.
+	Version int `json:"version,omitempty"`
+}
+
+// deferBatchItem saves a retryable failure of item i of job to the outbox.
+// The job keeps the failure until a replay replaces it.
+func (s *server) deferBatchItem(ctx context.Context, job *batchJob, i int, item BatchItem) {
+	deferred := deferredBatchItem{JobID: job.ID, Index: i, CEP: item.CEP, Query: job.Query, Lang: job.Lang, Version: job.Version}
+	if err := s.outbox.Add(ctx, outboxBatchItem, deferred, errors.New(item.Error.Message)); err != nil {
+		log.Printf("Failed to defer item %d of batch job %s: %v\n", i, job.ID, err)
+	}
+}
+
+// replayOutbox is the outbox handler of Service A.
+func (s *server) replayOutbox(ctx context.Context, entry outbox.Entry) error {
+	if entry.Kind != outboxBatchItem {
+		log.Printf("Dropping outbox entry %s of unknown kind %q\n", entry.ID, entry.Kind)
+		return nil
+	}
+	var deferred deferredBatchItem
+	if err := json.Unmarshal(entry.Payload, &deferred); err != nil {
+		log.Printf("Dropping unreadable outbox entry %s: %v\n", entry.ID, err)
+		return nil
+	}
+	if deferred.Version != 0 {
+		ctx = apiversion.NewContext(ctx, deferred.Version)
+	}
+	item := s.lookupBatchItem(ctx, deferred.CEP, deferred.Query, deferred.Lang)
+	if item.Error != nil && item.Error.Retryable {
+		return errors.New(item.Error.Message)
+	}
+	kept, err := s.batchJobs.replace(deferred.JobID, deferred.Index, item)
+	if err != nil {
+		log.Printf("Failed to save batch job %s: %v\n", deferred.JobID, err)
+	}
+	if !kept {
+		log.Printf("Batch job %s expired before item %d was replayed\n", deferred.JobID, deferred.Index)
+	}
+	return nil
+}
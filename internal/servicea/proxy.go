@@ -0,0 +1,81 @@
+package servicea
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newServiceBProxy forwards lookups to Service B. Requests handed to it
+// already point at their Service B URL; the proxy sends them with only the
+// headers Service B needs (language, format, version and validators) plus
+// X-Forwarded-*, strips hop-by-hop headers from the response, streams the
+// body and passes trailers through. Successful JSON bodies are checked
+// against the contract with check first, and malformed ones answered with
+// 502.
+func newServiceBProxy(transport http.RoundTripper, check func(context.Context, url.Values, []byte) error) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.Header = http.Header{}
+			// Service B localizes its own error messages.
+			pr.Out.Header.Set("Accept-Language", i18n.Language(pr.In))
+			if etags := pr.In.Header.Get("If-None-Match"); etags != "" {
+				pr.Out.Header.Set("If-None-Match", etags)
+			}
+			if accept := pr.In.Header.Get("Accept"); accept != "" {
+				pr.Out.Header.Set("Accept", accept)
+			}
+			pr.Out.Host = ""
+			// Extend the chain of proxies the request came through.
+			if prior, ok := pr.In.Header["X-Forwarded-For"]; ok {
+				pr.Out.Header["X-Forwarded-For"] = prior
+			}
+			pr.SetXForwarded()
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Service B echoes the request ID Service A already set.
+			resp.Header.Del(requestid.Header)
+			trace.SpanFromContext(resp.Request.Context()).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			if resp.StatusCode != http.StatusOK || !isJSON(resp.Header.Get("Content-Type")) {
+				return nil
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+			if err := check(resp.Request.Context(), resp.Request.URL.Query(), body); err != nil {
+				return err
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			resp.ContentLength = int64(len(body))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var malformed *malformedResponseError
+			if errors.As(err, &malformed) {
+				requestid.Logf(r.Context(), "Malformed response from Service B: %v\n", err)
+				i18n.Error(w, r, http.StatusBadGateway, "Bad Gateway: malformed response from Service B: %v", err)
+				return
+			}
+			span := trace.SpanFromContext(r.Context())
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to reach Service B")
+			requestid.Logf(r.Context(), "Failed to reach Service B: %v\n", err)
+			i18n.Error(w, r, http.StatusInternalServerError, "Internal Server Error: Failed to reach Service B: %v", err)
+		},
+	}
+}
@@ -0,0 +1,188 @@
+package servicea
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// proxyTo serves proxy in front of backend, pointing each request at the
+// same path on backend as server.forward does.
+func proxyTo(t *testing.T, backendURL string, check func(context.Context, url.Values, []byte) error) *httptest.Server {
+	t.Helper()
+	proxy := newServiceBProxy(http.DefaultTransport, check)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := r.Clone(r.Context())
+		out.URL, _ = url.Parse(backendURL + r.URL.RequestURI())
+		proxy.ServeHTTP(w, out)
+	}))
+	t.Cleanup(front.Close)
+	return front
+}
+
+func acceptAll(context.Context, url.Values, []byte) error { return nil }
+
+func TestProxyForwardsOnlyServiceBHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer backend.Close()
+	front := proxyTo(t, backend.URL, acceptAll)
+
+	req, _ := http.NewRequest(http.MethodGet, front.URL+"/weather/01001000", nil)
+	req.Header.Set("Accept-Language", "pt-BR")
+	req.Header.Set("Accept", "text/csv")
+	req.Header.Set("If-None-Match", `"abc"`)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got := <-received
+	for name, want := range map[string]string{
+		"Accept-Language": "pt-BR",
+		"Accept":          "text/csv",
+		"If-None-Match":   `"abc"`,
+		"X-Forwarded-For": "203.0.113.7, 127.0.0.1",
+		"Authorization":   "",
+		"Cookie":          "",
+	} {
+		if got.Get(name) != want {
+			t.Errorf("Service B got %s %q, want %q", name, got.Get(name), want)
+		}
+	}
+	if got.Get("X-Forwarded-Host") == "" || got.Get("X-Forwarded-Proto") != "http" {
+		t.Errorf("Service B got X-Forwarded-Host %q and X-Forwarded-Proto %q", got.Get("X-Forwarded-Host"), got.Get("X-Forwarded-Proto"))
+	}
+}
+
+func TestProxyStripsHopByHopResponseHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "X-Hop")
+		w.Header().Set("X-Hop", "1")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		w.Header().Set("Proxy-Authenticate", "Basic")
+		w.Header().Set("X-Request-Id", "from-service-b")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/csv")
+		io.WriteString(w, "city\nSão Paulo\n")
+	}))
+	defer backend.Close()
+
+	direct, err := http.Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct.Body.Close()
+	if direct.Header.Get("X-Hop") == "" || direct.Header.Get("Keep-Alive") == "" {
+		t.Fatal("Service B stub does not send the hop-by-hop headers")
+	}
+	resp, err := http.Get(proxyTo(t, backend.URL, acceptAll).URL + "/weather/01001000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, name := range []string{"X-Hop", "Keep-Alive", "Proxy-Authenticate", "X-Request-Id"} {
+		if value := resp.Header.Get(name); value != "" {
+			t.Errorf("response kept %s: %q", name, value)
+		}
+	}
+	if resp.Header.Get("ETag") != `"v1"` {
+		t.Errorf("ETag = %q, want it relayed", resp.Header.Get("ETag"))
+	}
+}
+
+func TestProxyStreamsBodyAndTrailers(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Import-Total")
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, "{\"line\":1}\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "{\"line\":2}\n")
+		w.Header().Set("X-Import-Total", "2")
+	}))
+	defer backend.Close()
+
+	resp, err := http.Get(proxyTo(t, backend.URL, acceptAll).URL + "/weather/import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	// The first line must arrive while Service B still holds the second.
+	if line, err := reader.ReadString('\n'); err != nil || line != "{\"line\":1}\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	close(release)
+	if rest, err := io.ReadAll(reader); err != nil || string(rest) != "{\"line\":2}\n" {
+		t.Fatalf("rest = %q, %v", rest, err)
+	}
+	if got := resp.Trailer.Get("X-Import-Total"); got != "2" {
+		t.Errorf("trailer X-Import-Total = %q, want 2", got)
+	}
+}
+
+func TestProxyAnswers(t *testing.T) {
+	check := func(_ context.Context, _ url.Values, body []byte) error {
+		if !strings.Contains(string(body), `"city"`) {
+			return &malformedResponseError{err: errors.New("missing city")}
+		}
+		return nil
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/weather/valid":
+			io.WriteString(w, `{"city":"São Paulo","temp_C":25}`)
+		case "/weather/malformed":
+			io.WriteString(w, `{"temp_C":25}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `can not find zipcode`)
+		}
+	}))
+	front := proxyTo(t, backend.URL, check)
+	down := proxyTo(t, "http://127.0.0.1:1", check)
+	defer backend.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid body", front.URL + "/weather/valid", http.StatusOK, `{"city":"São Paulo","temp_C":25}`},
+		{"malformed body", front.URL + "/weather/malformed", http.StatusBadGateway, "Bad Gateway: malformed response from Service B: missing city"},
+		{"error relayed unchecked", front.URL + "/weather/99999999", http.StatusNotFound, "can not find zipcode"},
+		{"service b down", down.URL + "/weather/valid", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(string(body)) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
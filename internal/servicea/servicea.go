@@ -0,0 +1,398 @@
+// Package servicea implements Service A: it validates CEPs and forwards
+// lookups to Service B.
+package servicea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/admin"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/asyncjobs"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/callbackurl"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/compress"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/faultinject"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/idempotency"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/ipfilter"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/journal"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/jwtauth"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/outbox"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tenants"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tracing"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/unmatched"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/version"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+type CEPRequest struct {
+	CEP         string `json:"cep"`
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+type server struct {
+	client      *http.Client
+	serviceBURL string
+	publisher   *asyncjobs.Publisher
+	pending     *pendingJobs
+	streams     *streamHub
+	proxy       *httputil.ReverseProxy
+	// astronomyProxy checks bodies against the astronomy contract instead.
+	astronomyProxy *httputil.ReverseProxy
+
+	cepPolicy cep.Policy
+
+	maxBodyBytes     int64
+	batchMaxSize     int
+	batchConcurrency int
+
+	batchAsyncThreshold int
+	batchJobMaxSize     int
+	batchJobs           *batchJobStore
+	batchJobRunner      *batchJobRunner
+	// outbox is nil unless OUTBOX_DIR is set.
+	outbox *outbox.Outbox
+
+	importMaxBytes    int64
+	importConcurrency int
+}
+
+type Options struct {
+	// ServiceBURL is the base URL lookups are forwarded to.
+	ServiceBURL string
+	// ServiceB, when set, serves the requests to ServiceBURL in-process
+	// instead of over the network.
+	ServiceB http.Handler
+	// ServiceBSocket, when set, is the Unix socket requests to ServiceBURL
+	// are sent over.
+	ServiceBSocket string
+	// Stats counts served requests for the shutdown report.
+	Stats *shutdownreport.Collector
+}
+
+// Service is a configured Service A. Its settings are read from the
+// environment by New.
+type Service struct {
+	srv     *server
+	handler http.Handler
+	admin   *http.ServeMux
+	closers []func() error
+}
+
+func New(opts Options) (*Service, error) {
+	var chaosTargets map[string]string
+	var serviceBHost string
+	if u, err := url.Parse(opts.ServiceBURL); err == nil {
+		serviceBHost = u.Host
+		chaosTargets = map[string]string{u.Host: "service-b"}
+	}
+	chaos, err := faultinject.ChaosFromEnv(chaosTargets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHAOS_FAULTS: %w", err)
+	}
+	var transports []func(http.RoundTripper) http.RoundTripper
+	switch {
+	case opts.ServiceB != nil:
+		transports = append(transports, embeddedTransport(serviceBHost, opts.ServiceB))
+	case os.Getenv("HTTP2_CLEARTEXT") == "true":
+		transports = append(transports, h2cTransport(serviceBHost, opts.ServiceBSocket))
+	case opts.ServiceBSocket != "":
+		transports = append(transports, unixSocketTransport(serviceBHost, opts.ServiceBSocket))
+	}
+	transports = append(transports, connectionMetrics(otel.Meter("service-a/http")), requestid.Transport)
+	if chaos != nil {
+		transports = append(transports, chaos.Wrap)
+	}
+
+	cepPolicy, err := cep.ParsePolicy(os.Getenv("CEP_POLICY"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEP_POLICY: %w", err)
+	}
+	srv := &server{
+		cepPolicy: cepPolicy,
+		client: newHTTPClient(envconfig.Duration("HTTP_CLIENT_TIMEOUT", 10*time.Second),
+			envconfig.Int("HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST", 64), transports...),
+		serviceBURL: strings.TrimRight(opts.ServiceBURL, "/"),
+		pending:     newPendingJobs(),
+		streams: newStreamHub(envconfig.Duration("STREAM_INTERVAL", time.Minute), envconfig.Duration("STREAM_WRITE_TIMEOUT", 10*time.Second),
+			envconfig.Int("STREAM_MAX_SUBSCRIBERS", 1000), otel.Meter("service-a/stream")),
+
+		maxBodyBytes:     int64(envconfig.Int("MAX_REQUEST_BODY_BYTES", 64<<10)),
+		batchMaxSize:     envconfig.Int("BATCH_MAX_SIZE", 100),
+		batchConcurrency: max(envconfig.Int("BATCH_CONCURRENCY", 8), 1),
+
+		batchAsyncThreshold: envconfig.Int("BATCH_ASYNC_THRESHOLD", 0),
+		batchJobMaxSize:     envconfig.Int("BATCH_JOB_MAX_SIZE", 10000),
+		batchJobRunner:      newBatchJobRunner(),
+
+		importMaxBytes:    int64(envconfig.Int("IMPORT_MAX_BYTES", 10<<20)),
+		importConcurrency: max(envconfig.Int("IMPORT_CONCURRENCY", 8), 1),
+	}
+	srv.proxy = newServiceBProxy(srv.client.Transport, checkWeatherBody)
+	srv.astronomyProxy = newServiceBProxy(srv.client.Transport, checkAstronomyBody)
+	svc := &Service{srv: srv}
+
+	if srv.batchJobs, err = openBatchJobStore(os.Getenv("BATCH_JOBS_DIR"), envconfig.Duration("BATCH_JOB_RETENTION", 24*time.Hour)); err != nil {
+		return nil, err
+	}
+	svc.closers = append(svc.closers, srv.batchJobRunner.close)
+	if dir := os.Getenv("OUTBOX_DIR"); dir != "" {
+		if srv.outbox, err = outbox.Open(filepath.Join(dir, "service-a"), envconfig.Int("OUTBOX_MAX_ENTRIES", 10000)); err != nil {
+			return nil, err
+		}
+		srv.batchJobRunner.wg.Add(1)
+		go func() {
+			defer srv.batchJobRunner.wg.Done()
+			srv.outbox.Run(srv.batchJobRunner.ctx, envconfig.Duration("OUTBOX_RETRY_INTERVAL", 30*time.Second), srv.replayOutbox)
+		}()
+	}
+	for _, job := range srv.batchJobs.unfinished() {
+		fmt.Printf("Resuming batch job %s\n", job.ID)
+		srv.startBatchJob(context.Background(), job)
+	}
+
+	lanes := newLaneScheduler(
+		newLane(envconfig.Int("LANE_INTERACTIVE_CONCURRENCY", 64), envconfig.Int("LANE_INTERACTIVE_QUEUE", 128)),
+		newLane(envconfig.Int("LANE_BATCH_CONCURRENCY", 8), envconfig.Int("LANE_BATCH_QUEUE", 32)),
+		strings.Split(os.Getenv("BATCH_API_KEYS"), ","),
+	)
+
+	clients := newClientLimiter(envconfig.Int("CLIENT_MAX_CONCURRENT", 0))
+
+	cepHasher, err := privacy.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var verifier *jwtauth.Verifier
+	if secret, jwksURL := os.Getenv("JWT_HS256_SECRET"), os.Getenv("JWT_JWKS_URL"); secret != "" || jwksURL != "" {
+		cfg := jwtauth.Config{
+			Secret:   []byte(secret),
+			Issuer:   os.Getenv("JWT_ISSUER"),
+			Audience: os.Getenv("JWT_AUDIENCE"),
+			Leeway:   envconfig.Duration("JWT_LEEWAY", time.Minute),
+		}
+		if jwksURL != "" {
+			cfg.JWKS = jwtauth.NewJWKS(jwksURL, srv.client, envconfig.Duration("JWT_JWKS_REFRESH", time.Hour))
+		}
+		if verifier, err = jwtauth.NewVerifier(cfg); err != nil {
+			return nil, err
+		}
+	}
+	bearer := func(h http.Handler) http.Handler { return bearerAuth(verifier, h) }
+
+	var gate *tenantGate
+	if path := os.Getenv("TENANTS_PATH"); path != "" {
+		registry, err := tenants.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tenants: %w", err)
+		}
+		var usage tenants.Store = tenants.NewMemoryStore()
+		if redisURL := os.Getenv("TENANT_USAGE_REDIS_URL"); redisURL != "" {
+			if usage, err = tenants.NewRedisStore(redisURL, "service-a:usage:"); err != nil {
+				return nil, fmt.Errorf("invalid TENANT_USAGE_REDIS_URL: %w", err)
+			}
+		}
+		svc.closers = append(svc.closers, usage.Close)
+		gate = &tenantGate{registry: registry, usage: usage}
+	}
+
+	compression := compress.Middleware(envconfig.Int("COMPRESSION_MIN_SIZE", 1024), otel.Meter("service-a/http"))
+
+	var idempotent *idempotencyGuard
+	if ttl := envconfig.Duration("IDEMPOTENCY_TTL", 24*time.Hour); ttl > 0 {
+		var store idempotency.Store = idempotency.NewMemoryStore(envconfig.Int("IDEMPOTENCY_MAX_ENTRIES", 10000))
+		if redisURL := os.Getenv("IDEMPOTENCY_REDIS_URL"); redisURL != "" {
+			redisStore, err := idempotency.NewRedisStore(redisURL, "service-a:idempotency:")
+			if err != nil {
+				return nil, fmt.Errorf("invalid IDEMPOTENCY_REDIS_URL: %w", err)
+			}
+			store = redisStore
+		}
+		svc.closers = append(svc.closers, store.Close)
+		idempotent = newIdempotencyGuard(store, ttl, envconfig.Duration("IDEMPOTENCY_LOCK_TTL", 30*time.Second),
+			envconfig.Int("IDEMPOTENCY_MAX_RESPONSE_BYTES", 64<<10), otel.Meter("service-a/idempotency"))
+	}
+
+	journaled := func(h http.Handler) http.Handler { return h }
+	if path := os.Getenv("JOURNAL_PATH"); path != "" {
+		requestJournal, err := journal.Open(path, int64(envconfig.Int("JOURNAL_MAX_BYTES", 10<<20)), envconfig.Int("JOURNAL_MAX_FILES", 5))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open request journal: %w", err)
+		}
+		svc.closers = append(svc.closers, requestJournal.Close)
+		requestJournal.OmitCEP = cepHasher != nil
+		journaled = requestJournal.Middleware
+	}
+
+	mux := http.NewServeMux()
+	instrument := func(h http.HandlerFunc) http.Handler {
+		return otelhttp.NewHandler(tracing.Recover("service-a/http", tracing.WithRoute(gate.middleware(bearer(callerBaggage(chaos.Middleware(opts.Stats.Middleware(journaled(compression(idempotent.middleware(clients.middleware(lanes.middleware(h)))))))))))), "ServiceA-HTTP-Request",
+			otelhttp.WithSpanNameFormatter(tracing.RouteSpanName))
+	}
+	mux.Handle("POST /{$}", instrument(srv.handleCEPRequest))
+	mux.Handle("GET /weather", instrument(srv.handleCEPQuery))
+	mux.Handle("GET /weather/{cep}", instrument(srv.handleCEPQuery))
+	mux.Handle("POST /weather/batch", instrument(srv.handleBatchLookup))
+	mux.Handle("GET /weather/batch/jobs/{id}", instrument(srv.handleBatchJobStatus))
+	mux.Handle("POST /weather/import", instrument(srv.handleImport))
+	// Streams are long-lived, so they skip the lanes, the per-client limit,
+	// idempotency and compression; the hub caps them instead.
+	mux.Handle("GET /weather/{cep}/{view}", weatherViews{
+		"stream": otelhttp.NewHandler(tracing.Recover("service-a/http", tracing.WithRoute(gate.middleware(bearer(callerBaggage(chaos.Middleware(opts.Stats.Middleware(journaled(http.HandlerFunc(srv.handleWeatherStream))))))))), "ServiceA-HTTP-Request",
+			otelhttp.WithSpanNameFormatter(tracing.RouteSpanName)),
+		"astronomy": instrument(srv.handleAstronomy),
+	})
+	mux.HandleFunc("GET /version", version.Handler("service-a"))
+	unmatched.Register(mux, instrument)
+
+	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+		topic := envconfig.String("KAFKA_LOOKUP_TOPIC", "weather-lookups")
+		srv.publisher = asyncjobs.NewPublisher(strings.Split(brokers, ","), topic)
+		svc.closers = append(svc.closers, srv.publisher.Close)
+		mux.Handle("POST /weather/async", instrument(srv.handleAsyncLookup))
+		mux.Handle("GET /weather/jobs/{id}", instrument(srv.handleJobStatus))
+		fmt.Printf("Async lookups enabled, publishing to Kafka topic %s\n", topic)
+	}
+	ips, err := ipfilter.New(os.Getenv("IP_ALLOWLIST"), os.Getenv("IP_DENYLIST"), otel.Meter("service-a/http"))
+	if err != nil {
+		return nil, err
+	}
+	svc.handler = ips.Middleware(requestid.Middleware(apiversion.Middleware(mux)))
+
+	svc.admin = http.NewServeMux()
+	svc.admin.HandleFunc("GET /debug/buildinfo", admin.BuildInfoHandler)
+	if gate != nil {
+		svc.admin.Handle("GET /admin/usage", admin.RequireToken(os.Getenv("ADMIN_TOKEN"), http.HandlerFunc(gate.usageHandler)))
+	}
+	if srv.outbox != nil {
+		svc.admin.Handle("GET /admin/outbox", admin.RequireToken(os.Getenv("ADMIN_TOKEN"), http.HandlerFunc(srv.outbox.StatusHandler)))
+	}
+
+	return svc, nil
+}
+
+// Handler serves the public API.
+func (s *Service) Handler() http.Handler { return s.handler }
+
+// AdminHandler serves the admin endpoints, meant for a separate port.
+func (s *Service) AdminHandler() http.Handler { return s.admin }
+
+// Client is the instrumented HTTP client used for outgoing calls.
+func (s *Service) Client() *http.Client { return s.srv.client }
+
+// CloseStreams ends the open weather streams. It is meant to run when the
+// server starts shutting down.
+func (s *Service) CloseStreams() { s.srv.streams.close() }
+
+func (s *Service) Close() error {
+	var errs []error
+	for _, closer := range s.closers {
+		errs = append(errs, closer())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *server) handleCEPRequest(w http.ResponseWriter, r *http.Request) {
+	var req CEPRequest
+	if !s.decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	s.forwardCEP(w, r, req.CEP, req.CallbackURL)
+}
+
+// handleCEPQuery serves GET /weather?cep=... and GET /weather/{cep}.
+func (s *server) handleCEPQuery(w http.ResponseWriter, r *http.Request) {
+	rawCEP := r.PathValue("cep")
+	if rawCEP == "" {
+		rawCEP = r.URL.Query().Get("cep")
+	}
+	s.forwardCEP(w, r, rawCEP, r.URL.Query().Get("callback_url"))
+}
+
+func (s *server) forwardCEP(w http.ResponseWriter, r *http.Request, rawCEP, callbackURL string) {
+	normalizedCEP, err := s.cepPolicy.Normalize(rawCEP)
+	if err != nil {
+		i18n.Error(w, r, http.StatusUnprocessableEntity, "invalid zipcode")
+		return
+	}
+	journal.SetCEP(r.Context(), normalizedCEP)
+
+	if callbackURL != "" && !callbackurl.Valid(callbackURL) {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: callback_url must be an absolute http(s) URL")
+		return
+	}
+
+	query := serviceBQuery(r)
+	if callbackURL != "" {
+		query.Set("callback_url", callbackURL)
+	}
+	s.forward(w, r, s.proxy, "/weather/"+normalizedCEP, query)
+}
+
+// serviceBURLFor is the URL of path on Service B under the API version the
+// client asked for, if any.
+func (s *server) serviceBURLFor(ctx context.Context, path string) string {
+	if v, ok := apiversion.FromContext(ctx); ok {
+		return fmt.Sprintf("%s/v%d%s", s.serviceBURL, v, path)
+	}
+	return s.serviceBURL + path
+}
+
+// forward sends r to path on Service B through proxy, under the API version
+// the client asked for.
+func (s *server) forward(w http.ResponseWriter, r *http.Request, proxy *httputil.ReverseProxy, path string, query url.Values) {
+	ctx, span := otel.Tracer("service-a/handler").Start(r.Context(), "call-service-b")
+	defer span.End()
+
+	targetURL := s.serviceBURLFor(ctx, path)
+	if len(query) > 0 {
+		targetURL += "?" + query.Encode()
+	}
+
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create request to Service B")
+		i18n.Error(w, r, http.StatusInternalServerError, "Internal Server Error: Failed to create request to Service B: %v", err)
+		return
+	}
+	if s.client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.client.Timeout)
+		defer cancel()
+	}
+	serviceBReq := r.Clone(ctx)
+	serviceBReq.Method = http.MethodGet
+	serviceBReq.URL = target
+	serviceBReq.Body = http.NoBody
+	serviceBReq.ContentLength = 0
+	proxy.ServeHTTP(w, serviceBReq)
+}
+
+// serviceBQuery carries the response options of r over to Service B.
+func serviceBQuery(r *http.Request) url.Values {
+	query := url.Values{}
+	for _, name := range []string{"units", "include", "fields"} {
+		if value := r.URL.Query().Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
@@ -0,0 +1,226 @@
+package servicea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// streamHub tracks the open weather streams. Each subscriber is served by
+// its own handler goroutine; the hub caps how many exist and ends them all
+// when the server shuts down.
+type streamHub struct {
+	interval     time.Duration
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+	max          int64
+
+	active    atomic.Int64
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	subscribers metric.Int64UpDownCounter
+}
+
+func newStreamHub(interval, writeTimeout time.Duration, max int, meter metric.Meter) *streamHub {
+	h := &streamHub{
+		interval:     interval,
+		heartbeat:    min(interval, 15*time.Second),
+		writeTimeout: writeTimeout,
+		max:          int64(max),
+		closing:      make(chan struct{}),
+	}
+	var err error
+	if h.subscribers, err = meter.Int64UpDownCounter("weather.stream.subscribers",
+		metric.WithDescription("Open weather streams"),
+	); err != nil {
+		log.Printf("Failed to create stream subscribers counter: %v\n", err)
+	}
+	return h
+}
+
+func (h *streamHub) join(ctx context.Context) bool {
+	if h.active.Add(1) > h.max {
+		h.active.Add(-1)
+		return false
+	}
+	if h.subscribers != nil {
+		h.subscribers.Add(ctx, 1)
+	}
+	return true
+}
+
+func (h *streamHub) leave(ctx context.Context) {
+	h.active.Add(-1)
+	if h.subscribers != nil {
+		h.subscribers.Add(context.WithoutCancel(ctx), -1)
+	}
+}
+
+// close tells every stream to say goodbye and return, so http.Server's
+// Shutdown does not wait on them.
+func (h *streamHub) close() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// handleWeatherStream serves GET /weather/{cep}/stream as server-sent events:
+// a "weather" event with the Service B response right away and then every
+// interval, "error" events for failed refreshes, and a final "shutdown"
+// event.
+func (s *server) handleWeatherStream(w http.ResponseWriter, r *http.Request) {
+	normalizedCEP, err := s.cepPolicy.Normalize(r.PathValue("cep"))
+	if err != nil {
+		i18n.Error(w, r, http.StatusUnprocessableEntity, "invalid zipcode")
+		return
+	}
+	ctx := r.Context()
+	if !s.streams.join(ctx) {
+		w.Header().Set("Retry-After", "30")
+		i18n.Error(w, r, http.StatusServiceUnavailable, "Service Unavailable: too many weather streams")
+		return
+	}
+	defer s.streams.leave(ctx)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("cep", normalizedCEP))
+	streamLink := trace.LinkFromContext(ctx)
+	query := serviceBQuery(r).Encode()
+	lang := i18n.Language(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	// send writes one event, giving a slow subscriber writeTimeout to take it
+	// before the stream is dropped.
+	send := func(event string, id int, data []byte) bool {
+		if err := rc.SetWriteDeadline(time.Now().Add(s.streams.writeTimeout)); err != nil && err != http.ErrNotSupported {
+			return false
+		}
+		var b strings.Builder
+		if event != "" {
+			fmt.Fprintf(&b, "event: %s\nid: %d\n", event, id)
+			for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+				fmt.Fprintf(&b, "data: %s\n", line)
+			}
+		} else {
+			b.WriteString(": keepalive\n")
+		}
+		b.WriteString("\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	refresh := time.NewTicker(s.streams.interval)
+	defer refresh.Stop()
+	heartbeat := time.NewTicker(s.streams.heartbeat)
+	defer heartbeat.Stop()
+	sent := 0
+	for {
+		sent++
+		event, data, final := s.streamUpdate(ctx, streamLink, normalizedCEP, query, lang, sent)
+		if !send(event, sent, data) {
+			span.AddEvent("subscriber too slow or gone", trace.WithAttributes(attribute.Int("stream.events", sent)))
+			return
+		}
+		if final {
+			return
+		}
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				span.SetAttributes(attribute.Int("stream.events", sent))
+				return
+			case <-s.streams.closing:
+				send("shutdown", sent+1, []byte(`{"reason":"server shutting down"}`))
+				span.SetAttributes(attribute.Int("stream.events", sent+1))
+				return
+			case <-heartbeat.C:
+				if !send("", 0, nil) {
+					return
+				}
+			case <-refresh.C:
+				break wait
+			}
+		}
+	}
+}
+
+// streamUpdate fetches the weather from Service B as its own trace, linked
+// to the stream's request span so a long-lived stream does not grow a
+// single trace without bound. final is set for answers that will not change,
+// such as an unknown CEP.
+func (s *server) streamUpdate(ctx context.Context, streamLink trace.Link, cepCode, query, lang string, seq int) (event string, data []byte, final bool) {
+	ctx, span := otel.Tracer("service-a/stream").Start(context.WithoutCancel(ctx), "stream-weather-update",
+		trace.WithNewRoot(), trace.WithLinks(streamLink), trace.WithAttributes(
+			attribute.String("cep", cepCode),
+			attribute.Int("stream.sequence", seq),
+		))
+	defer span.End()
+
+	targetURL := s.serviceBURLFor(ctx, "/weather/"+cepCode)
+	if query != "" {
+		targetURL += "?" + query
+	}
+	status, body, err := s.get(ctx, targetURL, lang)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to reach Service B")
+		return "error", streamError(http.StatusBadGateway, i18n.T(lang, "Failed to reach Service B: %v", err)), false
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status != http.StatusOK {
+		span.SetStatus(codes.Error, "Service B refused the lookup")
+		final = status == http.StatusNotFound || status == http.StatusUnprocessableEntity
+		return "error", streamError(status, strings.TrimSpace(string(body))), final
+	}
+	values, _ := url.ParseQuery(query)
+	if err := checkWeatherBody(ctx, values, body); err != nil {
+		return "error", streamError(http.StatusBadGateway, i18n.T(lang, "Bad Gateway: malformed response from Service B: %v", err)), false
+	}
+	return "weather", body, false
+}
+
+func (s *server) get(ctx context.Context, targetURL, lang string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept-Language", lang)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+func streamError(status int, message string) []byte {
+	data, err := json.Marshal(map[string]any{"status": status, "message": message})
+	if err != nil {
+		log.Printf("Error encoding stream error: %v\n", err)
+	}
+	return data
+}
@@ -0,0 +1,111 @@
+package servicea
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tenants"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tenantGate authenticates requests by X-API-Key against the tenant
+// registry and enforces each tenant's daily quota. A nil gate lets every
+// request through.
+type tenantGate struct {
+	registry *tenants.Registry
+	usage    tenants.Store
+}
+
+func (g *tenantGate) middleware(next http.Handler) http.Handler {
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tenant, ok := g.registry.Lookup(r.Header.Get("X-API-Key"))
+		if !ok {
+			writeErrorEnvelope(w, r, http.StatusUnauthorized, ErrorDetail{
+				Code:    "invalid_api_key",
+				Message: i18n.T(i18n.Language(r), "A valid X-API-Key is required"),
+			})
+			return
+		}
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(attribute.String(baggageTenant, tenant.Name))
+
+		now := time.Now()
+		used, allowed, err := g.usage.Take(ctx, tenant.Name, tenants.Day(now), tenant.DailyQuota)
+		if err != nil {
+			// Fail open: a broken usage store must not take lookups down.
+			requestid.Logf(ctx, "Tenant usage store unavailable: %v\n", err)
+			allowed = true
+		}
+		if tenant.DailyQuota > 0 {
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(tenant.DailyQuota, 10))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(tenant.DailyQuota-used, 0), 10))
+		}
+		if !allowed {
+			span.AddEvent("tenant quota exceeded", trace.WithAttributes(attribute.Int64("tenant.daily_quota", tenant.DailyQuota)))
+			w.Header().Set("Retry-After", strconv.Itoa(int(tenants.UntilReset(now).Seconds())+1))
+			writeErrorEnvelope(w, r, http.StatusTooManyRequests, ErrorDetail{
+				Code:      "quota_exceeded",
+				Message:   i18n.T(i18n.Language(r), "Daily quota of %d requests exceeded", tenant.DailyQuota),
+				Retryable: true,
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(tenants.ContextWithTenant(ctx, tenant)))
+	})
+}
+
+// TenantUsage is one tenant's entry in GET /admin/usage. Remaining is left
+// out for tenants without a quota.
+type TenantUsage struct {
+	Tenant     string `json:"tenant"`
+	DailyQuota int64  `json:"daily_quota"`
+	tenants.Usage
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+// UsageReport is the body of GET /admin/usage.
+type UsageReport struct {
+	Day     string        `json:"day"`
+	Tenants []TenantUsage `json:"tenants"`
+}
+
+// usageHandler serves GET /admin/usage, for today or for ?day=YYYY-MM-DD.
+func (g *tenantGate) usageHandler(w http.ResponseWriter, r *http.Request) {
+	day := r.URL.Query().Get("day")
+	if day == "" {
+		day = tenants.Day(time.Now())
+	} else if _, err := time.Parse(time.DateOnly, day); err != nil {
+		http.Error(w, "Bad Request: day must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	usage, err := g.usage.Usage(r.Context(), day)
+	if err != nil {
+		log.Printf("Failed to read tenant usage: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	report := UsageReport{Day: day, Tenants: []TenantUsage{}}
+	for _, tenant := range g.registry.Tenants() {
+		entry := TenantUsage{Tenant: tenant.Name, DailyQuota: tenant.DailyQuota, Usage: usage[tenant.Name]}
+		if tenant.DailyQuota > 0 {
+			remaining := max(tenant.DailyQuota-entry.Used, 0)
+			entry.Remaining = &remaining
+		}
+		report.Tenants = append(report.Tenants, entry)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
@@ -0,0 +1,35 @@
+package servicea
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// unixSocketTransport sends the requests for host to the Unix socket at
+// path, for Service B deployed as a sidecar listening on one. The
+// connections are pooled as base pools its own.
+func unixSocketTransport(host, path string) func(http.RoundTripper) http.RoundTripper {
+	return func(base http.RoundTripper) http.RoundTripper {
+		t, ok := base.(*http.Transport)
+		if !ok {
+			t = http.DefaultTransport.(*http.Transport)
+		}
+		unix := t.Clone()
+		unix.DialContext = dialUnix(path)
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host == host {
+				return unix.RoundTrip(req)
+			}
+			return base.RoundTrip(req)
+		})
+	}
+}
+
+// dialUnix dials the Unix socket at path whatever address is asked for.
+func dialUnix(path string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+}
@@ -0,0 +1,58 @@
+package servicea
+
+import (
+	"context"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/contract"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// malformedResponseError is a Service B weather body that breaks the
+// contract. The proxy answers it with 502 rather than relaying it.
+type malformedResponseError struct {
+	err error
+}
+
+func (e *malformedResponseError) Error() string { return e.err.Error() }
+func (e *malformedResponseError) Unwrap() error { return e.err }
+
+// checkWeatherBody validates a successful weather body from Service B,
+// requested with query, and records a failure on the span of ctx.
+func checkWeatherBody(ctx context.Context, query url.Values, body []byte) error {
+	var fields []string
+	for _, name := range strings.Split(query.Get("fields"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return malformed(ctx, contract.ValidateWeather(body, fields))
+}
+
+// checkAstronomyBody validates a successful astronomy body from Service B.
+func checkAstronomyBody(ctx context.Context, _ url.Values, body []byte) error {
+	return malformed(ctx, contract.ValidateAstronomy(body))
+}
+
+// malformed records a contract violation err, if any, on the span of ctx.
+func malformed(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetAttributes(attribute.String("error.type", "malformed_response"))
+	span.SetStatus(codes.Error, "malformed Service B response")
+	return &malformedResponseError{err: err}
+}
+
+// isJSON reports whether contentType is JSON, including the versioned
+// application/vnd.cepweather.v1+json. Other formats are relayed unchecked.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
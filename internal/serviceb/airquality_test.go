@@ -0,0 +1,96 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+)
+
+func TestWeatherAPIParsesAirQuality(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *provider.AirQuality
+	}{
+		{
+			name: "reported",
+			body: `{"current":{"temp_c":25,"air_quality":{"co":230.3,"pm2_5":12.5,"pm10":20.25,"us-epa-index":2,"gb-defra-index":1}}}`,
+			want: &provider.AirQuality{PM25: 12.5, PM10: 20.25, USEPAIndex: 2},
+		},
+		{
+			name: "partly reported",
+			body: `{"current":{"temp_c":25,"air_quality":{"pm2_5":3.1}}}`,
+			want: &provider.AirQuality{PM25: 3.1},
+		},
+		{
+			name: "not reported",
+			body: `{"current":{"temp_c":25}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("aqi") != "yes" {
+					t.Errorf("query %q does not ask for air quality", r.URL.RawQuery)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			}))
+			defer upstream.Close()
+
+			obs, err := newWeatherAPIProvider(upstream.Client(), upstream.URL, "test").Current(context.Background(), testKnownCity)
+			if err != nil {
+				t.Fatalf("Current: %v", err)
+			}
+			switch {
+			case tt.want == nil && obs.AirQuality != nil:
+				t.Errorf("AirQuality = %+v, want nil", *obs.AirQuality)
+			case tt.want != nil && (obs.AirQuality == nil || *obs.AirQuality != *tt.want):
+				t.Errorf("AirQuality = %+v, want %+v", obs.AirQuality, *tt.want)
+			}
+		})
+	}
+}
+
+func TestWeatherHandlerIncludesAirQuality(t *testing.T) {
+	svc := newTestService(t, fakeUpstreams(t), nil)
+	want := AirQualityResponse{PM25: 12.5, PM10: 20.25, USEPAIndex: 2}
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       *AirQualityResponse
+	}{
+		{query: "", wantStatus: http.StatusOK},
+		{query: "?include=aqi", wantStatus: http.StatusOK, want: &want},
+		{query: "?include=address,aqi&units=metric", wantStatus: http.StatusOK, want: &want},
+		{query: "?include=pollen", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather/"+testKnownCEP+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("answered %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var body struct {
+				AirQuality *AirQualityResponse `json:"air_quality"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			switch {
+			case tt.want == nil && body.AirQuality != nil:
+				t.Errorf("air_quality = %+v, want it left out", *body.AirQuality)
+			case tt.want != nil && (body.AirQuality == nil || *body.AirQuality != *tt.want):
+				t.Errorf("air_quality = %+v, want %+v", body.AirQuality, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package serviceb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/callbackurl"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Notification channels an alert can pick.
+const (
+	channelWebhook = "webhook"
+	channelSlack   = "slack"
+	channelEmail   = "email"
+)
+
+// Notifier delivers fired alerts through one channel.
+type Notifier interface {
+	// Validate checks an alert's target when the alert is created.
+	Validate(target string) error
+	// Notify makes a single delivery attempt. Failures that another attempt
+	// cannot fix are wrapped with permanent.
+	Notify(ctx context.Context, target string, event AlertEvent) error
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error { return &permanentError{err} }
+
+// alertNotifier routes fired alerts to the notifier of their channel and
+// retries failed deliveries with exponential backoff.
+type alertNotifier struct {
+	channels    map[string]Notifier
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// channelFor returns the channel of an alert that names none: email for
+// "mailto:" targets and webhook otherwise.
+func channelFor(target string) string {
+	if strings.HasPrefix(target, "mailto:") {
+		return channelEmail
+	}
+	return channelWebhook
+}
+
+func (n *alertNotifier) validate(channel, target string) error {
+	notifier, ok := n.channels[channel]
+	if !ok {
+		if channel == channelEmail {
+			return errors.New("email alerts require SMTP_ADDR")
+		}
+		return fmt.Errorf("unknown channel %q (available: %v)", channel, slices.Sorted(maps.Keys(n.channels)))
+	}
+	return notifier.Validate(target)
+}
+
+func (n *alertNotifier) notify(ctx context.Context, alert Alert, event AlertEvent) error {
+	tracer := otel.Tracer("service-b/alerts")
+	ctx, span := tracer.Start(ctx, "notify-alert", trace.WithAttributes(
+		attribute.String("alert.id", alert.ID),
+		attribute.String("alert.channel", alert.Channel),
+	))
+	defer span.End()
+
+	notifier, ok := n.channels[alert.Channel]
+	if !ok {
+		err := fmt.Errorf("channel %q is not configured", alert.Channel)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "alert channel unavailable")
+		return err
+	}
+
+	backoff := n.backoff
+	var attempts []trace.SpanContext
+	for attempt := 1; ; attempt++ {
+		sc, err := n.attempt(ctx, notifier, alert.Target, event, attempt, attempts)
+		attempts = append(attempts, sc)
+		var perm *permanentError
+		if err == nil || errors.As(err, &perm) || attempt >= n.maxAttempts {
+			span.SetAttributes(attribute.Int("alert.notify.attempts", attempt))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "alert notification failed")
+			}
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			span.RecordError(ctx.Err())
+			span.SetStatus(codes.Error, "alert notification cancelled")
+			return ctx.Err()
+		}
+	}
+}
+
+func (n *alertNotifier) attempt(ctx context.Context, notifier Notifier, target string, event AlertEvent, attempt int, earlier []trace.SpanContext) (trace.SpanContext, error) {
+	tracer := otel.Tracer("service-b/alerts")
+	ctx, span := tracer.Start(ctx, "notify-alert-attempt", trace.WithAttributes(
+		attribute.Int("alert.notify.attempt", attempt),
+	), trace.WithLinks(retryLinks(earlier)...))
+	defer span.End()
+
+	if err := notifier.Notify(ctx, target, event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "alert notification attempt failed")
+		return span.SpanContext(), err
+	}
+	return span.SpanContext(), nil
+}
+
+// alertMessage is the text of the notifications sent to people, in
+// Portuguese like the rest of the alert emails.
+func alertMessage(event AlertEvent) (subject, body string) {
+	comparison := "acima de"
+	if event.Direction == alertBelow {
+		comparison = "abaixo de"
+	}
+	subject = fmt.Sprintf("Alerta de temperatura: %s %s %.1f°C", event.City, comparison, event.ThresholdC)
+	body = fmt.Sprintf("A temperatura em %s (CEP %s) está em %.1f°C, %s %.1f°C.\nObservado em %s.\n",
+		event.City, event.CEP, event.TempC, comparison, event.ThresholdC, event.ObservedAt.Format(time.RFC3339))
+	return subject, body
+}
+
+// webhookNotifier POSTs the event as JSON, signed like the lookup callbacks.
+type webhookNotifier struct {
+	callbacks *callbackDeliverer
+}
+
+func (n webhookNotifier) Validate(target string) error {
+	if !callbackurl.Valid(target) {
+		return errors.New("target must be an absolute http(s) URL or a mailto: address")
+	}
+	return nil
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, target string, event AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return permanent(err)
+	}
+	retry, err := n.callbacks.post(ctx, target, body)
+	if err != nil && !retry {
+		return permanent(err)
+	}
+	return err
+}
+
+// slackNotifier posts the alert message to a Slack incoming webhook.
+type slackNotifier struct {
+	client *http.Client
+}
+
+func (n slackNotifier) Validate(target string) error {
+	if !callbackurl.Valid(target) || !strings.HasPrefix(target, "https://") {
+		return errors.New("slack target must be an https incoming webhook URL")
+	}
+	return nil
+}
+
+func (n slackNotifier) Notify(ctx context.Context, target string, event AlertEvent) error {
+	subject, body := alertMessage(event)
+	payload, err := json.Marshal(map[string]string{"text": "*" + subject + "*\n" + body})
+	if err != nil {
+		return permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		err := fmt.Errorf("slack responded %s", resp.Status)
+		if !retryableStatus(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// mailSender sends alert emails through an SMTP relay.
+type mailSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func newMailSender(addr, from, username, password string) *mailSender {
+	s := &mailSender{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *mailSender) Validate(target string) error {
+	if _, err := mail.ParseAddress(strings.TrimPrefix(target, "mailto:")); err != nil {
+		return fmt.Errorf("invalid email target: %w", err)
+	}
+	return nil
+}
+
+func (s *mailSender) Notify(ctx context.Context, target string, event AlertEvent) error {
+	to := strings.TrimPrefix(target, "mailto:")
+	tracer := otel.Tracer("service-b/alerts")
+	_, span := tracer.Start(ctx, "send-alert-email", trace.WithAttributes(
+		attribute.String("alert.id", event.AlertID),
+		attribute.String("smtp.addr", s.addr),
+	))
+	defer span.End()
+
+	subject, body := alertMessage(event)
+	msg := "From: " + s.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+
+	// net/smtp takes no context, so cancellation is not honoured mid-send.
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(msg)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to send alert email")
+		// 5xx replies reject the message for good; 4xx ones are temporary.
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return permanent(err)
+		}
+		return err
+	}
+	return nil
+}
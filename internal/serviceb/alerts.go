@@ -0,0 +1,295 @@
+package serviceb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	alertAbove = "above"
+	alertBelow = "below"
+
+	maxAlertBodyBytes = 4 << 10
+)
+
+// Alert fires a notification when the temperature at CEP crosses ThresholdC
+// in Direction. It fires once per crossing and re-arms when the temperature
+// moves back.
+type Alert struct {
+	ID         string    `json:"id"`
+	CEP        string    `json:"cep"`
+	ThresholdC float64   `json:"threshold_c"`
+	Direction  string    `json:"direction"`
+	Channel    string    `json:"channel"`
+	Target     string    `json:"target"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Triggered       bool       `json:"triggered"`
+	LastTempC       *float64   `json:"last_temp_C,omitempty"`
+	LastCheckedAt   *time.Time `json:"last_checked_at,omitempty"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+}
+
+func (a Alert) crossed(tempC float64) bool {
+	if a.Direction == alertAbove {
+		return tempC > a.ThresholdC
+	}
+	return tempC < a.ThresholdC
+}
+
+// AlertEvent is the payload sent to an alert's target when it fires.
+type AlertEvent struct {
+	AlertID    string    `json:"alert_id"`
+	CEP        string    `json:"cep"`
+	City       string    `json:"city"`
+	Direction  string    `json:"direction"`
+	ThresholdC float64   `json:"threshold_c"`
+	TempC      float64   `json:"temp_C"`
+	ObservedAt time.Time `json:"observed_at"`
+	FiredAt    time.Time `json:"fired_at"`
+}
+
+type alertStore struct {
+	max int
+
+	mu     sync.Mutex
+	alerts map[string]Alert
+}
+
+func newAlertStore(max int) *alertStore {
+	return &alertStore{max: max, alerts: make(map[string]Alert)}
+}
+
+var errTooManyAlerts = errors.New("too many alerts")
+
+func (s *alertStore) add(alert Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.alerts) >= s.max {
+		return errTooManyAlerts
+	}
+	s.alerts[alert.ID] = alert
+	return nil
+}
+
+func (s *alertStore) get(id string) (Alert, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alert, ok := s.alerts[id]
+	return alert, ok
+}
+
+// update stores alert unless it was deleted while being evaluated.
+func (s *alertStore) update(alert Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.alerts[alert.ID]; ok {
+		s.alerts[alert.ID] = alert
+	}
+}
+
+func (s *alertStore) delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.alerts[id]
+	delete(s.alerts, id)
+	return ok
+}
+
+func (s *alertStore) list() []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alerts := make([]Alert, 0, len(s.alerts))
+	for _, alert := range s.alerts {
+		alerts = append(alerts, alert)
+	}
+	slices.SortFunc(alerts, func(a, b Alert) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return alerts
+}
+
+func newAlertID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("serviceb: reading random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+type alertRequest struct {
+	CEP        string   `json:"cep"`
+	ThresholdC *float64 `json:"threshold_c"`
+	Direction  string   `json:"direction"`
+	// Channel defaults to email for "mailto:" targets and webhook otherwise.
+	Channel string `json:"channel"`
+	Target  string `json:"target"`
+}
+
+func (s *server) createAlertHandler(w http.ResponseWriter, r *http.Request) {
+	var req alertRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAlertBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, "Malformed JSON")
+		return
+	}
+
+	cepCode, err := s.cepPolicy.Normalize(req.CEP)
+	if err != nil {
+		i18n.Error(w, r, http.StatusUnprocessableEntity, "invalid zipcode")
+		return
+	}
+	if req.ThresholdC == nil {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: threshold_c is required")
+		return
+	}
+	if req.Direction != alertAbove && req.Direction != alertBelow {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: direction must be %q or %q", alertAbove, alertBelow)
+		return
+	}
+	if req.Channel == "" {
+		req.Channel = channelFor(req.Target)
+	}
+	if err := s.notifier.validate(req.Channel, req.Target); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: %v", err)
+		return
+	}
+
+	alert := Alert{
+		ID:         newAlertID(),
+		CEP:        cepCode,
+		ThresholdC: *req.ThresholdC,
+		Direction:  req.Direction,
+		Channel:    req.Channel,
+		Target:     req.Target,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.alerts.add(alert); err != nil {
+		i18n.Error(w, r, http.StatusConflict, "Conflict: alert limit of %d reached", s.alerts.max)
+		return
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("alert.id", alert.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/alerts/"+alert.ID)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(r.Context(), w, alert)
+}
+
+func (s *server) listAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(r.Context(), w, s.alerts.list())
+}
+
+func (s *server) getAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alert, ok := s.alerts.get(r.PathValue("id"))
+	if !ok {
+		i18n.Error(w, r, http.StatusNotFound, "alert not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(r.Context(), w, alert)
+}
+
+func (s *server) deleteAlertHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.alerts.delete(r.PathValue("id")) {
+		i18n.Error(w, r, http.StatusNotFound, "alert not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runAlerts re-evaluates every alert each interval until ctx is done.
+func (s *server) runAlerts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkAlerts(ctx)
+		}
+	}
+}
+
+// checkAlerts runs one scheduler pass as its own trace.
+func (s *server) checkAlerts(ctx context.Context) {
+	alerts := s.alerts.list()
+	if len(alerts) == 0 {
+		return
+	}
+	tracer := otel.Tracer("service-b/alerts")
+	ctx, span := tracer.Start(ctx, "check-alerts", trace.WithNewRoot(), trace.WithAttributes(
+		attribute.Int("alerts.count", len(alerts)),
+	))
+	defer span.End()
+
+	fired := 0
+	for _, alert := range alerts {
+		if s.evaluateAlert(ctx, alert) {
+			fired++
+		}
+	}
+	span.SetAttributes(attribute.Int("alerts.fired", fired))
+}
+
+func (s *server) evaluateAlert(ctx context.Context, alert Alert) bool {
+	tracer := otel.Tracer("service-b/alerts")
+	ctx, span := tracer.Start(ctx, "evaluate-alert", trace.WithAttributes(
+		attribute.String("alert.id", alert.ID),
+		attribute.String("alert.cep", alert.CEP),
+		attribute.String("alert.direction", alert.Direction),
+		attribute.Float64("alert.threshold_c", alert.ThresholdC),
+	))
+	defer span.End()
+
+	result, err := s.lookupWeather(ctx, alert.CEP)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "weather lookup failed")
+		return false
+	}
+
+	now := time.Now().UTC()
+	tempC := result.response.TempC
+	alert.LastTempC, alert.LastCheckedAt = &tempC, &now
+	crossed := alert.crossed(tempC)
+	fire := crossed && !alert.Triggered
+	alert.Triggered = crossed
+	span.SetAttributes(attribute.Float64("weather.temp_c", tempC), attribute.Bool("alert.fired", fire))
+
+	if fire {
+		alert.LastTriggeredAt = &now
+		event := AlertEvent{
+			AlertID:    alert.ID,
+			CEP:        alert.CEP,
+			City:       result.response.City,
+			Direction:  alert.Direction,
+			ThresholdC: alert.ThresholdC,
+			TempC:      tempC,
+			ObservedAt: result.response.ObservedAt,
+			FiredAt:    now,
+		}
+		if err := s.notifier.notify(ctx, alert, event); err != nil {
+			log.Printf("Failed to notify alert %s: %v\n", alert.ID, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "alert notification failed")
+		}
+	}
+	s.alerts.update(alert)
+	return fire
+}
@@ -0,0 +1,294 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// brasiliaTime is the default calendar of ?date=. Brazil has had no
+// daylight saving time since 2019.
+var brasiliaTime = time.FixedZone("BRT", -3*60*60)
+
+// AstronomyResponse is the body of GET /weather/{cep}/astronomy. Times are
+// in the city's time zone and left out when the event does not happen on
+// date.
+type AstronomyResponse struct {
+	City     string `json:"city"`
+	UF       string `json:"uf,omitempty"`
+	Date     string `json:"date"`
+	TimeZone string `json:"timezone,omitempty"`
+
+	Sunrise  *time.Time `json:"sunrise,omitempty"`
+	Sunset   *time.Time `json:"sunset,omitempty"`
+	Moonrise *time.Time `json:"moonrise,omitempty"`
+	Moonset  *time.Time `json:"moonset,omitempty"`
+
+	MoonPhase        string `json:"moon_phase"`
+	MoonIllumination int    `json:"moon_illumination"`
+}
+
+// weatherViewHandler serves GET /weather/{cep}/{view}. The route cannot be
+// /weather/{cep}/astronomy, which would conflict with /weather/jobs/{id}.
+func (s *server) weatherViewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("view") != "astronomy" {
+		http.NotFound(w, r)
+		return
+	}
+	s.astronomyHandler(w, r)
+}
+
+// astronomyHandler answers with the sun and moon events of ?date= in the
+// city of the CEP, today in Brasília time by default. Events are cached per
+// city and date.
+func (s *server) astronomyHandler(w http.ResponseWriter, r *http.Request) {
+	date := time.Now().In(brasiliaTime)
+	if value := r.URL.Query().Get("date"); value != "" {
+		var err error
+		if date, err = time.ParseInLocation(time.DateOnly, value, brasiliaTime); err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, "Bad Request: date must be YYYY-MM-DD")
+			return
+		}
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	ctx := r.Context()
+	features := s.toggles.Get()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(features.Attributes()...)
+	span.SetAttributes(attribute.String("astronomy.date", date.Format(time.DateOnly)))
+
+	cepCode, err := s.cepPolicy.Normalize(r.PathValue("cep"))
+	if err != nil {
+		writeLookupError(w, r, lookupErrorFor(err, "Internal server error: %v"))
+		return
+	}
+	var result lookupResult
+	address, err := s.resolveAddress(ctx, features, &result, cepCode)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+
+	source := s.astronomy
+	if features.MockMode {
+		source = demoWeatherProvider{}
+	}
+	query := placename.WeatherQuery(address.City, address.UF)
+	astro, status, err := s.astronomyCache.get(ctx, source, query, date)
+	if err != nil {
+		writeLookupError(w, r, lookupErrorFor(err, "Internal server error getting astronomy: %v"))
+		return
+	}
+	span.SetAttributes(attribute.String("astronomy.cache", string(status)))
+
+	body := AstronomyResponse{
+		City:             address.City,
+		UF:               address.UF,
+		Date:             date.Format(time.DateOnly),
+		TimeZone:         astro.TimeZone,
+		Sunrise:          astro.Sunrise,
+		Sunset:           astro.Sunset,
+		Moonrise:         astro.Moonrise,
+		Moonset:          astro.Moonset,
+		MoonPhase:        astro.MoonPhase,
+		MoonIllumination: astro.MoonIllumination,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", string(status))
+	if address.Source != "" {
+		w.Header().Set("X-Resolved-By", address.Source)
+	}
+	for _, degraded := range result.degraded {
+		w.Header().Add("X-Degraded", degraded)
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// astronomyCacheTTL is how long the events of a day are kept. They do not
+// change, so this only bounds how long past days linger.
+const astronomyCacheTTL = 24 * time.Hour
+
+// astronomyCacheMax bounds the cached city and date pairs.
+const astronomyCacheMax = 10000
+
+type astronomyEntry struct {
+	astro   provider.Astronomy
+	expires time.Time
+}
+
+// astronomyCache keeps astronomy answers per weather query and date.
+type astronomyCache struct {
+	mu      sync.Mutex
+	entries map[string]astronomyEntry
+}
+
+func newAstronomyCache() *astronomyCache {
+	return &astronomyCache{entries: make(map[string]astronomyEntry)}
+}
+
+func (c *astronomyCache) get(ctx context.Context, source provider.AstronomyProvider, query string, date time.Time) (provider.Astronomy, cacheStatus, error) {
+	key := source.Name() + "|" + query + "|" + date.Format(time.DateOnly)
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.astro, cacheHit, nil
+	}
+
+	astro, err := source.Astronomy(ctx, query, date)
+	if err != nil {
+		return astro, cacheMiss, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= astronomyCacheMax {
+		for cached, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, cached)
+			}
+		}
+	}
+	if len(c.entries) < astronomyCacheMax {
+		c.entries[key] = astronomyEntry{astro: astro, expires: now.Add(astronomyCacheTTL)}
+	}
+	return astro, cacheMiss, nil
+}
+
+type weatherAPIAstronomyResponse struct {
+	Location struct {
+		Name string `json:"name"`
+		TzID string `json:"tz_id"`
+	} `json:"location"`
+	Astronomy struct {
+		Astro struct {
+			Sunrise   string `json:"sunrise"`
+			Sunset    string `json:"sunset"`
+			Moonrise  string `json:"moonrise"`
+			Moonset   string `json:"moonset"`
+			MoonPhase string `json:"moon_phase"`
+			// MoonIllumination has been sent both as a number and as a
+			// string.
+			MoonIllumination json.RawMessage `json:"moon_illumination"`
+		} `json:"astro"`
+	} `json:"astronomy"`
+	Error *weatherAPIError `json:"error,omitempty"`
+}
+
+func (p *weatherAPIProvider) Astronomy(ctx context.Context, location string, date time.Time) (provider.Astronomy, error) {
+	ctx, span := otel.Tracer("service-b/weatherapi-client").Start(ctx, "call-weather-api-astronomy", trace.WithAttributes(
+		attribute.String("provider.name", p.Name()),
+		attribute.String("weather.location.input", location),
+		attribute.String("astronomy.date", date.Format(time.DateOnly)),
+	))
+	defer span.End()
+
+	var body weatherAPIAstronomyResponse
+	params := url.Values{"q": {location}, "dt": {date.Format(time.DateOnly)}}
+	if err := p.get(ctx, span, "astronomy.json", params, &body, &body.Error); err != nil {
+		return provider.Astronomy{}, err
+	}
+
+	loc := brasiliaTime
+	if tz, err := time.LoadLocation(body.Location.TzID); body.Location.TzID != "" && err == nil {
+		loc = tz
+	}
+	astro := body.Astronomy.Astro
+	illumination, _ := strconv.Atoi(strings.Trim(string(astro.MoonIllumination), `"`))
+	span.SetStatus(codes.Ok, "astronomy found")
+	return provider.Astronomy{
+		Sunrise:          clockTime(date, astro.Sunrise, loc),
+		Sunset:           clockTime(date, astro.Sunset, loc),
+		Moonrise:         clockTime(date, astro.Moonrise, loc),
+		Moonset:          clockTime(date, astro.Moonset, loc),
+		MoonPhase:        astro.MoonPhase,
+		MoonIllumination: illumination,
+		Location:         body.Location.Name,
+		TimeZone:         body.Location.TzID,
+	}, nil
+}
+
+// clockTime places a WeatherAPI clock reading such as "05:59 AM" on date in
+// loc. Readings such as "No moonrise" give nil.
+func clockTime(date time.Time, clock string, loc *time.Location) *time.Time {
+	t, err := time.Parse(time.Kitchen, strings.ReplaceAll(clock, " ", ""))
+	if err != nil {
+		return nil
+	}
+	at := time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	return &at
+}
+
+// synodicMonth is the mean time between new moons, and knownNewMoon one of
+// them.
+const synodicMonth = time.Duration(29.530588853 * 24 * float64(time.Hour))
+
+var knownNewMoon = time.Date(2000, time.January, 6, 18, 14, 0, 0, time.UTC)
+
+// moonPhases are WeatherAPI's phase names, each an eighth of the cycle
+// centered on its point.
+var moonPhases = []string{
+	"New Moon", "Waxing Crescent", "First Quarter", "Waxing Gibbous",
+	"Full Moon", "Waning Gibbous", "Last Quarter", "Waning Crescent",
+}
+
+// Astronomy computes the moon's phase for noon of date; the sun rises at six
+// and sets at six, and the moon rises 50 minutes later each day of its cycle.
+func (demoWeatherProvider) Astronomy(ctx context.Context, location string, date time.Time) (provider.Astronomy, error) {
+	_, span := otel.Tracer("service-b/demo").Start(ctx, "demo-astronomy", trace.WithAttributes(
+		attribute.String("provider.name", "demo"),
+		attribute.String("weather.location.input", location),
+		attribute.Bool("demo.mode", true),
+	))
+	defer span.End()
+
+	location, _, _ = strings.Cut(location, ",")
+	if _, ok := demoTemperatures[placename.Fold(location)]; !ok {
+		span.SetStatus(codes.Error, "location not in demo data set")
+		return provider.Astronomy{}, provider.ErrNotFound
+	}
+
+	loc, err := time.LoadLocation(demoTimeZone)
+	if err != nil {
+		loc = brasiliaTime
+	}
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	age := day.Add(12*time.Hour).Sub(knownNewMoon) % synodicMonth
+	if age < 0 {
+		age += synodicMonth
+	}
+	cycle := float64(age) / float64(synodicMonth)
+	at := func(d time.Duration) *time.Time {
+		t := day.Add(d % (24 * time.Hour))
+		return &t
+	}
+	moonrise := 6*time.Hour + time.Duration(cycle*24*float64(time.Hour)).Truncate(time.Minute)
+	return provider.Astronomy{
+		Sunrise:          at(6 * time.Hour),
+		Sunset:           at(18 * time.Hour),
+		Moonrise:         at(moonrise),
+		Moonset:          at(moonrise + 12*time.Hour),
+		MoonPhase:        moonPhases[int(math.Round(cycle*8))%8],
+		MoonIllumination: int(math.Round((1 - math.Cos(2*math.Pi*cycle)) / 2 * 100)),
+		Location:         location,
+		TimeZone:         demoTimeZone,
+	}, nil
+}
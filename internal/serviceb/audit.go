@@ -0,0 +1,43 @@
+package serviceb
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/audit"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// auditLookup writes the audit record of a finished lookup; like
+// recordLookup, it is deferred by lookupWeather. The caller comes from the
+// baggage Service A attaches, so batch, stream and asynchronous lookups are
+// attributed to whoever started them.
+func (s *server) auditLookup(ctx context.Context, rawCEP string, start time.Time, result *lookupResult, err *error) {
+	bag := baggage.FromContext(ctx)
+	status := lookupStatus(*err)
+	rec := audit.Record{
+		Time:      start.UTC(),
+		Caller:    bag.Member("client.id").Value(),
+		Tenant:    bag.Member("tenant").Value(),
+		EndUser:   bag.Member("enduser.id").Value(),
+		CEP:       strings.TrimSpace(rawCEP),
+		Outcome:   audit.OutcomeFor(status),
+		Status:    status,
+		Cache:     string(result.cacheStatus),
+		LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+		RequestID: requestid.FromContext(ctx),
+	}
+	if normalized, nerr := s.cepPolicy.Normalize(rawCEP); nerr == nil {
+		rec.CEP = normalized
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		rec.TraceID = sc.TraceID().String()
+	}
+	if err := s.audit.Log(rec); err != nil {
+		log.Printf("Failed to write audit record: %v\n", err)
+	}
+}
@@ -0,0 +1,114 @@
+package serviceb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BrasilAPIResponse is the body of BrasilAPI's /api/cep/v2/{cep}, which
+// reports coordinates as strings and leaves them out when unknown.
+type BrasilAPIResponse struct {
+	State    string `json:"state"`
+	City     string `json:"city"`
+	Location struct {
+		Coordinates struct {
+			Latitude  string `json:"latitude"`
+			Longitude string `json:"longitude"`
+		} `json:"coordinates"`
+	} `json:"location"`
+}
+
+func init() {
+	provider.RegisterCEPProvider("brasilapi", func(deps provider.Deps) (provider.CEPProvider, error) {
+		baseURL, err := upstreamBaseURL(deps.Getenv, "BRASILAPI_URL", brasilAPIHost)
+		if err != nil {
+			return nil, err
+		}
+		return &brasilAPIProvider{baseURL: baseURL, client: deps.Client}, nil
+	})
+}
+
+type brasilAPIProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *brasilAPIProvider) Name() string { return "brasilapi" }
+
+func (p *brasilAPIProvider) Locate(ctx context.Context, cep string) (string, error) {
+	address, err := p.Address(ctx, cep)
+	return address.City, err
+}
+
+func (p *brasilAPIProvider) Address(ctx context.Context, cep string) (provider.Address, error) {
+	tracer := otel.Tracer("service-b/brasilapi-client")
+	ctx, span := tracer.Start(ctx, "call-brasilapi", trace.WithAttributes(
+		attribute.String("provider.name", p.Name()),
+		attribute.String("cep.input", cep),
+	))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/cep/v2/%s", p.baseURL, cep), nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create brasilapi request")
+		return provider.Address{}, fmt.Errorf("error creating BrasilAPI request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to call brasilapi")
+		return provider.Address{}, fmt.Errorf("%w: error fetching CEP data: %w", provider.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		span.SetStatus(codes.Error, "brasilapi unavailable")
+		return provider.Address{}, provider.NewStatusError("BrasilAPI", resp)
+	case resp.StatusCode == http.StatusNotFound:
+		span.SetStatus(codes.Error, "brasilapi cep not found")
+		return provider.Address{}, provider.ErrNotFound
+	case resp.StatusCode == http.StatusBadRequest:
+		span.SetStatus(codes.Error, "brasilapi rejected cep")
+		return provider.Address{}, fmt.Errorf("%w: BrasilAPI responded %s", provider.ErrInvalidCEP, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		span.SetStatus(codes.Error, "brasilapi rejected request")
+		return provider.Address{}, provider.NewStatusError("BrasilAPI", resp)
+	}
+
+	var body BrasilAPIResponse
+	if err := decodeUpstream(span, "BrasilAPI", resp, &body); err != nil {
+		span.SetStatus(codes.Error, "failed to decode brasilapi response")
+		return provider.Address{}, err
+	}
+	if body.City == "" {
+		span.SetStatus(codes.Error, "brasilapi returned empty city")
+		return provider.Address{}, provider.ErrNotFound
+	}
+
+	address := provider.Address{City: body.City, UF: body.State}
+	lat, latErr := strconv.ParseFloat(body.Location.Coordinates.Latitude, 64)
+	lon, lonErr := strconv.ParseFloat(body.Location.Coordinates.Longitude, 64)
+	if latErr == nil && lonErr == nil {
+		address.Latitude, address.Longitude = &lat, &lon
+	}
+	span.SetAttributes(
+		attribute.String("brasilapi.location", body.City),
+		attribute.Bool("brasilapi.coordinates", address.Latitude != nil),
+	)
+	span.SetStatus(codes.Ok, "location found")
+	return address, nil
+}
@@ -0,0 +1,270 @@
+package serviceb
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cachemetrics"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type cacheStatus string
+
+const (
+	cacheHit   cacheStatus = "HIT"
+	cacheStale cacheStatus = "STALE"
+	cacheMiss  cacheStatus = "MISS"
+)
+
+const cacheRefreshTimeout = 10 * time.Second
+
+// weatherCacheMax bounds the weather cache, so lookups spread over many
+// locations cannot grow it without limit. The least recently used entries
+// are evicted first.
+const weatherCacheMax = 10000
+
+type weatherCacheEntry struct {
+	key         string
+	observation provider.Observation
+	fetchedAt   time.Time
+}
+
+// weatherCache keeps WeatherAPI observations per city. Entries younger than
+// ttl are served as-is; entries younger than ttl+staleTTL are served while a
+// background refresh replaces them.
+type weatherCache struct {
+	ttl      time.Duration
+	staleTTL time.Duration
+	fetch    func(context.Context, string) (provider.Observation, error)
+	metrics  *cachemetrics.Metrics
+
+	// entries indexes recent, which is ordered most recently used first and
+	// holds at most maxEntries entries.
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	recent     *list.List
+	maxEntries int
+	refreshing map[string]bool
+
+	hits, stale, misses, evictions atomic.Int64
+}
+
+func newWeatherCache(ttl, staleTTL time.Duration, fetch func(context.Context, string) (provider.Observation, error), metrics *cachemetrics.Metrics) *weatherCache {
+	return &weatherCache{
+		ttl:        ttl,
+		staleTTL:   staleTTL,
+		fetch:      fetch,
+		metrics:    metrics,
+		entries:    make(map[string]*list.Element),
+		recent:     list.New(),
+		maxEntries: weatherCacheMax,
+		refreshing: make(map[string]bool),
+	}
+}
+
+func cacheKey(location string) string {
+	return strings.ToLower(strings.TrimSpace(location))
+}
+
+// Get returns the temperature for location along with how it was served and
+// the age of the returned value.
+func (c *weatherCache) Get(ctx context.Context, location string) (provider.Observation, cacheStatus, time.Duration, error) {
+	if c.ttl <= 0 {
+		c.misses.Add(1)
+		c.metrics.Miss(ctx)
+		obs, err := c.fetch(ctx, location)
+		return obs, cacheMiss, 0, err
+	}
+
+	key := cacheKey(location)
+	now := time.Now()
+
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		entry := el.Value.(*weatherCacheEntry)
+		c.recent.MoveToFront(el)
+		age := now.Sub(entry.fetchedAt)
+		if age < c.ttl {
+			c.mu.Unlock()
+			c.metrics.Operation(ctx, cachemetrics.OperationGet, now)
+			c.hits.Add(1)
+			c.metrics.Hit(ctx, false)
+			return entry.observation, cacheHit, age, nil
+		}
+		if age < c.ttl+c.staleTTL {
+			if !c.refreshing[key] {
+				c.refreshing[key] = true
+				go c.refresh(context.WithoutCancel(ctx), key, location)
+			}
+			c.mu.Unlock()
+			c.metrics.Operation(ctx, cachemetrics.OperationGet, now)
+			c.stale.Add(1)
+			c.metrics.Hit(ctx, true)
+			return entry.observation, cacheStale, age, nil
+		}
+		c.remove(el)
+	}
+	c.mu.Unlock()
+	c.metrics.Operation(ctx, cachemetrics.OperationGet, now)
+	if ok {
+		c.evictions.Add(1)
+		c.metrics.Evicted(ctx, 1)
+	}
+	c.misses.Add(1)
+	c.metrics.Miss(ctx)
+
+	obs, err := c.fetch(ctx, location)
+	if err != nil {
+		return provider.Observation{}, cacheMiss, 0, err
+	}
+	c.store(ctx, key, obs)
+	return obs, cacheMiss, 0, nil
+}
+
+// Delete drops the cached observation for location, reporting whether one
+// was present.
+func (c *weatherCache) Delete(ctx context.Context, location string) bool {
+	defer c.metrics.Operation(ctx, cachemetrics.OperationDelete, time.Now())
+	key := cacheKey(location)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok {
+		c.remove(el)
+	}
+	return ok
+}
+
+// remove drops el from the cache; c.mu must be held.
+func (c *weatherCache) remove(el *list.Element) {
+	delete(c.entries, c.recent.Remove(el).(*weatherCacheEntry).key)
+}
+
+func (c *weatherCache) refresh(ctx context.Context, key, location string) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.refreshing, key)
+		c.mu.Unlock()
+	}()
+
+	tracer := otel.Tracer("service-b/weather-cache")
+	ctx, span := tracer.Start(ctx, "refresh-weather-cache", trace.WithAttributes(
+		attribute.String("weather.location.input", location),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, cacheRefreshTimeout)
+	defer cancel()
+
+	obs, err := c.fetch(ctx, location)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to refresh cached weather")
+		return
+	}
+	c.store(ctx, key, obs)
+	span.SetStatus(codes.Ok, "cache refreshed")
+}
+
+func (c *weatherCache) store(ctx context.Context, key string, obs provider.Observation) {
+	defer c.metrics.Operation(ctx, cachemetrics.OperationSet, time.Now())
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		*el.Value.(*weatherCacheEntry) = weatherCacheEntry{key: key, observation: obs, fetchedAt: time.Now()}
+		c.recent.MoveToFront(el)
+		c.mu.Unlock()
+		return
+	}
+	c.entries[key] = c.recent.PushFront(&weatherCacheEntry{key: key, observation: obs, fetchedAt: time.Now()})
+	evicted := 0
+	for c.recent.Len() > c.maxEntries {
+		c.remove(c.recent.Back())
+		evicted++
+	}
+	c.mu.Unlock()
+	if evicted > 0 {
+		c.evictions.Add(int64(evicted))
+		c.metrics.Evicted(ctx, evicted)
+	}
+}
+
+// Stats reports how lookups were served since startup.
+func (c *weatherCache) Stats() map[string]int64 {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return map[string]int64{
+		"hits":      c.hits.Load(),
+		"stale":     c.stale.Load(),
+		"misses":    c.misses.Load(),
+		"evictions": c.evictions.Load(),
+		"entries":   int64(entries),
+	}
+}
+
+// CacheEntry describes a cached observation for /admin/cache.
+type CacheEntry struct {
+	Location   string    `json:"location"`
+	TempC      float64   `json:"temp_C"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	AgeSeconds float64   `json:"age_seconds"`
+	Stale      bool      `json:"stale"`
+}
+
+func (c *weatherCache) Entries() []CacheEntry {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]CacheEntry, 0, len(c.entries))
+	for el := c.recent.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*weatherCacheEntry)
+		age := now.Sub(entry.fetchedAt)
+		entries = append(entries, CacheEntry{
+			Location:   entry.key,
+			TempC:      entry.observation.TempC,
+			FetchedAt:  entry.fetchedAt.UTC(),
+			AgeSeconds: age.Seconds(),
+			Stale:      age >= c.ttl,
+		})
+	}
+	slices.SortFunc(entries, func(a, b CacheEntry) int { return strings.Compare(a.Location, b.Location) })
+	return entries
+}
+
+// Clear drops every cached observation and returns how many there were.
+func (c *weatherCache) Clear(ctx context.Context) int {
+	defer c.metrics.Operation(ctx, cachemetrics.OperationDelete, time.Now())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	clear(c.entries)
+	c.recent.Init()
+	return n
+}
+
+// Warm fetches location again when its entry is missing or expires within
+// horizon. It reports whether a fetch was made.
+func (c *weatherCache) Warm(ctx context.Context, location string, horizon time.Duration) bool {
+	key := cacheKey(location)
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if (ok && time.Since(el.Value.(*weatherCacheEntry).fetchedAt)+horizon < c.ttl) || c.refreshing[key] {
+		c.mu.Unlock()
+		return false
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	c.refresh(ctx, key, location)
+	return true
+}
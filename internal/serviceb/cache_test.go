@@ -0,0 +1,44 @@
+package serviceb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cachemetrics"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+)
+
+func TestWeatherCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	fetches := 0
+	c := newWeatherCache(time.Minute, 0, func(context.Context, string) (provider.Observation, error) {
+		fetches++
+		return provider.Observation{TempC: 20}, nil
+	}, cachemetrics.New(nil, "weather", "memory"))
+	c.maxEntries = 3
+	for _, location := range []string{"a", "b", "c"} {
+		c.Get(ctx, location)
+	}
+	// Using a makes b the least recently used.
+	if _, status, _, _ := c.Get(ctx, "a"); status != cacheHit {
+		t.Fatalf("a was served %s, want %s", status, cacheHit)
+	}
+	c.Get(ctx, "d")
+
+	if len(c.entries) != 3 || c.recent.Len() != 3 {
+		t.Fatalf("kept %d entries (%d in order), want 3", len(c.entries), c.recent.Len())
+	}
+	if got := c.Stats()["evictions"]; got != 1 {
+		t.Errorf("evictions = %d, want 1", got)
+	}
+	fetches = 0
+	for _, location := range []string{"a", "c", "d"} {
+		if _, status, _, _ := c.Get(ctx, location); status != cacheHit {
+			t.Errorf("%s was served %s, want %s", location, status, cacheHit)
+		}
+	}
+	if _, status, _, _ := c.Get(ctx, "b"); status != cacheMiss || fetches != 1 {
+		t.Errorf("b was served %s after %d fetches, want a single %s", status, fetches, cacheMiss)
+	}
+}
@@ -0,0 +1,171 @@
+package serviceb
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/callbackurl"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// callbackDeliverer POSTs lookup results to client supplied URLs. Bodies are
+// signed with HMAC-SHA256 over "<timestamp>.<body>" when a secret is set, and
+// network errors, 429s and 5xx responses are retried with exponential backoff.
+// client must refuse non-public addresses, see callbackurl.Guard.
+type callbackDeliverer struct {
+	client      *http.Client
+	secret      []byte
+	maxAttempts int
+	backoff     time.Duration
+	// pending holds a token for each background delivery under way, so
+	// at most cap(pending) run at once.
+	pending chan struct{}
+}
+
+// reserve takes a slot for a background delivery, reporting false when
+// all of them are taken. The delivery calls release when done.
+func (d *callbackDeliverer) reserve() bool {
+	select {
+	case d.pending <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *callbackDeliverer) release() { <-d.pending }
+
+func (d *callbackDeliverer) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver sends payload to callbackURL. ctx should carry the span of the
+// originating request; the delivery span becomes its child and links back
+// to it.
+func (d *callbackDeliverer) Deliver(ctx context.Context, callbackURL string, payload any) error {
+	tracer := otel.Tracer("service-b/callbacks")
+	ctx, span := tracer.Start(ctx, "deliver-callback",
+		trace.WithLinks(trace.LinkFromContext(ctx, attribute.String("link.reason", "callback origin"))),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to encode callback")
+		return err
+	}
+
+	backoff := d.backoff
+	var attempts []trace.SpanContext
+	for attempt := 1; ; attempt++ {
+		retry, sc, err := d.attempt(ctx, callbackURL, body, attempt, attempts)
+		attempts = append(attempts, sc)
+		if err == nil {
+			span.SetAttributes(attribute.Int("callback.attempts", attempt))
+			return nil
+		}
+		if !retry || attempt >= d.maxAttempts {
+			span.SetAttributes(attribute.Int("callback.attempts", attempt))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "callback delivery failed")
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			span.RecordError(ctx.Err())
+			span.SetStatus(codes.Error, "callback delivery cancelled")
+			return ctx.Err()
+		}
+	}
+}
+
+// attempt makes one delivery under its own span, linked to the spans of the
+// earlier attempts, and returns that span's context for the next one.
+func (d *callbackDeliverer) attempt(ctx context.Context, callbackURL string, body []byte, attempt int, earlier []trace.SpanContext) (bool, trace.SpanContext, error) {
+	tracer := otel.Tracer("service-b/callbacks")
+	ctx, span := tracer.Start(ctx, "callback-attempt", trace.WithAttributes(
+		attribute.Int("callback.attempt", attempt),
+	), trace.WithLinks(retryLinks(earlier)...))
+	defer span.End()
+
+	retry, err := d.post(ctx, callbackURL, body)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "callback delivery attempt failed")
+	}
+	return retry, span.SpanContext(), err
+}
+
+// retryLinks links a retry's span to the first attempt and, from the third
+// attempt on, to the one right before it, so the trace UI can walk from any
+// retry back through the earlier attempts.
+func retryLinks(earlier []trace.SpanContext) []trace.Link {
+	if len(earlier) == 0 {
+		return nil
+	}
+	links := []trace.Link{{
+		SpanContext: earlier[0],
+		Attributes:  []attribute.KeyValue{attribute.String("link.reason", "original attempt")},
+	}}
+	if len(earlier) > 1 {
+		links = append(links, trace.Link{
+			SpanContext: earlier[len(earlier)-1],
+			Attributes:  []attribute.KeyValue{attribute.String("link.reason", "previous attempt")},
+		})
+	}
+	return links
+}
+
+// post makes a single signed delivery. retry reports whether a failure is
+// worth another attempt.
+func (d *callbackDeliverer) post(ctx context.Context, callbackURL string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(d.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		req.Header.Set("X-Signature-256", d.sign(timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, callbackurl.ErrForbiddenAddress), err
+	}
+	defer resp.Body.Close()
+
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		return retryableStatus(resp.StatusCode), fmt.Errorf("callback responded %s", resp.Status)
+	}
+	return false, nil
+}
+
+// retryableStatus reports whether a failed delivery answered with code is
+// worth retrying: rate limiting and server errors are, rejections are not.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,50 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/callbackurl"
+)
+
+func TestWeatherHandlerBoundsPendingCallbacks(t *testing.T) {
+	svc := newTestService(t, fakeUpstreams(t), map[string]string{"CALLBACK_MAX_PENDING": "1"})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		target := "/weather/" + testKnownCEP + "?callback_url=http://127.0.0.1:1/hook"
+		svc.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if !svc.srv.callbacks.reserve() {
+		t.Fatal("no delivery slot free")
+	}
+	if w := get(); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("with every slot taken answered %d (Retry-After %q), want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	svc.srv.callbacks.release()
+	if w := get(); w.Code != http.StatusOK || w.Header().Get("X-Callback-Job-Id") == "" {
+		t.Fatalf("with a free slot answered %d (X-Callback-Job-Id %q), want 200 with the job ID", w.Code, w.Header().Get("X-Callback-Job-Id"))
+	}
+}
+
+func TestCallbackDeliveryRefusesLoopback(t *testing.T) {
+	var hits int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer receiver.Close()
+	d := &callbackDeliverer{
+		client:      &http.Client{Transport: callbackurl.NewGuard(nil).Transport()},
+		maxAttempts: 3,
+	}
+
+	err := d.Deliver(context.Background(), receiver.URL, map[string]string{"status": "done"})
+	if !errors.Is(err, callbackurl.ErrForbiddenAddress) {
+		t.Fatalf("Deliver error = %v, want ErrForbiddenAddress", err)
+	}
+	if hits != 0 {
+		t.Errorf("receiver got %d requests, want none", hits)
+	}
+}
@@ -0,0 +1,122 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cepdb"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sourceLocalDB is the address source of CEPs answered by the CEP database.
+const sourceLocalDB = "local-db"
+
+// maxCEPDBImportBytes caps the body of POST /admin/cepdb.
+const maxCEPDBImportBytes = 256 << 20
+
+// storedAddress looks cepCode up in the CEP database. A database error is
+// logged and treated as a miss, so the CEP provider is asked instead.
+func (s *server) storedAddress(ctx context.Context, cepCode string) (provider.Address, bool) {
+	record, ok, err := s.cepDB.Get(ctx, cepCode)
+	if err != nil {
+		log.Printf("Failed to read CEP database: %v\n", err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cepdb.hit", ok))
+	if !ok {
+		return provider.Address{}, false
+	}
+	return provider.Address{
+		City:      record.City,
+		UF:        record.UF,
+		IBGECode:  record.IBGECode,
+		Latitude:  record.Latitude,
+		Longitude: record.Longitude,
+		DDD:       record.DDD,
+		SIAFI:     record.SIAFI,
+		Source:    sourceLocalDB,
+	}, true
+}
+
+// storeAddress seeds the CEP database with what the CEP provider resolved
+// cepCode to.
+func (s *server) storeAddress(ctx context.Context, cepCode string, address provider.Address) {
+	if address.City == "" {
+		return
+	}
+	err := s.cepDB.Put(ctx, cepdb.Record{
+		CEP:       cepCode,
+		City:      address.City,
+		UF:        address.UF,
+		IBGECode:  address.IBGECode,
+		DDD:       address.DDD,
+		SIAFI:     address.SIAFI,
+		Latitude:  address.Latitude,
+		Longitude: address.Longitude,
+	})
+	if err != nil {
+		log.Printf("Failed to store CEP %s in CEP database: %v\n", cepCode, err)
+	}
+}
+
+// checkCEPRecord rejects imported records without a valid CEP or a city,
+// and normalizes the CEP of the others.
+func checkCEPRecord(record *cepdb.Record) error {
+	normalized, err := cep.Normalize(record.CEP)
+	if err != nil {
+		return err
+	}
+	if record.City == "" {
+		return errors.New("city is required")
+	}
+	record.CEP = normalized
+	return nil
+}
+
+// preloadCEPs loads the CSV dataset at path into db.
+func preloadCEPs(ctx context.Context, db *cepdb.DB, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open CEP preload: %w", err)
+	}
+	defer f.Close()
+	start := time.Now()
+	stored, skipped, err := db.ImportCSV(ctx, f, checkCEPRecord)
+	if err != nil {
+		return fmt.Errorf("failed to preload CEPs from %s: %w", path, err)
+	}
+	log.Printf("CEP preload: %d CEPs stored, %d rows skipped, in %v\n", stored, skipped, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// cepDBHandler serves GET /admin/cepdb, a JSON lines snapshot of the CEP
+// database, and POST /admin/cepdb, which loads one.
+func (s *server) cepDBHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		imported, err := s.cepDB.Import(r.Context(), http.MaxBytesReader(w, r.Body, maxCEPDBImportBytes), checkCEPRecord)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+			return
+		}
+		log.Printf("CEP database import: %d CEPs stored\n", imported)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]int{"imported": imported}); err != nil {
+			log.Printf("Error encoding JSON response: %v\n", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="cepdb.jsonl"`)
+	if err := s.cepDB.Export(r.Context(), w); err != nil {
+		log.Printf("Error exporting CEP database: %v\n", err)
+	}
+}
@@ -0,0 +1,116 @@
+package serviceb
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sourceCEPRange is the address source of addresses inferred from the CEP
+// range.
+const sourceCEPRange = "cep-range"
+
+// resolutionApproximate marks responses whose address was inferred from
+// the CEP range rather than resolved by a CEP provider.
+const resolutionApproximate = "approximate"
+
+// cepRange is a span of CEP prefixes, the first five digits, that belong to
+// a state or, when city is set, to its capital.
+type cepRange struct {
+	from, to int
+	uf, city string
+}
+
+// capitalRanges are the Correios ranges of the state capitals, checked
+// before stateRanges. The Federal District is Brasília throughout.
+var capitalRanges = []cepRange{
+	{1000, 5999, "SP", "São Paulo"}, {8000, 8499, "SP", "São Paulo"},
+	{20000, 23799, "RJ", "Rio de Janeiro"},
+	{29000, 29099, "ES", "Vitória"},
+	{30000, 31999, "MG", "Belo Horizonte"},
+	{40000, 42599, "BA", "Salvador"},
+	{49000, 49099, "SE", "Aracaju"},
+	{50000, 52999, "PE", "Recife"},
+	{57000, 57099, "AL", "Maceió"},
+	{58000, 58099, "PB", "João Pessoa"},
+	{59000, 59139, "RN", "Natal"},
+	{60000, 61599, "CE", "Fortaleza"},
+	{64000, 64099, "PI", "Teresina"},
+	{65000, 65109, "MA", "São Luís"},
+	{66000, 66999, "PA", "Belém"},
+	{68900, 68914, "AP", "Macapá"},
+	{69000, 69099, "AM", "Manaus"},
+	{69300, 69339, "RR", "Boa Vista"},
+	{69900, 69923, "AC", "Rio Branco"},
+	{70000, 72799, "DF", "Brasília"}, {73000, 73699, "DF", "Brasília"},
+	{74000, 74899, "GO", "Goiânia"},
+	{76800, 76834, "RO", "Porto Velho"},
+	{77000, 77270, "TO", "Palmas"},
+	{78000, 78109, "MT", "Cuiabá"},
+	{79000, 79129, "MS", "Campo Grande"},
+	{80000, 82999, "PR", "Curitiba"},
+	{88000, 88099, "SC", "Florianópolis"},
+	{90000, 91999, "RS", "Porto Alegre"},
+}
+
+// stateRanges are the Correios ranges of each state.
+var stateRanges = []cepRange{
+	{1000, 19999, "SP", ""},
+	{20000, 28999, "RJ", ""},
+	{29000, 29999, "ES", ""},
+	{30000, 39999, "MG", ""},
+	{40000, 48999, "BA", ""},
+	{49000, 49999, "SE", ""},
+	{50000, 56999, "PE", ""},
+	{57000, 57999, "AL", ""},
+	{58000, 58999, "PB", ""},
+	{59000, 59999, "RN", ""},
+	{60000, 63999, "CE", ""},
+	{64000, 64999, "PI", ""},
+	{65000, 65999, "MA", ""},
+	{66000, 68899, "PA", ""},
+	{68900, 68999, "AP", ""},
+	{69000, 69299, "AM", ""}, {69400, 69899, "AM", ""},
+	{69300, 69399, "RR", ""},
+	{69900, 69999, "AC", ""},
+	{72800, 72999, "GO", ""}, {73700, 76799, "GO", ""},
+	{76800, 76999, "RO", ""},
+	{77000, 77999, "TO", ""},
+	{78000, 78899, "MT", ""},
+	{79000, 79999, "MS", ""},
+	{80000, 87999, "PR", ""},
+	{88000, 89999, "SC", ""},
+	{90000, 99999, "RS", ""},
+}
+
+// approximateAddress infers the state of cepCode, and the city when it is
+// a capital's, from its range. It is the CEP provider's fallback under the
+// fallback-provider degradation mode, so it only answers when the providers
+// are down.
+func approximateAddress(ctx context.Context, cepCode string) (provider.Address, error) {
+	_, span := otel.Tracer("service-b/ceprange").Start(ctx, "infer-cep-range", trace.WithAttributes(
+		attribute.String("provider.name", "ceprange"),
+	))
+	defer span.End()
+
+	prefix, err := strconv.Atoi(cepCode[:min(len(cepCode), 5)])
+	if err != nil || len(cepCode) != 8 {
+		span.SetStatus(codes.Error, "invalid CEP")
+		return provider.Address{}, provider.ErrInvalidCEP
+	}
+	for _, ranges := range [][]cepRange{capitalRanges, stateRanges} {
+		for _, r := range ranges {
+			if prefix >= r.from && prefix <= r.to {
+				span.SetAttributes(attribute.String("cep.range.uf", r.uf), attribute.Bool("cep.range.capital", r.city != ""))
+				return provider.Address{City: r.city, UF: r.uf, Source: sourceCEPRange}, nil
+			}
+		}
+	}
+	span.SetStatus(codes.Error, "CEP outside known ranges")
+	return provider.Address{}, provider.ErrNotFound
+}
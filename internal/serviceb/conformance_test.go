@@ -0,0 +1,47 @@
+package serviceb
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider/providertest"
+)
+
+func TestViaCEPConformance(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	providertest.CEPSuite{
+		New: func(client *http.Client) provider.CEPProvider {
+			return &viaCEPProvider{name: "viacep", baseURL: upstream.URL, client: client}
+		},
+		Upstream:   upstream.Client().Transport,
+		KnownCEP:   testKnownCEP,
+		KnownCity:  testKnownCity,
+		UnknownCEP: testUnknownCEP,
+	}.Run(t)
+}
+
+func TestBrasilAPIConformance(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	providertest.CEPSuite{
+		New: func(client *http.Client) provider.CEPProvider {
+			return &brasilAPIProvider{baseURL: upstream.URL, client: client}
+		},
+		Upstream:   upstream.Client().Transport,
+		KnownCEP:   testKnownCEP,
+		KnownCity:  testKnownCity,
+		UnknownCEP: testUnknownCEP,
+	}.Run(t)
+}
+
+func TestWeatherAPIConformance(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	providertest.WeatherSuite{
+		New: func(client *http.Client) provider.WeatherProvider {
+			return newWeatherAPIProvider(client, upstream.URL, "test")
+		},
+		Upstream:        upstream.Client().Transport,
+		KnownLocation:   testKnownCity,
+		UnknownLocation: testUnknownCity,
+	}.Run(t)
+}
@@ -0,0 +1,80 @@
+package serviceb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errInvalidCoordinates = errors.New("invalid coordinates")
+
+// coordsHandler serves GET /weather/coords?lat=..&lon=.., which skips CEP
+// resolution and asks the weather provider for the coordinates directly.
+func (s *server) coordsHandler(w http.ResponseWriter, r *http.Request) {
+	lat, lon, err := parseCoordinates(r.URL.Query().Get("lat") + "," + r.URL.Query().Get("lon"))
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: lat and lon must be decimal degrees within range")
+		return
+	}
+	opts, ok := s.renderOptions(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	features := s.toggles.Get()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(features.Attributes()...)
+	span.SetAttributes(attribute.Float64("weather.latitude", lat), attribute.Float64("weather.longitude", lon))
+
+	result := lookupResult{cacheStatus: cacheMiss}
+	address := provider.Address{Latitude: &lat, Longitude: &lon}
+	if err := s.currentWeather(ctx, features, &result, address, coordinatesQuery(lat, lon)); err != nil {
+		var lerr *lookupError
+		if errors.As(err, &lerr) && lerr.status == http.StatusNotFound {
+			err = newLookupError(http.StatusNotFound, "can not find location")
+		}
+		writeLookupError(w, r, err)
+		return
+	}
+	s.writeWeather(w, r, result, opts)
+}
+
+// parseCoordinates reads "lat,lon" in decimal degrees.
+func parseCoordinates(value string) (lat, lon float64, err error) {
+	latText, lonText, ok := strings.Cut(value, ",")
+	if !ok {
+		return 0, 0, errInvalidCoordinates
+	}
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	if latErr != nil || lonErr != nil || !(lat >= -90 && lat <= 90) || !(lon >= -180 && lon <= 180) {
+		return 0, 0, errInvalidCoordinates
+	}
+	return lat, lon, nil
+}
+
+// coordinatesGrid is the cell size, in degrees, coordinates are rounded to
+// before they reach WeatherAPI and the weather cache (about 1.1 km), so
+// GPS readings that differ in the last decimals share one cache entry.
+const coordinatesGrid = 0.01
+
+// coordinatesQuery is the "lat,lon" query WeatherAPI accepts, snapped to
+// coordinatesGrid.
+func coordinatesQuery(lat, lon float64) string {
+	return fmt.Sprintf("%.2f,%.2f", snapToGrid(lat), snapToGrid(lon))
+}
+
+func snapToGrid(degrees float64) float64 {
+	// Adding 0 turns -0 into 0, so both sides of the equator and the prime
+	// meridian share the cell.
+	return math.Round(degrees/coordinatesGrid)*coordinatesGrid + 0
+}
@@ -0,0 +1,21 @@
+package serviceb
+
+import "testing"
+
+func TestCoordinatesQuerySnapsToGrid(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{-23.5505, -46.6333, "-23.55,-46.63"},
+		{-23.5549, -46.6301, "-23.55,-46.63"},
+		{-23.5551, -46.6333, "-23.56,-46.63"},
+		{-0.001, 0.004, "0.00,0.00"},
+		{90, -180, "90.00,-180.00"},
+	}
+	for _, tt := range tests {
+		if got := coordinatesQuery(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("coordinatesQuery(%v, %v) = %q, want %q", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package serviceb
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type degradationMode string
+
+const (
+	degradeFail             degradationMode = "fail"
+	degradeStaleCache       degradationMode = "stale-cache"
+	degradeFallbackProvider degradationMode = "fallback-provider"
+	degradeDefaultValue     degradationMode = "default-value"
+)
+
+// Address sources of CEPs answered by the stale-cache and default-value
+// modes, or by a tracked CEP's cached entry.
+const (
+	sourceCache   = "cache"
+	sourceDefault = "default"
+)
+
+// lastKnownMax bounds the values kept for the stale-cache mode, so a client
+// walking through random CEPs cannot grow them without limit. The least
+// recently used are dropped first.
+const lastKnownMax = 10000
+
+type degradationRule struct {
+	mode  degradationMode
+	value string
+}
+
+// degradationController decides how each upstream dependency failure is
+// answered, based on the operator supplied degradation matrix.
+type degradationController struct {
+	rules map[string]degradationRule
+
+	// onDegrade, when set, observes every degradation decision. Failure-mode
+	// tests hook a faultinject.Recorder here.
+	onDegrade func(dependency, mode string, cause error)
+
+	// lastKnown holds the last value of each dependency with a stale-cache
+	// rule, per key, indexing recent, which is ordered most recently used
+	// first and holds at most maxLastKnown entries.
+	mu           sync.Mutex
+	lastKnown    map[string]*list.Element
+	recent       *list.List
+	maxLastKnown int
+}
+
+type lastKnownValue struct {
+	key   string
+	value any
+}
+
+// parseDegradationMatrix reads specs such as
+// "viacep=stale-cache,weatherapi=default-value:25".
+func parseDegradationMatrix(spec string) (map[string]degradationRule, error) {
+	rules := make(map[string]degradationRule)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		dependency, behavior, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid degradation entry %q", item)
+		}
+		dependency = strings.TrimSpace(dependency)
+		mode, value, _ := strings.Cut(behavior, ":")
+		rule := degradationRule{mode: degradationMode(strings.TrimSpace(mode)), value: strings.TrimSpace(value)}
+		switch rule.mode {
+		case degradeFail, degradeStaleCache, degradeFallbackProvider:
+		case degradeDefaultValue:
+			if rule.value == "" {
+				return nil, fmt.Errorf("degradation entry %q requires a value", item)
+			}
+			// CEP providers default to a city name, which any value is;
+			// WeatherAPI defaults to a temperature.
+			if dependency == "weatherapi" {
+				if tempC, err := strconv.ParseFloat(rule.value, 64); err != nil || math.IsNaN(tempC) || math.IsInf(tempC, 0) {
+					return nil, fmt.Errorf("degradation entry %q requires a temperature in °C", item)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown degradation mode %q for %s", rule.mode, dependency)
+		}
+		rules[dependency] = rule
+	}
+	return rules, nil
+}
+
+func newDegradationController(rules map[string]degradationRule) *degradationController {
+	return &degradationController{
+		rules:        rules,
+		lastKnown:    make(map[string]*list.Element),
+		recent:       list.New(),
+		maxLastKnown: lastKnownMax,
+	}
+}
+
+func (d *degradationController) rule(dependency string) degradationRule {
+	if rule, ok := d.rules[dependency]; ok {
+		return rule
+	}
+	return degradationRule{mode: degradeFail}
+}
+
+// remember keeps value as the last known one of dependency for key. Only
+// dependencies with a stale-cache rule ever recall it.
+func (d *degradationController) remember(dependency, key string, value any) {
+	if d.rule(dependency).mode != degradeStaleCache {
+		return
+	}
+	key = dependency + "/" + key
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if el, ok := d.lastKnown[key]; ok {
+		el.Value.(*lastKnownValue).value = value
+		d.recent.MoveToFront(el)
+		return
+	}
+	d.lastKnown[key] = d.recent.PushFront(&lastKnownValue{key: key, value: value})
+	if d.recent.Len() > d.maxLastKnown {
+		oldest := d.recent.Remove(d.recent.Back()).(*lastKnownValue)
+		delete(d.lastKnown, oldest.key)
+	}
+}
+
+func (d *degradationController) recall(dependency, key string) (any, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	el, ok := d.lastKnown[dependency+"/"+key]
+	if !ok {
+		return nil, false
+	}
+	d.recent.MoveToFront(el)
+	return el.Value.(*lastKnownValue).value, true
+}
+
+func isUpstreamFailure(err error) bool {
+	return !errors.Is(err, provider.ErrNotFound) && !errors.Is(err, provider.ErrInvalidCEP)
+}
+
+// callWithDegradation runs call and, when it fails because of the upstream,
+// applies the rule configured for dependency. The returned mode is empty
+// when the value came from the upstream itself.
+func callWithDegradation[T any](
+	ctx context.Context,
+	d *degradationController,
+	dependency, key string,
+	call func(context.Context) (T, error),
+	fallback func(context.Context) (T, error),
+	parseDefault func(string) (T, error),
+) (T, degradationMode, error) {
+	value, err := call(ctx)
+	if err == nil {
+		d.remember(dependency, key, value)
+		return value, "", nil
+	}
+	if !isUpstreamFailure(err) {
+		return value, "", err
+	}
+
+	rule := d.rule(dependency)
+	span := trace.SpanFromContext(ctx)
+	degraded := func(v T) (T, degradationMode, error) {
+		if d.onDegrade != nil {
+			d.onDegrade(dependency, string(rule.mode), err)
+		}
+		span.AddEvent("dependency degraded", trace.WithAttributes(
+			attribute.String("degradation.dependency", dependency),
+			attribute.String("degradation.mode", string(rule.mode)),
+			attribute.String("degradation.cause", err.Error()),
+		))
+		return v, rule.mode, nil
+	}
+
+	switch rule.mode {
+	case degradeStaleCache:
+		if cached, ok := d.recall(dependency, key); ok {
+			return degraded(cached.(T))
+		}
+	case degradeFallbackProvider:
+		if fallback != nil {
+			if v, fbErr := fallback(ctx); fbErr == nil {
+				return degraded(v)
+			}
+		}
+	case degradeDefaultValue:
+		if v, parseErr := parseDefault(rule.value); parseErr == nil {
+			return degraded(v)
+		}
+	}
+	return value, "", err
+}
@@ -0,0 +1,77 @@
+package serviceb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRememberOnlyKeepsStaleCacheDependencies(t *testing.T) {
+	d := newDegradationController(map[string]degradationRule{
+		"viacep":     {mode: degradeStaleCache},
+		"weatherapi": {mode: degradeDefaultValue, value: "25"},
+	})
+	d.remember("viacep", "01001000", "São Paulo")
+	d.remember("weatherapi", "São Paulo", 21.5)
+	d.remember("brasilapi", "01001000", "São Paulo")
+
+	if value, ok := d.recall("viacep", "01001000"); !ok || value != "São Paulo" {
+		t.Errorf("recall(viacep) = %v, %v; want São Paulo, true", value, ok)
+	}
+	for _, dependency := range []string{"weatherapi", "brasilapi"} {
+		if _, ok := d.recall(dependency, "São Paulo"); ok {
+			t.Errorf("recall(%s) found a value for a dependency without a stale-cache rule", dependency)
+		}
+	}
+	if len(d.lastKnown) != 1 {
+		t.Errorf("kept %d values, want 1", len(d.lastKnown))
+	}
+}
+
+func TestRememberEvictsLeastRecentlyUsed(t *testing.T) {
+	d := newDegradationController(map[string]degradationRule{"viacep": {mode: degradeStaleCache}})
+	d.maxLastKnown = 3
+	for i := range 3 {
+		d.remember("viacep", fmt.Sprint(i), i)
+	}
+	// Using 0 makes 1 the least recently used.
+	if _, ok := d.recall("viacep", "0"); !ok {
+		t.Fatal("recall(0) missed")
+	}
+	d.remember("viacep", "3", 3)
+
+	if len(d.lastKnown) != 3 || d.recent.Len() != 3 {
+		t.Fatalf("kept %d values (%d in order), want 3", len(d.lastKnown), d.recent.Len())
+	}
+	if _, ok := d.recall("viacep", "1"); ok {
+		t.Error("1 was kept, want it evicted as the least recently used")
+	}
+	for _, key := range []string{"0", "2", "3"} {
+		if _, ok := d.recall("viacep", key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestParseDegradationMatrixChecksDefaultValues(t *testing.T) {
+	valid := []string{
+		"weatherapi=default-value:25",
+		"weatherapi=default-value:-3.5",
+		"viacep=default-value:São Paulo",
+	}
+	for _, spec := range valid {
+		if _, err := parseDegradationMatrix(spec); err != nil {
+			t.Errorf("parseDegradationMatrix(%q): %v", spec, err)
+		}
+	}
+	invalid := []string{
+		"weatherapi=default-value:abc",
+		"weatherapi=default-value:NaN",
+		"weatherapi=default-value",
+		"viacep=default-value:",
+	}
+	for _, spec := range invalid {
+		if _, err := parseDegradationMatrix(spec); err == nil {
+			t.Errorf("parseDegradationMatrix(%q) succeeded, want an error", spec)
+		}
+	}
+}
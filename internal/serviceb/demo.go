@@ -0,0 +1,128 @@
+package serviceb
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func demoAddress(city, uf, ibge, ddd, siafi string, lat, lon float64) provider.Address {
+	return provider.Address{City: city, UF: uf, IBGECode: ibge, DDD: ddd, SIAFI: siafi, Latitude: &lat, Longitude: &lon}
+}
+
+// demoAddresses is the fixed data set served when DEMO_MODE is enabled.
+var demoAddresses = map[string]provider.Address{
+	"01001000": demoAddress("São Paulo", "SP", "3550308", "11", "7107", -23.5505, -46.6333),
+	"20040020": demoAddress("Rio de Janeiro", "RJ", "3304557", "21", "6001", -22.9035, -43.1758),
+	"30130000": demoAddress("Belo Horizonte", "MG", "3106200", "31", "4123", -19.9191, -43.9386),
+	"40020000": demoAddress("Salvador", "BA", "2927408", "71", "3849", -12.9714, -38.5014),
+	"60060000": demoAddress("Fortaleza", "CE", "2304400", "85", "1389", -3.7319, -38.5267),
+	"70040010": demoAddress("Brasília", "DF", "5300108", "61", "9701", -15.7939, -47.8828),
+	"80010000": demoAddress("Curitiba", "PR", "4106902", "41", "7535", -25.4284, -49.2733),
+	"90010000": demoAddress("Porto Alegre", "RS", "4314902", "51", "8801", -30.0346, -51.2177),
+}
+
+// demoTemperatures is keyed by the folded city name.
+var demoTemperatures = map[string]float64{
+	"sao paulo":      21.5,
+	"rio de janeiro": 28,
+	"belo horizonte": 23.5,
+	"salvador":       27,
+	"fortaleza":      29.5,
+	"brasilia":       24,
+	"curitiba":       16.5,
+	"porto alegre":   19,
+}
+
+const demoWindKph = 12.6
+
+// demoTimeZone is right for every demo city, all on Brasília time.
+const demoTimeZone = "America/Sao_Paulo"
+
+var demoAirQuality = provider.AirQuality{PM25: 8.4, PM10: 15.2, USEPAIndex: 1}
+
+var demoCondition = provider.Condition{Code: 1003, Text: "Partly cloudy", IconURL: weatherIconCDN + "64x64/day/116.png"}
+
+func init() {
+	provider.RegisterCEPProvider("demo", func(provider.Deps) (provider.CEPProvider, error) {
+		return demoCEPProvider{}, nil
+	})
+}
+
+type demoCEPProvider struct{}
+
+func (demoCEPProvider) Name() string { return "demo" }
+
+func (p demoCEPProvider) Locate(ctx context.Context, cep string) (string, error) {
+	address, err := p.Address(ctx, cep)
+	return address.City, err
+}
+
+func (demoCEPProvider) Address(ctx context.Context, cep string) (provider.Address, error) {
+	tracer := otel.Tracer("service-b/demo")
+	_, span := tracer.Start(ctx, "call-viacep-api", trace.WithAttributes(
+		attribute.String("provider.name", "demo"),
+		attribute.String("cep.input", cep),
+		attribute.Bool("demo.mode", true),
+	))
+	defer span.End()
+
+	address, ok := demoAddresses[cep]
+	if !ok {
+		span.SetStatus(codes.Error, "cep not in demo data set")
+		return provider.Address{}, provider.ErrNotFound
+	}
+
+	span.SetAttributes(attribute.String("viacep.location", address.City))
+	span.SetStatus(codes.Ok, "location found")
+	return address, nil
+}
+
+type demoWeatherProvider struct{}
+
+func (demoWeatherProvider) Name() string { return "demo" }
+
+func (demoWeatherProvider) Current(ctx context.Context, location string) (provider.Observation, error) {
+	tracer := otel.Tracer("service-b/demo")
+	_, span := tracer.Start(ctx, "call-weather-api", trace.WithAttributes(
+		attribute.String("provider.name", "demo"),
+		attribute.String("weather.location.input", location),
+		attribute.Bool("demo.mode", true),
+	))
+	defer span.End()
+
+	if lat, lon, err := parseCoordinates(location); err == nil {
+		location = nearestDemoCity(lat, lon)
+	}
+	// Queries name the city first, as in "Sao Paulo,SP,Brazil".
+	location, _, _ = strings.Cut(location, ",")
+	tempC, ok := demoTemperatures[placename.Fold(location)]
+	if !ok {
+		span.SetStatus(codes.Error, "location not in demo data set")
+		return provider.Observation{}, provider.ErrNotFound
+	}
+
+	span.SetAttributes(attribute.Float64("weather.temp_c", tempC))
+	span.SetStatus(codes.Ok, "temperature found")
+	aq, condition := demoAirQuality, demoCondition
+	return provider.Observation{TempC: tempC, WindKph: demoWindKph, AirQuality: &aq, Condition: &condition, Location: location, TimeZone: demoTimeZone}, nil
+}
+
+// nearestDemoCity returns the demo city within one degree of lat/lon, or an
+// empty string when there is none.
+func nearestDemoCity(lat, lon float64) string {
+	city, best := "", 1.0
+	for _, address := range demoAddresses {
+		if d := math.Hypot(*address.Latitude-lat, *address.Longitude-lon); d < best {
+			city, best = address.City, d
+		}
+	}
+	return city
+}
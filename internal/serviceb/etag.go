@@ -0,0 +1,54 @@
+package serviceb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+)
+
+// weatherETag identifies a weather representation by its city, its
+// temperature rounded to a tenth of a degree and its observation time
+// truncated to bucket, plus the options that shape the body. It is weak:
+// bodies with the same tag may differ in fields such as observed_at.
+func weatherETag(r *http.Request, result lookupResult, opts renderOptions, bucket time.Duration) string {
+	version, _ := apiversion.FromContext(r.Context())
+	key := fmt.Sprintf("%s|%s|%.1f|%d|%s|%t|%t|%s|%d|%s",
+		result.response.City, result.response.UF, math.Round(result.response.TempC*10)/10,
+		result.response.ObservedAt.Truncate(bucket).Unix(), opts.preset, opts.airQuality, opts.address, strings.Join(opts.fields, ","), version, opts.mediaType)
+	sum := sha256.Sum256([]byte(key))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using the
+// weak comparison RFC 9110 prescribes for it.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl lets HTTP caches keep a response for as long as the weather
+// cache will serve the same observation. Stale and uncached responses must
+// be revalidated, which the ETag keeps cheap.
+func (s *server) cacheControl(result lookupResult) string {
+	if result.cacheStatus == cacheTracked {
+		remaining := max(s.tracked.maxStale-result.age, 0)
+		return "public, max-age=" + strconv.Itoa(int(remaining.Seconds()))
+	}
+	if !s.toggles.Get().CacheEnabled || s.cache.ttl <= 0 || result.cacheStatus == cacheStale || len(result.degraded) > 0 {
+		return "no-cache"
+	}
+	remaining := max(s.cache.ttl-result.age, 0)
+	return "public, max-age=" + strconv.Itoa(int(remaining.Seconds()))
+}
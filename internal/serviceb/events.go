@@ -0,0 +1,179 @@
+package serviceb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/history"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event kinds written to the outbox.
+const (
+	eventBilling      = "billing.lookup"
+	eventNotification = "notification.lookup"
+)
+
+// eventRelayBatch caps how many events one relay pass sends.
+const eventRelayBatch = 100
+
+// billingEvent charges a successful lookup to whoever made it.
+type billingEvent struct {
+	Tenant   string `json:"tenant,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	CEP      string `json:"cep"`
+	Cache    string `json:"cache,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// notificationEvent tells the end user how a lookup ended.
+type notificationEvent struct {
+	EndUser string   `json:"enduser_id,omitempty"`
+	CEP     string   `json:"cep"`
+	Status  int      `json:"status"`
+	City    string   `json:"city,omitempty"`
+	TempC   *float64 `json:"temp_C,omitempty"`
+	TraceID string   `json:"trace_id,omitempty"`
+}
+
+// lookupEvents returns the events entry raises: a notification for every
+// lookup and a billing event for the successful ones.
+func lookupEvents(ctx context.Context, entry history.Entry, cache cacheStatus) ([]history.Event, error) {
+	bag := baggage.FromContext(ctx)
+	notification := notificationEvent{
+		EndUser: bag.Member("enduser.id").Value(),
+		CEP:     entry.CEP,
+		Status:  entry.Status,
+		TraceID: entry.TraceID,
+	}
+	if entry.Status == http.StatusOK {
+		notification.City, notification.TempC = entry.City, &entry.TempC
+	}
+	var events []history.Event
+	add := func(kind string, payload any) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		events = append(events, history.Event{ID: newEventID(), Kind: kind, Payload: data, CreatedAt: entry.CreatedAt})
+		return nil
+	}
+	if entry.Status == http.StatusOK {
+		err := add(eventBilling, billingEvent{
+			Tenant:   bag.Member("tenant").Value(),
+			ClientID: bag.Member("client.id").Value(),
+			CEP:      entry.CEP,
+			Cache:    string(cache),
+			TraceID:  entry.TraceID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := add(eventNotification, notification); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// eventRelay sends the outbox events to url, oldest first, and marks each
+// one relayed once the consumer acknowledged it with a 2xx. A failed
+// delivery ends the pass so ordering is kept; the event is sent again, with
+// the same Idempotency-Key, on the next one.
+type eventRelay struct {
+	store    history.EventStore
+	client   *http.Client
+	url      string
+	interval time.Duration
+}
+
+func (r *eventRelay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		// A full batch means more events are probably waiting, so the next
+		// one is sent right away; a short or failed pass waits for the tick.
+		for r.relayPending(ctx) == eventRelayBatch {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayPending sends up to eventRelayBatch pending events and returns how
+// many went through.
+func (r *eventRelay) relayPending(ctx context.Context) int {
+	events, err := r.store.PendingEvents(ctx, eventRelayBatch)
+	if err != nil {
+		log.Printf("Failed to read pending events: %v\n", err)
+		return 0
+	}
+	for i, event := range events {
+		if err := r.relay(ctx, event); err != nil {
+			log.Printf("Failed to relay event %s: %v\n", event.ID, err)
+			return i
+		}
+		if err := r.store.MarkRelayed(context.WithoutCancel(ctx), event.ID); err != nil {
+			log.Printf("Failed to mark event %s relayed: %v\n", event.ID, err)
+			return i
+		}
+	}
+	return len(events)
+}
+
+func (r *eventRelay) relay(ctx context.Context, event history.Event) error {
+	ctx, span := otel.Tracer("service-b/events").Start(ctx, "relay-event", trace.WithAttributes(
+		attribute.String("event.id", event.ID),
+		attribute.String("event.kind", event.Kind),
+	))
+	defer span.End()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "event relay failed")
+		return err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		span.SetStatus(codes.Error, "event relay failed")
+		return fmt.Errorf("events webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/history"
+)
+
+func TestEventRelayRetriesWithTheSameIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	repo, err := history.OpenSQLite(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	entry := history.Entry{CEP: "01001000", City: "São Paulo", TempC: 21.5, Status: http.StatusOK, CreatedAt: time.Now()}
+	entry.Events, err = lookupEvents(ctx, entry, cacheMiss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Events) != 2 || entry.Events[0].Kind != eventBilling || entry.Events[1].Kind != eventNotification {
+		t.Fatalf("events = %+v, want billing then notification", entry.Events)
+	}
+	if err := repo.Record(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+		fail = true
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event history.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || event.ID != r.Header.Get("Idempotency-Key") {
+			t.Errorf("body %+v (%v) does not match Idempotency-Key %q", event, err, r.Header.Get("Idempotency-Key"))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer webhook.Close()
+
+	relay := &eventRelay{store: repo, client: webhook.Client(), url: webhook.URL}
+	if n := relay.relayPending(ctx); n != 0 {
+		t.Fatalf("first pass relayed %d events, want 0 after the webhook failed", n)
+	}
+	if n := relay.relayPending(ctx); n != 2 {
+		t.Fatalf("second pass relayed %d events, want 2", n)
+	}
+	if n := relay.relayPending(ctx); n != 0 {
+		t.Fatalf("third pass relayed %d events, want 0", n)
+	}
+
+	want := []string{entry.Events[0].ID, entry.Events[0].ID, entry.Events[1].ID}
+	if len(keys) != len(want) {
+		t.Fatalf("deliveries = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("deliveries = %v, want %v", keys, want)
+		}
+	}
+}
+
+func TestEventRelayDrainsFullBatchesWithoutWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo, err := history.OpenSQLite(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// Each successful lookup raises two events, so this is more than two
+	// batches.
+	const lookups = eventRelayBatch + 10
+	for range lookups {
+		entry := history.Entry{CEP: "01001000", City: "São Paulo", Status: http.StatusOK, CreatedAt: time.Now()}
+		if entry.Events, err = lookupEvents(ctx, entry, cacheMiss); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Record(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		delivered int
+	)
+	done := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if delivered++; delivered == 2*lookups {
+			close(done)
+		}
+	}))
+	defer webhook.Close()
+
+	relay := &eventRelay{store: repo, client: webhook.Client(), url: webhook.URL, interval: time.Hour}
+	go relay.run(ctx)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("relayed %d of %d events without waiting for the next tick", delivered, 2*lookups)
+	}
+}
@@ -0,0 +1,193 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/faultinject"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+)
+
+func TestProviderFailureModes(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	providers := []struct {
+		name string
+		call func(*http.Client) error
+	}{
+		{"viacep", func(client *http.Client) error {
+			_, err := (&viaCEPProvider{name: "viacep", baseURL: upstream.URL, client: client}).Address(context.Background(), testKnownCEP)
+			return err
+		}},
+		{"brasilapi", func(client *http.Client) error {
+			_, err := (&brasilAPIProvider{baseURL: upstream.URL, client: client}).Address(context.Background(), testKnownCEP)
+			return err
+		}},
+		{"weatherapi", func(client *http.Client) error {
+			_, err := newWeatherAPIProvider(client, upstream.URL, "test").Current(context.Background(), testKnownCity)
+			return err
+		}},
+	}
+	for _, p := range providers {
+		for _, scenario := range faultinject.StandardScenarios() {
+			t.Run(p.name+"/"+scenario.Name, func(t *testing.T) {
+				transport := faultinject.New(upstream.Client().Transport)
+				transport.Inject("", scenario.Fault)
+
+				err := p.call(&http.Client{Transport: transport})
+				if err == nil {
+					t.Fatal("call succeeded, want an error")
+				}
+				// Upstream failures must stay apart from the client's own
+				// mistakes, or the degradation matrix would never apply.
+				if !isUpstreamFailure(err) {
+					t.Errorf("error %v is not an upstream failure", err)
+				}
+			})
+		}
+	}
+}
+
+func TestDegradationFailureModes(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	tests := []struct {
+		name     string
+		rule     degradationRule
+		primed   bool
+		wantMode degradationMode
+		wantTemp float64
+	}{
+		{name: "fail", rule: degradationRule{mode: degradeFail}},
+		{name: "stale cache", rule: degradationRule{mode: degradeStaleCache}, primed: true, wantMode: degradeStaleCache, wantTemp: testKnownTempC},
+		{name: "stale cache without a value", rule: degradationRule{mode: degradeStaleCache}},
+		{name: "fallback provider", rule: degradationRule{mode: degradeFallbackProvider}, wantMode: degradeFallbackProvider, wantTemp: 18},
+		{name: "default value", rule: degradationRule{mode: degradeDefaultValue, value: "21.5"}, wantMode: degradeDefaultValue, wantTemp: 21.5},
+	}
+	for _, tt := range tests {
+		for _, scenario := range faultinject.StandardScenarios() {
+			t.Run(tt.name+"/"+scenario.Name, func(t *testing.T) {
+				transport := faultinject.New(upstream.Client().Transport)
+				weather := newWeatherAPIProvider(&http.Client{Transport: transport}, upstream.URL, "test")
+				var rec faultinject.Recorder
+				d := newDegradationController(map[string]degradationRule{"weatherapi": tt.rule})
+				d.onDegrade = rec.Record
+				call := func() (provider.Observation, degradationMode, error) {
+					return callWithDegradation(context.Background(), d, "weatherapi", testKnownCity,
+						func(ctx context.Context) (provider.Observation, error) { return weather.Current(ctx, testKnownCity) },
+						func(context.Context) (provider.Observation, error) { return provider.Observation{TempC: 18}, nil },
+						func(value string) (provider.Observation, error) {
+							tempC, err := strconv.ParseFloat(value, 64)
+							return provider.Observation{TempC: tempC}, err
+						},
+					)
+				}
+				if tt.primed {
+					if _, _, err := call(); err != nil {
+						t.Fatalf("priming call: %v", err)
+					}
+				}
+
+				transport.Inject("", scenario.Fault)
+				obs, mode, err := call()
+				decisions := rec.Decisions()
+				if tt.wantMode == "" {
+					if err == nil {
+						t.Fatalf("call succeeded with mode %q, want the upstream error", mode)
+					}
+					if len(decisions) != 0 {
+						t.Errorf("recorded %v, want no degradation", decisions)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("call: %v", err)
+				}
+				if mode != tt.wantMode || obs.TempC != tt.wantTemp {
+					t.Errorf("got mode %q and %v°C, want %q and %v°C", mode, obs.TempC, tt.wantMode, tt.wantTemp)
+				}
+				if len(decisions) != 1 || decisions[0].Dependency != "weatherapi" || decisions[0].Mode != string(tt.wantMode) || decisions[0].Cause == nil {
+					t.Errorf("recorded %+v, want one weatherapi %s decision with its cause", decisions, tt.wantMode)
+				}
+			})
+		}
+	}
+}
+
+func TestDegradationSkipsClientErrors(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	weather := newWeatherAPIProvider(upstream.Client(), upstream.URL, "test")
+	var rec faultinject.Recorder
+	d := newDegradationController(map[string]degradationRule{"weatherapi": {mode: degradeDefaultValue, value: "25"}})
+	d.onDegrade = rec.Record
+
+	_, mode, err := callWithDegradation(context.Background(), d, "weatherapi", testUnknownCity,
+		func(ctx context.Context) (provider.Observation, error) { return weather.Current(ctx, testUnknownCity) },
+		nil,
+		func(string) (provider.Observation, error) { return provider.Observation{TempC: 25}, nil },
+	)
+	if !errors.Is(err, provider.ErrNotFound) || mode != "" {
+		t.Errorf("got mode %q and error %v, want ErrNotFound undegraded", mode, err)
+	}
+	if decisions := rec.Decisions(); len(decisions) != 0 {
+		t.Errorf("recorded %v, want no degradation", decisions)
+	}
+}
+
+func TestWeatherHandlerFailureModes(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	tests := []struct {
+		name         string
+		matrix       string
+		wantDegraded []string
+	}{
+		{name: "without a matrix"},
+		{name: "degraded", matrix: "viacep=stale-cache,weatherapi=default-value:21.5", wantDegraded: []string{"viacep=stale-cache", "weatherapi=default-value"}},
+	}
+	for _, tt := range tests {
+		svc := newTestService(t, upstream, map[string]string{"DEGRADATION_MATRIX": tt.matrix})
+		transport := faultinject.New(svc.srv.client.Transport)
+		svc.srv.client.Transport = transport
+		var rec faultinject.Recorder
+		svc.srv.degrader.onDegrade = rec.Record
+		get := func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather/"+testKnownCEP, nil))
+			return w
+		}
+		if w := get(); w.Code != http.StatusOK {
+			t.Fatalf("%s: priming lookup answered %d: %s", tt.name, w.Code, w.Body)
+		}
+
+		for _, scenario := range faultinject.StandardScenarios() {
+			t.Run(tt.name+"/"+scenario.Name, func(t *testing.T) {
+				transport.Reset()
+				transport.Inject("", scenario.Fault)
+				before := len(rec.Decisions())
+
+				w := get()
+				if tt.matrix == "" {
+					if w.Code < 500 {
+						t.Errorf("answered %d, want a 5xx: %s", w.Code, w.Body)
+					}
+					if n := len(rec.Decisions()) - before; n != 0 {
+						t.Errorf("recorded %d degradations, want none", n)
+					}
+					return
+				}
+				if w.Code != http.StatusOK {
+					t.Fatalf("answered %d, want 200: %s", w.Code, w.Body)
+				}
+				if got := w.Header().Values("X-Degraded"); !slices.Equal(got, tt.wantDegraded) {
+					t.Errorf("X-Degraded = %q, want %q", got, tt.wantDegraded)
+				}
+				if n := len(rec.Decisions()) - before; n != len(tt.wantDegraded) {
+					t.Errorf("recorded %d degradations, want %d", n, len(tt.wantDegraded))
+				}
+			})
+		}
+	}
+}
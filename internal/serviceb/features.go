@@ -0,0 +1,112 @@
+package serviceb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/toggles"
+)
+
+// cepProviderSet builds CEP providers on first use, so the admin API can
+// switch between registered providers at runtime.
+type cepProviderSet struct {
+	deps provider.Deps
+
+	// hedgeWith names the provider hedged requests are sent to; empty
+	// disables hedging.
+	hedgeWith  string
+	hedgeDelay time.Duration
+	// raceWith names the providers every lookup is raced against when
+	// CEP_STRATEGY is race; empty disables racing.
+	raceWith []string
+
+	mu        sync.Mutex
+	providers map[string]provider.CEPProvider
+}
+
+func newCEPProviderSet(deps provider.Deps) *cepProviderSet {
+	return &cepProviderSet{deps: deps, providers: make(map[string]provider.CEPProvider)}
+}
+
+func (s *cepProviderSet) get(name string) (provider.CEPProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.providers[name]; ok {
+		return p, nil
+	}
+	p, err := provider.NewCEPProvider(name, s.deps)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(s.raceWith) > 0 && name != "demo":
+		racing := &racingCEPProvider{providers: []provider.CEPProvider{p}}
+		for _, other := range s.raceWith {
+			if other == name {
+				continue
+			}
+			contender, err := provider.NewCEPProvider(other, s.deps)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create race provider: %w", err)
+			}
+			racing.providers = append(racing.providers, contender)
+		}
+		if len(racing.providers) > 1 {
+			p = racing
+		}
+	case s.hedgeWith != "" && name != s.hedgeWith && name != "demo":
+		secondary, err := provider.NewCEPProvider(s.hedgeWith, s.deps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create hedge provider: %w", err)
+		}
+		p = &hedgedCEPProvider{primary: p, secondary: secondary, delay: s.hedgeDelay}
+	}
+	s.providers[name] = p
+	return p, nil
+}
+
+// validateToggles rejects toggles whose CEP provider cannot be built, such
+// as viacep-mirror without VIACEP_MIRROR_URL.
+func (s *server) validateToggles(t toggles.Toggles) error {
+	if _, err := s.cepProviders.get(t.CEPProvider); err != nil {
+		return fmt.Errorf("invalid cep_provider: %w", err)
+	}
+	return nil
+}
+
+// cepProviderFor returns the CEP provider selected by the toggles; mock mode
+// always uses the demo provider.
+func (s *server) cepProviderFor(t toggles.Toggles) (provider.CEPProvider, error) {
+	if t.MockMode {
+		return s.cepProviders.get("demo")
+	}
+	return s.cepProviders.get(t.CEPProvider)
+}
+
+func (s *server) togglesHandler(w http.ResponseWriter, r *http.Request) {
+	current := s.toggles.Get()
+	if r.Method == http.MethodPatch {
+		var patch toggles.Patch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "Bad Request: Malformed JSON", http.StatusBadRequest)
+			return
+		}
+		updated, err := s.toggles.Apply(patch)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Feature toggles updated: %+v\n", updated)
+		current = updated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(current); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
@@ -0,0 +1,43 @@
+package serviceb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTogglesRejectUnbuildableCEPProvider(t *testing.T) {
+	svc := newTestService(t, fakeUpstreams(t), map[string]string{"VIACEP_MIRROR_URL": ""})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPatch, "/admin/toggles", strings.NewReader(`{"cep_provider":"viacep-mirror"}`))
+	svc.srv.togglesHandler(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("PATCH answered %d, want 400: %s", w.Code, w.Body)
+	}
+	if got := svc.srv.toggles.Get().CEPProvider; got != "viacep" {
+		t.Errorf("cep_provider = %q after the rejected PATCH, want viacep", got)
+	}
+}
+
+func TestSavedTogglesAreValidatedAtStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "toggles.json")
+	if err := os.WriteFile(path, []byte(`{"cache_enabled":true,"cep_provider":"viacep-mirror","sampling_ratio":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	upstream := fakeUpstreams(t)
+	for key, value := range map[string]string{"TOGGLES_PATH": path, "VIACEP_MIRROR_URL": "", "CEP_PROVIDER": "viacep", "VIACEP_BASE_URL": upstream.URL} {
+		t.Setenv(key, value)
+	}
+	svc, err := New(Options{})
+	if err == nil {
+		svc.Close()
+		t.Fatal("New accepted saved toggles naming viacep-mirror without VIACEP_MIRROR_URL")
+	}
+	if !strings.Contains(err.Error(), "VIACEP_MIRROR_URL") {
+		t.Errorf("New failed with %v, want the missing VIACEP_MIRROR_URL", err)
+	}
+}
@@ -0,0 +1,30 @@
+package serviceb
+
+import (
+	"context"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// geocode fills in the coordinates of address from s.geocoder. It is best
+// effort: a failure only leaves the coordinates out of the response.
+func (s *server) geocode(ctx context.Context, result *lookupResult, cep string, address *provider.Address) {
+	start := time.Now()
+	found, err := provider.ResolveAddress(ctx, s.geocoder, cep)
+	result.timeUpstream(s.geocoder.Name(), start, err)
+	span := trace.SpanFromContext(ctx)
+	if err != nil || found.Latitude == nil {
+		span.AddEvent("geocoding skipped", trace.WithAttributes(
+			attribute.String("geocoder", s.geocoder.Name()),
+			attribute.Bool("geocoder.error", err != nil),
+		))
+		return
+	}
+	address.Latitude, address.Longitude = found.Latitude, found.Longitude
+	if address.UF == "" {
+		address.UF = found.UF
+	}
+}
@@ -0,0 +1,99 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hedgedCEPProvider sends a second lookup to secondary when primary has not
+// answered within delay, or as soon as primary fails. The first definitive
+// answer wins and the other request is cancelled. It keeps the primary's
+// name so degradation rules and toggles keep matching.
+type hedgedCEPProvider struct {
+	primary   provider.CEPProvider
+	secondary provider.CEPProvider
+	delay     time.Duration
+}
+
+func (h *hedgedCEPProvider) Name() string { return h.primary.Name() }
+
+type hedgeResult struct {
+	provider string
+	address  provider.Address
+	err      error
+}
+
+func (h *hedgedCEPProvider) Locate(ctx context.Context, cep string) (string, error) {
+	address, err := h.Address(ctx, cep)
+	return address.City, err
+}
+
+func (h *hedgedCEPProvider) Address(ctx context.Context, cep string) (provider.Address, error) {
+	span := trace.SpanFromContext(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+	launch := func(p provider.CEPProvider) {
+		go func() {
+			address, err := provider.ResolveAddress(ctx, p, cep)
+			results <- hedgeResult{p.Name(), address, err}
+		}()
+	}
+	launch(h.primary)
+
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+
+	inflight, hedged := 1, false
+	hedge := func(reason string) {
+		hedged = true
+		inflight++
+		span.AddEvent("cep.hedge.sent", trace.WithAttributes(
+			attribute.String("hedge.reason", reason),
+			attribute.String("hedge.primary", h.primary.Name()),
+			attribute.String("hedge.secondary", h.secondary.Name()),
+			attribute.Int64("hedge.delay_ms", h.delay.Milliseconds()),
+		))
+		launch(h.secondary)
+	}
+
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedge("delay")
+			}
+		case res := <-results:
+			inflight--
+			if res.err == nil || errors.Is(res.err, provider.ErrNotFound) {
+				if hedged {
+					span.AddEvent("cep.hedge.won", trace.WithAttributes(
+						attribute.String("hedge.winner", res.provider),
+						attribute.Bool("hedge.cancelled_other", inflight > 0),
+					))
+				}
+				return res.address, res.err
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if !hedged && ctx.Err() == nil {
+				hedge("primary_failed")
+				continue
+			}
+			if inflight == 0 {
+				if hedged {
+					span.AddEvent("cep.hedge.failed")
+				}
+				return provider.Address{}, firstErr
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/history"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	historyWindow  = 24 * time.Hour
+	historyTopCEPs = 10
+)
+
+// recordLookup stores a finished lookup; it is deferred by lookupWeather so
+// failed lookups are recorded too.
+func (s *server) recordLookup(ctx context.Context, rawCEP string, start time.Time, result *lookupResult, err *error) {
+	entry := history.Entry{
+		CEP:       strings.TrimSpace(rawCEP),
+		City:      result.response.City,
+		TempC:     result.response.TempC,
+		Latency:   time.Since(start),
+		Status:    lookupStatus(*err),
+		CreatedAt: time.Now(),
+		Upstreams: result.upstreams,
+	}
+	if normalized, nerr := s.cepPolicy.Normalize(rawCEP); nerr == nil {
+		entry.CEP = normalized
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		entry.TraceID = sc.TraceID().String()
+	}
+	if s.events != nil {
+		events, err := lookupEvents(ctx, entry, result.cacheStatus)
+		if err != nil {
+			log.Printf("Failed to build lookup events: %v\n", err)
+		}
+		entry.Events = events
+	}
+	if err := s.history.Record(context.WithoutCancel(ctx), entry); err != nil {
+		log.Printf("Failed to record lookup history: %v\n", err)
+	}
+}
+
+func (s *server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-historyWindow)
+	entries, err := s.history.Since(r.Context(), since)
+	if err != nil {
+		http.Error(w, "Internal server error reading history", http.StatusInternalServerError)
+		log.Printf("Failed to read lookup history: %v\n", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history.Summarize(since, entries, historyTopCEPs)); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
@@ -0,0 +1,37 @@
+package serviceb
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// newHTTPClient builds the instrumented client shared by every upstream call.
+// It is created once at startup so connections are pooled across requests.
+// wrap layers transports, innermost first, below the instrumentation, so
+// replayed or fault-injected calls still produce client spans. A nil
+// tlsConfig keeps the system roots.
+func newHTTPClient(timeout time.Duration, tlsConfig *tls.Config, wrap ...func(http.RoundTripper) http.RoundTripper) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 20
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.TLSHandshakeTimeout = 5 * time.Second
+	transport.ResponseHeaderTimeout = timeout
+
+	var base http.RoundTripper = transport
+	for _, w := range wrap {
+		base = w(base)
+	}
+	return &http.Client{
+		Transport: otelhttp.NewTransport(base),
+		Timeout:   timeout,
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
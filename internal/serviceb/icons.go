@@ -0,0 +1,136 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// weatherIconCDN is where WeatherAPI serves its condition icons, such as
+// https://cdn.weatherapi.com/weather/64x64/day/116.png.
+const weatherIconCDN = "https://cdn.weatherapi.com/weather/"
+
+// iconPath matches the icons WeatherAPI publishes, so the proxy cannot be
+// used to fetch anything else from the CDN.
+var iconPath = regexp.MustCompile(`^(64x64|128x128)/(day|night)/\d{3}\.png$`)
+
+// iconCacheSize bounds the cached icons; WeatherAPI has fewer than 200.
+const iconCacheSize = 500
+
+// maxIconBytes caps the size of an icon read from the CDN.
+const maxIconBytes = 64 << 10
+
+var errIconNotFound = errors.New("icon not found")
+
+// iconProxy serves WeatherAPI icons from Service B under /icons/, fetching
+// each one from the CDN once and keeping it in memory, so clients need not
+// reach WeatherAPI themselves.
+type iconProxy struct {
+	// baseURL is the public URL Service B is reached at.
+	baseURL string
+	client  *http.Client
+
+	mu    sync.Mutex
+	icons map[string][]byte
+}
+
+func newIconProxy(baseURL string, client *http.Client) *iconProxy {
+	return &iconProxy{baseURL: strings.TrimRight(baseURL, "/"), client: client, icons: make(map[string][]byte)}
+}
+
+// iconURL points a WeatherAPI icon URL at the proxy, when there is one.
+func (s *server) iconURL(icon string) string {
+	if s.icons == nil {
+		return icon
+	}
+	if path, ok := strings.CutPrefix(icon, weatherIconCDN); ok && iconPath.MatchString(path) {
+		return s.icons.baseURL + "/icons/" + path
+	}
+	return icon
+}
+
+// handler serves GET /icons/{size}/{period}/{file}.
+func (p *iconProxy) handler(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("size") + "/" + r.PathValue("period") + "/" + r.PathValue("file")
+	if !iconPath.MatchString(path) {
+		i18n.Error(w, r, http.StatusNotFound, "icon not found")
+		return
+	}
+	icon, status, err := p.get(r.Context(), path)
+	switch {
+	case errors.Is(err, errIconNotFound):
+		i18n.Error(w, r, http.StatusNotFound, "icon not found")
+		return
+	case err != nil:
+		i18n.Error(w, r, http.StatusBadGateway, "could not fetch icon")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Cache", string(status))
+	w.Write(icon)
+}
+
+func (p *iconProxy) get(ctx context.Context, path string) ([]byte, cacheStatus, error) {
+	p.mu.Lock()
+	icon, ok := p.icons[path]
+	p.mu.Unlock()
+	if ok {
+		return icon, cacheHit, nil
+	}
+	icon, err := p.fetch(ctx, path)
+	if err != nil {
+		return nil, cacheMiss, err
+	}
+	p.mu.Lock()
+	if len(p.icons) < iconCacheSize {
+		p.icons[path] = icon
+	}
+	p.mu.Unlock()
+	return icon, cacheMiss, nil
+}
+
+func (p *iconProxy) fetch(ctx context.Context, path string) ([]byte, error) {
+	ctx, span := otel.Tracer("service-b/icons").Start(ctx, "fetch-weather-icon")
+	defer span.End()
+	span.SetAttributes(attribute.String("icon.path", path))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, weatherIconCDN+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch icon")
+		return nil, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		span.SetStatus(codes.Error, "icon not found")
+		return nil, errIconNotFound
+	case resp.StatusCode != http.StatusOK:
+		span.SetStatus(codes.Error, "failed to fetch icon")
+		return nil, fmt.Errorf("icon CDN responded %s", resp.Status)
+	}
+	icon, err := io.ReadAll(io.LimitReader(resp.Body, maxIconBytes))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to read icon")
+		return nil, err
+	}
+	return icon, nil
+}
@@ -0,0 +1,93 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/asyncjobs"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+)
+
+const jobRetention = time.Hour
+
+// jobStore keeps the state of asynchronous lookups resolved by this instance.
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]asyncjobs.Job
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]asyncjobs.Job)}
+}
+
+func (s *jobStore) put(job asyncjobs.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[job.ID] = job
+	for id, j := range s.jobs {
+		if time.Since(j.UpdatedAt) > jobRetention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *jobStore) get(id string) (asyncjobs.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// resolveJob is the consumer callback for asynchronous lookups.
+func (s *server) resolveJob(ctx context.Context, req asyncjobs.LookupRequest) {
+	s.jobs.put(asyncjobs.Job{ID: req.JobID, Status: asyncjobs.StatusProcessing})
+
+	result, err := s.lookupWeather(ctx, req.CEP)
+	if s.deferLookup(ctx, req, err) {
+		return
+	}
+	s.finishJob(ctx, req, jobFromLookup(req.JobID, result, err))
+}
+
+// finishJob stores the final state of job and delivers it to the callback.
+func (s *server) finishJob(ctx context.Context, req asyncjobs.LookupRequest, job asyncjobs.Job) {
+	s.jobs.put(job)
+	if req.CallbackURL != "" {
+		if err := s.callbacks.Deliver(ctx, req.CallbackURL, job); err != nil {
+			log.Printf("Failed to deliver callback for job %s: %v\n", job.ID, err)
+		}
+	}
+}
+
+func jobFromLookup(id string, result lookupResult, err error) asyncjobs.Job {
+	job := asyncjobs.Job{ID: id, Status: asyncjobs.StatusDone, StatusCode: http.StatusOK, UpdatedAt: time.Now().UTC()}
+	if err != nil {
+		job.Status = asyncjobs.StatusFailed
+		job.StatusCode = http.StatusInternalServerError
+		var lerr *lookupError
+		if errors.As(err, &lerr) {
+			job.StatusCode = lerr.status
+		}
+		job.Error = err.Error()
+	} else if job.Result, err = json.Marshal(result.response); err != nil {
+		job.Status, job.StatusCode, job.Error = asyncjobs.StatusFailed, http.StatusInternalServerError, err.Error()
+	}
+	return job
+}
+
+func (s *server) jobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		i18n.Error(w, r, http.StatusNotFound, "job not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(r.Context(), w, job)
+}
@@ -0,0 +1,110 @@
+package serviceb
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheNotFound marks lookups answered from the not-found cache.
+const cacheNotFound cacheStatus = "NOT_FOUND"
+
+// notFoundCacheMax bounds the not-found cache, so a client walking through
+// random CEPs cannot grow it without limit.
+const notFoundCacheMax = 10000
+
+// notFoundCache remembers, for ttl, the CEPs whose lookup ended with "can
+// not find zipcode", so a client retrying one in a loop is answered without
+// calling ViaCEP again.
+type notFoundCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+
+	hits atomic.Int64
+}
+
+func newNotFoundCache(ttl time.Duration) *notFoundCache {
+	return &notFoundCache{ttl: ttl, expires: make(map[string]time.Time)}
+}
+
+// has reports whether cep is cached as not found, counting the hit.
+func (c *notFoundCache) has(cep string) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires, ok := c.expires[cep]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(c.expires, cep)
+		return false
+	}
+	c.hits.Add(1)
+	return true
+}
+
+// add caches cep as not found, reporting whether it was stored. When the
+// cache is full of live entries the CEP is not cached.
+func (c *notFoundCache) add(cep string) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.expires) >= notFoundCacheMax {
+		for cached, expires := range c.expires {
+			if now.After(expires) {
+				delete(c.expires, cached)
+			}
+		}
+		if len(c.expires) >= notFoundCacheMax {
+			return false
+		}
+	}
+	c.expires[cep] = now.Add(c.ttl)
+	return true
+}
+
+func (c *notFoundCache) delete(cep string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.expires[cep]
+	delete(c.expires, cep)
+	return ok
+}
+
+func (c *notFoundCache) clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.expires)
+	clear(c.expires)
+	return n
+}
+
+// NotFoundEntry describes a CEP cached as not found for /admin/cache.
+type NotFoundEntry struct {
+	CEP       string    `json:"cep"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (c *notFoundCache) entries() []NotFoundEntry {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]NotFoundEntry, 0, len(c.expires))
+	for cep, expires := range c.expires {
+		if now.Before(expires) {
+			entries = append(entries, NotFoundEntry{CEP: cep, ExpiresAt: expires.UTC()})
+		}
+	}
+	slices.SortFunc(entries, func(a, b NotFoundEntry) int { return strings.Compare(a.CEP, b.CEP) })
+	return entries
+}
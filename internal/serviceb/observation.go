@@ -0,0 +1,59 @@
+package serviceb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	timestampProvider = "provider"
+	timestampServer   = "server"
+)
+
+// observationTimestamper decides which clock an observation is reported
+// with. Provider timestamps are trusted unless they drift from the server
+// clock by more than maxSkew, in which case server time is used and the
+// skew is counted as a data-quality problem.
+type observationTimestamper struct {
+	now     func() time.Time
+	maxSkew time.Duration
+	skewed  metric.Int64Counter
+}
+
+func newObservationTimestamper(maxSkew time.Duration) *observationTimestamper {
+	skewed, err := otel.Meter("service-b/observations").Int64Counter("weather.observation.clock_skew",
+		metric.WithDescription("Provider timestamps rejected for drifting from the server clock"),
+	)
+	if err != nil {
+		log.Printf("Failed to create clock skew counter: %v\n", err)
+	}
+	return &observationTimestamper{now: time.Now, maxSkew: maxSkew, skewed: skewed}
+}
+
+func (t *observationTimestamper) Stamp(ctx context.Context, provider string, providerTime time.Time) (time.Time, string) {
+	now := t.now()
+	if providerTime.IsZero() {
+		return now, timestampServer
+	}
+
+	skew := now.Sub(providerTime)
+	if skew.Abs() <= t.maxSkew {
+		return providerTime, timestampProvider
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("provider clock skew", trace.WithAttributes(
+		attribute.String("observation.provider", provider),
+		attribute.String("observation.provider_time", providerTime.UTC().Format(time.RFC3339)),
+		attribute.Float64("observation.skew_seconds", skew.Seconds()),
+	))
+	if t.skewed != nil {
+		t.skewed.Add(ctx, 1, metric.WithAttributes(attribute.String("observation.provider", provider)))
+	}
+	return now, timestampServer
+}
@@ -0,0 +1,49 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/asyncjobs"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/outbox"
+)
+
+// outboxAsyncLookup is the outbox kind of asynchronous lookups that failed
+// because an upstream was down.
+const outboxAsyncLookup = "async_lookup"
+
+// deferLookup saves req to the outbox when it failed with an upstream
+// error, reporting whether it did. The job stays pending meanwhile and its
+// callback waits for the replay.
+func (s *server) deferLookup(ctx context.Context, req asyncjobs.LookupRequest, err error) bool {
+	if s.outbox == nil || lookupStatus(err) < http.StatusInternalServerError {
+		return false
+	}
+	if addErr := s.outbox.Add(ctx, outboxAsyncLookup, req, err); addErr != nil {
+		log.Printf("Failed to defer job %s: %v\n", req.JobID, addErr)
+		return false
+	}
+	s.jobs.put(asyncjobs.Job{ID: req.JobID, Status: asyncjobs.StatusPending, Error: "queued for retry: " + err.Error()})
+	return true
+}
+
+// replayOutbox is the outbox handler of Service B.
+func (s *server) replayOutbox(ctx context.Context, entry outbox.Entry) error {
+	if entry.Kind != outboxAsyncLookup {
+		log.Printf("Dropping outbox entry %s of unknown kind %q\n", entry.ID, entry.Kind)
+		return nil
+	}
+	var req asyncjobs.LookupRequest
+	if err := json.Unmarshal(entry.Payload, &req); err != nil {
+		log.Printf("Dropping unreadable outbox entry %s: %v\n", entry.ID, err)
+		return nil
+	}
+	result, err := s.lookupWeather(ctx, req.CEP)
+	if lookupStatus(err) >= http.StatusInternalServerError {
+		return err
+	}
+	s.finishJob(ctx, req, jobFromLookup(req.JobID, result, err))
+	return nil
+}
@@ -0,0 +1,172 @@
+package serviceb
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PopularCEP is a CEP counted by the popularity tracker along with the city
+// it last resolved to.
+type PopularCEP struct {
+	CEP      string  `json:"cep"`
+	City     string  `json:"city"`
+	Requests float64 `json:"requests"`
+
+	query string
+}
+
+// popularityTracker counts lookups per CEP. Counts are halved after every
+// pre-warm pass so the ranking follows recent traffic, and only max CEPs are
+// tracked.
+type popularityTracker struct {
+	max int
+
+	mu   sync.Mutex
+	ceps map[string]*PopularCEP
+}
+
+func newPopularityTracker(max int) *popularityTracker {
+	return &popularityTracker{max: max, ceps: make(map[string]*PopularCEP)}
+}
+
+func (t *popularityTracker) record(cep, city, query string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.ceps[cep]; ok {
+		p.Requests++
+		p.City, p.query = city, query
+		return
+	}
+	if len(t.ceps) >= t.max {
+		var least *PopularCEP
+		for _, p := range t.ceps {
+			if least == nil || p.Requests < least.Requests {
+				least = p
+			}
+		}
+		delete(t.ceps, least.CEP)
+	}
+	t.ceps[cep] = &PopularCEP{CEP: cep, City: city, Requests: 1, query: query}
+}
+
+func (t *popularityTracker) top(n int) []PopularCEP {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	popular := make([]PopularCEP, 0, len(t.ceps))
+	for _, p := range t.ceps {
+		popular = append(popular, *p)
+	}
+	slices.SortFunc(popular, func(a, b PopularCEP) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), cmp.Compare(a.CEP, b.CEP))
+	})
+	return popular[:min(n, len(popular))]
+}
+
+func (t *popularityTracker) decay() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for cep, p := range t.ceps {
+		if p.Requests /= 2; p.Requests < 0.5 {
+			delete(t.ceps, cep)
+		}
+	}
+}
+
+// runPrewarm refreshes the weather of the most requested CEPs every
+// interval until ctx is done.
+func (s *server) runPrewarm(ctx context.Context, interval time.Duration, top int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.prewarm(ctx, interval, top)
+		}
+	}
+}
+
+// prewarm runs one pass as its own trace. Entries that outlive the next pass
+// are left alone, so steady traffic costs no extra upstream calls.
+func (s *server) prewarm(ctx context.Context, interval time.Duration, top int) {
+	popular := s.popular.top(top)
+	s.popular.decay()
+	if len(popular) == 0 || !s.toggles.Get().CacheEnabled {
+		return
+	}
+
+	tracer := otel.Tracer("service-b/weather-cache")
+	ctx, span := tracer.Start(ctx, "prewarm-weather-cache", trace.WithNewRoot(), trace.WithAttributes(
+		attribute.Int("prewarm.candidates", len(popular)),
+	))
+	defer span.End()
+
+	warmed, seen := 0, make(map[string]bool, len(popular))
+	for _, p := range popular {
+		key := cacheKey(p.query)
+		if p.query == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if s.cache.Warm(ctx, p.query, interval) {
+			warmed++
+		}
+	}
+	span.SetAttributes(attribute.Int("prewarm.refreshed", warmed))
+}
+
+// CacheReport is the body of GET /admin/cache.
+type CacheReport struct {
+	Entries  []CacheEntry     `json:"entries"`
+	NotFound []NotFoundEntry  `json:"not_found"`
+	Popular  []PopularCEP     `json:"popular,omitempty"`
+	Stats    map[string]int64 `json:"stats"`
+}
+
+func (s *server) cacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		var removed int
+		location, cepCode := r.URL.Query().Get("location"), r.URL.Query().Get("cep")
+		switch {
+		case location != "":
+			if s.cache.Delete(r.Context(), location) {
+				removed = 1
+			}
+		case cepCode != "":
+			if normalized, err := cep.Normalize(cepCode); err == nil && s.notFound.delete(normalized) {
+				removed = 1
+			}
+		default:
+			removed = s.cache.Clear(r.Context()) + s.notFound.clear()
+		}
+		log.Printf("Weather cache invalidated: %d entries removed\n", removed)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]int{"removed": removed}); err != nil {
+			log.Printf("Error encoding JSON response: %v\n", err)
+		}
+		return
+	}
+
+	report := CacheReport{Entries: s.cache.Entries(), NotFound: s.notFound.entries(), Stats: s.cache.Stats()}
+	report.Stats["not_found_hits"] = s.notFound.hits.Load()
+	report.Stats["not_found_entries"] = int64(len(report.NotFound))
+	if s.popular != nil {
+		report.Popular = s.popular.top(s.prewarmTop)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
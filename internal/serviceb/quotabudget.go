@@ -0,0 +1,108 @@
+package serviceb
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// quotaWarnRatio is the share of the daily budget whose consumption is
+// warned about.
+const quotaWarnRatio = 0.8
+
+// quotaBudget counts the calls made to an upstream against a daily budget,
+// reset at midnight UTC, and warns once a day, in the log and on the
+// upstream.quota.warnings counter, when quotaWarnRatio of it is used.
+type quotaBudget struct {
+	upstream string
+	host     string
+	limit    int64
+	warnings metric.Int64Counter
+
+	mu     sync.Mutex
+	day    string
+	used   int64
+	warned bool
+}
+
+func newQuotaBudget(meter metric.Meter, upstream, host string, limit int64) *quotaBudget {
+	b := &quotaBudget{upstream: upstream, host: host, limit: limit}
+	attrs := metric.WithAttributes(attribute.String("upstream", upstream))
+	var err error
+	if _, err = meter.Int64ObservableGauge("upstream.quota.used",
+		metric.WithDescription("Upstream calls made today (UTC)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			used, _ := b.usage()
+			o.Observe(used, attrs)
+			return nil
+		}),
+	); err != nil {
+		log.Printf("Failed to create upstream quota used gauge: %v\n", err)
+	}
+	if _, err = meter.Int64ObservableGauge("upstream.quota.remaining",
+		metric.WithDescription("Upstream calls left in today's budget (UTC)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			_, remaining := b.usage()
+			o.Observe(remaining, attrs)
+			return nil
+		}),
+	); err != nil {
+		log.Printf("Failed to create upstream quota remaining gauge: %v\n", err)
+	}
+	if b.warnings, err = meter.Int64Counter("upstream.quota.warnings",
+		metric.WithDescription("Days on which the upstream budget crossed the warning threshold"),
+	); err != nil {
+		log.Printf("Failed to create upstream quota warnings counter: %v\n", err)
+	}
+	return b
+}
+
+// Wrap counts the requests base sends to the budget's host. It is meant to
+// be the innermost wrapper, so replayed and injected answers do not count.
+func (b *quotaBudget) Wrap(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == b.host {
+			b.consume(req.Context())
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+func (b *quotaBudget) consume(ctx context.Context) {
+	b.mu.Lock()
+	b.rollover()
+	b.used++
+	warn := !b.warned && float64(b.used) >= quotaWarnRatio*float64(b.limit)
+	if warn {
+		b.warned = true
+	}
+	used := b.used
+	b.mu.Unlock()
+
+	if warn {
+		log.Printf("Quota warning: %d%% of the daily %s budget used, %d of %d calls\n", int(quotaWarnRatio*100), b.upstream, used, b.limit)
+		if b.warnings != nil {
+			b.warnings.Add(ctx, 1, metric.WithAttributes(attribute.String("upstream", b.upstream)))
+		}
+	}
+}
+
+// usage reports today's calls and what is left of the budget.
+func (b *quotaBudget) usage() (used, remaining int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	return b.used, max(b.limit-b.used, 0)
+}
+
+// rollover starts a new day's count. The caller holds b.mu.
+func (b *quotaBudget) rollover() {
+	if today := time.Now().UTC().Format(time.DateOnly); today != b.day {
+		b.day, b.used, b.warned = today, 0, false
+	}
+}
@@ -0,0 +1,62 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// racingCEPProvider sends every lookup to all of its providers at once and
+// answers with the first success, cancelling the others. A provider that
+// does not find the CEP does not end the race, since another may know it.
+// It keeps the first provider's name so degradation rules and toggles keep
+// matching.
+type racingCEPProvider struct {
+	providers []provider.CEPProvider
+}
+
+func (r *racingCEPProvider) Name() string { return r.providers[0].Name() }
+
+func (r *racingCEPProvider) Locate(ctx context.Context, cep string) (string, error) {
+	address, err := r.Address(ctx, cep)
+	return address.City, err
+}
+
+func (r *racingCEPProvider) Address(ctx context.Context, cep string) (provider.Address, error) {
+	span := trace.SpanFromContext(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, len(r.providers))
+	for _, p := range r.providers {
+		go func() {
+			address, err := provider.ResolveAddress(ctx, p, cep)
+			results <- hedgeResult{p.Name(), address, err}
+		}()
+	}
+
+	// With no success, a provider that answered "not found" is a better
+	// answer than one that failed.
+	var failed []string
+	var bestErr error
+	for range r.providers {
+		res := <-results
+		if res.err == nil {
+			span.AddEvent("cep.race.won", trace.WithAttributes(
+				attribute.String("race.winner", res.provider),
+				attribute.Int("race.contenders", len(r.providers)),
+				attribute.StringSlice("race.failed", failed),
+			))
+			return res.address, nil
+		}
+		failed = append(failed, res.provider)
+		if bestErr == nil || errors.Is(res.err, provider.ErrNotFound) && !errors.Is(bestErr, provider.ErrNotFound) {
+			bestErr = res.err
+		}
+	}
+	span.AddEvent("cep.race.failed", trace.WithAttributes(attribute.Int("race.contenders", len(r.providers))))
+	return provider.Address{}, bestErr
+}
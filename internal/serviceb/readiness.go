@@ -0,0 +1,112 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// keyCheckLocation is looked up to validate the WeatherAPI key; any
+	// answer but a refusal proves the key works.
+	keyCheckLocation = "Sao Paulo"
+	// keyCheckRetry is how long a key check that could not reach WeatherAPI
+	// waits before trying again.
+	keyCheckRetry = 10 * time.Second
+)
+
+// readiness backs GET /readyz. Without WEATHERAPI_KEY_CHECK the service is
+// ready from the start; with it, once WeatherAPI has accepted the key.
+type readiness struct {
+	mu     sync.Mutex
+	ready  bool
+	reason string
+}
+
+func newReadiness(checkKey bool) *readiness {
+	if !checkKey {
+		return &readiness{ready: true}
+	}
+	return &readiness{reason: "WeatherAPI key not checked yet"}
+}
+
+func (r *readiness) set(ready bool, reason string) {
+	r.mu.Lock()
+	r.ready, r.reason = ready, reason
+	r.mu.Unlock()
+}
+
+// checkWeatherAPIKey calls p until WeatherAPI gives an answer. A refused key
+// leaves the service unready for good, since only a new deployment fixes it;
+// an unreachable WeatherAPI is retried every keyCheckRetry.
+func (r *readiness) checkWeatherAPIKey(ctx context.Context, p provider.WeatherProvider) {
+	tracer := otel.Tracer("service-b/readiness")
+	for {
+		spanCtx, span := tracer.Start(ctx, "check-weatherapi-key")
+		_, err := p.Current(spanCtx, keyCheckLocation)
+		switch {
+		case err == nil || errors.Is(err, provider.ErrNotFound):
+			span.End()
+			r.set(true, "")
+			log.Println("WeatherAPI key check passed, service ready")
+			return
+		case errors.Is(err, provider.ErrRejected):
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "weatherapi key refused")
+			span.End()
+			r.set(false, "WeatherAPI refused the key")
+			log.Printf("WeatherAPI key check failed, service stays unready: %v\n", err)
+			return
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "weatherapi unreachable")
+		span.End()
+		r.set(false, "WeatherAPI key not checked yet: WeatherAPI unreachable")
+		log.Printf("WeatherAPI key check could not reach WeatherAPI, retrying in %s: %v\n", keyCheckRetry, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(keyCheckRetry):
+		}
+	}
+}
+
+type probeResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// livenessHandler answers as long as the process serves requests; upstream
+// problems never fail it, so they do not get the process restarted.
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, http.StatusOK, probeResponse{Status: "ok"})
+}
+
+func (r *readiness) handler(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	ready, reason := r.ready, r.reason
+	r.mu.Unlock()
+	if !ready {
+		writeProbe(w, http.StatusServiceUnavailable, probeResponse{Status: "not_ready", Reason: reason})
+		return
+	}
+	writeProbe(w, http.StatusOK, probeResponse{Status: "ready"})
+}
+
+func writeProbe(w http.ResponseWriter, status int, body probeResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
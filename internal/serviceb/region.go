@@ -0,0 +1,45 @@
+package serviceb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// regionMismatchError means the weather provider resolved a query to a city
+// outside the state the query asked for, as happens with the many cities
+// that share a name.
+type regionMismatchError struct {
+	query    string
+	location string
+	region   string
+}
+
+func (e *regionMismatchError) Error() string {
+	return fmt.Sprintf("weather provider resolved %q to %s, %s", e.query, e.location, e.region)
+}
+
+// checkRegion rejects observations placed outside the state named in the
+// query, so they are neither cached nor served. Providers that report no
+// region are trusted.
+func checkRegion(fetch func(context.Context, string) (provider.Observation, error)) func(context.Context, string) (provider.Observation, error) {
+	return func(ctx context.Context, query string) (provider.Observation, error) {
+		obs, err := fetch(ctx, query)
+		if err != nil {
+			return obs, err
+		}
+		if uf := placename.QueryUF(query); uf != "" && obs.Region != "" && !placename.InState(obs.Region, uf) {
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.Bool("weather.region.mismatch", true),
+				attribute.String("weather.region.expected", uf),
+				attribute.String("weather.region.returned", obs.Region),
+			)
+			return provider.Observation{}, &regionMismatchError{query: query, location: obs.Location, region: obs.Region}
+		}
+		return obs, nil
+	}
+}
@@ -0,0 +1,37 @@
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/formats"
+)
+
+// snakeCase is the field naming of API version 2, in which version 1's
+// temp_C is temp_c. Every other field is already snake_case.
+func snakeCase(name string) string { return strings.ToLower(name) }
+
+// versionedBody renames the fields of body to the naming of API version v.
+func versionedBody(body any, v int) (any, error) {
+	if v < 2 {
+		return body, nil
+	}
+	return formats.Rename(body, snakeCase)
+}
+
+// writeJSON encodes body with the field naming of the API version the
+// request negotiated.
+func writeJSON(ctx context.Context, w http.ResponseWriter, body any) {
+	v, _ := apiversion.FromContext(ctx)
+	body, err := versionedBody(body, v)
+	if err == nil {
+		err = json.NewEncoder(w).Encode(body)
+	}
+	if err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
@@ -0,0 +1,928 @@
+// Package serviceb implements Service B, the CEP weather API: it resolves a
+// CEP to a city and returns its current temperature.
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	// The image has no zoneinfo database for time.LoadLocation.
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/admin"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/asyncjobs"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/audit"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cachemetrics"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/callbackurl"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cepdb"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/compress"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/faultinject"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/history"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/ipfilter"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/outbox"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/toggles"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tracing"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/unmatched"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/vcr"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/version"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var weatherAPIKey = "43a8de906a5a4e4ab67165701253105"
+
+type server struct {
+	client       *http.Client
+	cepProviders *cepProviderSet
+	// geocoder fills in coordinates the CEP provider did not report.
+	geocoder     provider.CEPProvider
+	fetchWeather func(context.Context, string) (provider.Observation, error)
+	mockWeather  func(context.Context, string) (provider.Observation, error)
+	cache        *weatherCache
+	notFound     *notFoundCache
+	// outbox is nil unless OUTBOX_DIR is set.
+	outbox *outbox.Outbox
+	// cepDB is nil unless CEP_DB_PATH is set. It answers the CEPs it
+	// knows before the CEP provider is asked.
+	cepDB *cepdb.DB
+	// icons is nil unless WEATHER_ICON_BASE_URL enables the icon proxy.
+	icons *iconProxy
+	// astronomy is nil when the weather provider has no astronomy data.
+	astronomy      provider.AstronomyProvider
+	astronomyCache *astronomyCache
+	cepPolicy      cep.Policy
+	toggles        *toggles.Store
+	degrader       *degradationController
+	jobs           *jobStore
+	callbacks      *callbackDeliverer
+	history        history.Repository
+	// events relays the billing and notification events recorded with the
+	// history; nil unless EVENTS_WEBHOOK_URL is set.
+	events *eventRelay
+	audit  *audit.Logger
+
+	// popular is nil unless CACHE_PREWARM_INTERVAL enables pre-warming.
+	popular         *popularityTracker
+	prewarmInterval time.Duration
+	prewarmTop      int
+
+	// alerts is nil when ALERT_CHECK_INTERVAL disables temperature alerts.
+	alerts        *alertStore
+	notifier      *alertNotifier
+	tracked       *trackedSet
+	alertInterval time.Duration
+
+	// zipkinUIURL is set in demo/debug modes to link responses to their trace.
+	zipkinUIURL string
+	// unitsPreset is the default for requests without ?units=; empty serves
+	// the original body.
+	unitsPreset string
+	// etagBucket is the observation time granularity of ETags.
+	etagBucket time.Duration
+}
+
+type WeatherResponse struct {
+	City      string   `json:"city"`
+	UF        string   `json:"uf,omitempty"`
+	IBGECode  string   `json:"ibge_code,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// Resolution is "approximate" when the CEP providers were down and the
+	// state, and for capitals the city, were inferred from the CEP range.
+	Resolution string `json:"resolution,omitempty"`
+	// Source is where the address came from: the CEP provider that
+	// answered, such as "viacep" or "brasilapi", "local-db", "cache",
+	// "cep-range" or "default". It is also sent as X-Resolved-By.
+	Source string `json:"source,omitempty"`
+
+	Address *AddressResponse `json:"address,omitempty"`
+
+	TempC float64 `json:"temp_C"`
+	TempF float64 `json:"temp_F"`
+	TempK float64 `json:"temp_K"`
+
+	Condition *ConditionResponse `json:"condition,omitempty"`
+
+	ObservedAt time.Time `json:"observed_at"`
+	// TimeZone and LocalTime, the time in the city when the response was
+	// built, are left out when the provider reports no time zone.
+	TimeZone  string     `json:"timezone,omitempty"`
+	LocalTime *time.Time `json:"local_time,omitempty"`
+
+	AirQuality *AirQualityResponse `json:"air_quality,omitempty"`
+
+	ZipkinURL string `json:"zipkin_url,omitempty"`
+}
+
+// ConditionResponse describes the weather in words and as an icon.
+type ConditionResponse struct {
+	Code    int    `json:"code"`
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// AddressResponse holds the CEP details served with ?include=address.
+type AddressResponse struct {
+	DDD   string `json:"ddd,omitempty"`
+	SIAFI string `json:"siafi,omitempty"`
+}
+
+type AirQualityResponse struct {
+	PM25       float64 `json:"pm2_5"`
+	PM10       float64 `json:"pm10"`
+	USEPAIndex int     `json:"us_epa_index"`
+}
+
+func celsiusToFahrenheit(celsius float64) float64 {
+	return celsius*1.8 + 32
+}
+
+func celsiusToKelvin(celsius float64) float64 {
+	return celsius + 273
+}
+
+// lookupError carries the HTTP status and client facing message of a failed
+// lookup. The message is kept as a format string so it can be translated.
+type lookupError struct {
+	status int
+	format string
+	args   []any
+}
+
+func newLookupError(status int, format string, args ...any) *lookupError {
+	return &lookupError{status: status, format: format, args: args}
+}
+
+// lookupStatus is the HTTP status a lookup that ended with err answers with.
+func lookupStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var lerr *lookupError
+	if errors.As(err, &lerr) {
+		return lerr.status
+	}
+	return http.StatusInternalServerError
+}
+
+// lookupErrorFor maps the provider error taxonomy to the status and message
+// clients receive; an upstream that answers garbage or refuses the request for
+// reasons other than the CEP is a 502. Any other error is reported as a 500
+// with unexpected, which formats err.
+func lookupErrorFor(err error, unexpected string) *lookupError {
+	switch {
+	case errors.Is(err, provider.ErrNotFound):
+		return newLookupError(http.StatusNotFound, "can not find zipcode")
+	case errors.Is(err, provider.ErrInvalidCEP):
+		return newLookupError(http.StatusUnprocessableEntity, "invalid zipcode")
+	case errors.Is(err, provider.ErrBadResponse):
+		return newLookupError(http.StatusBadGateway, "upstream returned an invalid response")
+	case errors.Is(err, provider.ErrUnauthorized):
+		return newLookupError(http.StatusBadGateway, "upstream rejected the service credentials")
+	case errors.Is(err, provider.ErrQuotaExceeded):
+		return newLookupError(http.StatusServiceUnavailable, "upstream quota exceeded, try again later")
+	case errors.Is(err, provider.ErrRejected):
+		return newLookupError(http.StatusBadGateway, "upstream rejected the request")
+	}
+	return newLookupError(http.StatusInternalServerError, unexpected, err)
+}
+
+func (e *lookupError) Error() string { return i18n.T(i18n.English, e.format, e.args...) }
+
+type lookupResult struct {
+	response    WeatherResponse
+	windKph     float64
+	airQuality  *provider.AirQuality
+	address     AddressResponse
+	cacheStatus cacheStatus
+	age         time.Duration
+	degraded    []string
+	upstreams   []history.UpstreamCall
+	// resolution is resolutionApproximate when the address was inferred
+	// from the CEP range.
+	resolution string
+}
+
+// timeUpstream records how long a call to upstream took. Not-found answers
+// are valid responses and do not count as upstream errors.
+func (r *lookupResult) timeUpstream(upstream string, start time.Time, err error) {
+	ok := err == nil || errors.Is(err, provider.ErrNotFound)
+	r.upstreams = append(r.upstreams, history.UpstreamCall{Upstream: upstream, Latency: time.Since(start), OK: ok})
+}
+
+func (s *server) lookupWeather(ctx context.Context, rawCEP string) (result lookupResult, err error) {
+	if s.history != nil {
+		defer s.recordLookup(ctx, rawCEP, time.Now(), &result, &err)
+	}
+	if s.audit != nil {
+		defer s.auditLookup(ctx, rawCEP, time.Now(), &result, &err)
+	}
+
+	cepCode, err := s.cepPolicy.Normalize(rawCEP)
+	if err != nil {
+		return lookupResult{}, lookupErrorFor(err, "Internal server error: %v")
+	}
+
+	features := s.toggles.Get()
+	trace.SpanFromContext(ctx).SetAttributes(features.Attributes()...)
+	if s.tracked != nil && !features.MockMode {
+		if entry, ok := s.tracked.get(cepCode); ok {
+			result.cacheStatus, result.age = cacheTracked, time.Since(entry.refreshedAt)
+			address := entry.address
+			address.Source = sourceCache
+			s.fillResponse(ctx, &result, address, entry.observation)
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("weather.cache", string(result.cacheStatus)))
+			return result, nil
+		}
+	}
+	if !features.MockMode {
+		if s.notFound.has(cepCode) {
+			result.cacheStatus = cacheNotFound
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("weather.cache", string(result.cacheStatus)))
+			return result, lookupErrorFor(provider.ErrNotFound, "")
+		}
+		defer func() {
+			if lookupStatus(err) == http.StatusNotFound && s.notFound.add(cepCode) {
+				trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("weather.cache.not_found_stored", true))
+			}
+		}()
+	}
+	result.cacheStatus = cacheMiss
+	address, err := s.resolveAddress(ctx, features, &result, cepCode)
+	if err != nil {
+		return result, err
+	}
+	if address.Latitude == nil && s.geocoder != nil && !features.MockMode {
+		s.geocode(ctx, &result, cepCode, &address)
+	}
+	// Accents and duplicated city names lead WeatherAPI astray, so it is
+	// asked for "Sao Paulo,SP,Brazil" rather than "São Paulo".
+	query := placename.WeatherQuery(address.City, address.UF)
+	if s.popular != nil && !features.MockMode {
+		s.popular.record(cepCode, address.City, query)
+	}
+
+	err = s.currentWeather(ctx, features, &result, address, query)
+	return result, err
+}
+
+// resolveAddress resolves cepCode with the CEP provider features select,
+// degrading as the matrix says and recording the call in result.
+func (s *server) resolveAddress(ctx context.Context, features toggles.Toggles, result *lookupResult, cepCode string) (provider.Address, error) {
+	// The CEP database is skipped in mock mode, whose demo data set must
+	// not be served or stored as real addresses.
+	useDB := s.cepDB != nil && !features.MockMode
+	if useDB {
+		if address, ok := s.storedAddress(ctx, cepCode); ok {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("address.source", address.Source))
+			return address, nil
+		}
+	}
+	cepProvider, err := s.cepProviderFor(features)
+	if err != nil {
+		return provider.Address{}, newLookupError(http.StatusInternalServerError, "Internal server error getting location: %v", err)
+	}
+	address, locationMode, err := callWithDegradation(ctx, s.degrader, cepProvider.Name(), cepCode,
+		func(ctx context.Context) (provider.Address, error) {
+			start := time.Now()
+			address, err := provider.ResolveAddress(ctx, cepProvider, cepCode)
+			result.timeUpstream(cepProvider.Name(), start, err)
+			return address, err
+		},
+		func(ctx context.Context) (provider.Address, error) { return approximateAddress(ctx, cepCode) },
+		func(value string) (provider.Address, error) { return provider.Address{City: value}, nil },
+	)
+	if err != nil {
+		return provider.Address{}, lookupErrorFor(err, "Internal server error getting location: %v")
+	}
+	switch locationMode {
+	case degradeStaleCache:
+		address.Source = sourceCache
+	case degradeFallbackProvider:
+		result.resolution = resolutionApproximate
+	case degradeDefaultValue:
+		address.Source = sourceDefault
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("address.source", address.Source))
+	if locationMode != "" {
+		result.degraded = append(result.degraded, cepProvider.Name()+"="+string(locationMode))
+	} else if useDB {
+		s.storeAddress(ctx, cepCode, address)
+	}
+	return address, nil
+}
+
+// currentWeather fetches the weather for location, the query sent to the
+// weather provider, and fills result.response for address.
+func (s *server) currentWeather(ctx context.Context, features toggles.Toggles, result *lookupResult, address provider.Address, location string) error {
+	obs, tempMode, err := callWithDegradation(ctx, s.degrader, "weatherapi", location,
+		func(ctx context.Context) (provider.Observation, error) {
+			fetch := func(location string) (obs provider.Observation, err error) {
+				start := time.Now()
+				switch {
+				case features.MockMode:
+					obs, err = s.mockWeather(ctx, location)
+				case !features.CacheEnabled:
+					obs, err = s.fetchWeather(ctx, location)
+				default:
+					obs, result.cacheStatus, result.age, err = s.cache.Get(ctx, location)
+				}
+				if result.cacheStatus == cacheMiss {
+					result.timeUpstream("weatherapi", start, err)
+				}
+				return obs, err
+			}
+			obs, err := fetch(location)
+			// A city of the same name in another state answered; coordinates,
+			// when the CEP has them, cannot be mistaken.
+			var mismatch *regionMismatchError
+			if errors.As(err, &mismatch) && address.Latitude != nil && address.Longitude != nil {
+				trace.SpanFromContext(ctx).AddEvent("retrying weather by coordinates", trace.WithAttributes(
+					attribute.String("weather.location.returned", mismatch.location),
+				))
+				obs, err = fetch(coordinatesQuery(*address.Latitude, *address.Longitude))
+			}
+			return obs, err
+		},
+		nil,
+		func(value string) (provider.Observation, error) {
+			tempC, err := strconv.ParseFloat(value, 64)
+			return provider.Observation{TempC: tempC, ObservedAt: time.Now(), TimestampSource: timestampServer}, err
+		},
+	)
+	if err != nil {
+		return lookupErrorFor(err, "Internal server error getting weather: %v")
+	}
+	s.fillResponse(ctx, result, address, obs)
+	if tempMode != "" {
+		result.degraded = append(result.degraded, "weatherapi="+string(tempMode))
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("weather.cache", string(result.cacheStatus)))
+	return nil
+}
+
+// fillResponse builds result.response for the weather obs observed at
+// address.
+func (s *server) fillResponse(ctx context.Context, result *lookupResult, address provider.Address, obs provider.Observation) {
+	city := address.City
+	if city == "" {
+		city = obs.Location
+	} else if obs.Location != "" && !placename.Equal(city, obs.Location) {
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Bool("weather.location.mismatch", true),
+			attribute.String("weather.location.returned", obs.Location),
+		)
+	}
+	tempC := obs.TempC
+	tempF := celsiusToFahrenheit(tempC)
+	tempK := celsiusToKelvin(tempC)
+
+	result.response = WeatherResponse{
+		City:       city,
+		UF:         address.UF,
+		IBGECode:   address.IBGECode,
+		Latitude:   address.Latitude,
+		Longitude:  address.Longitude,
+		Resolution: result.resolution,
+		Source:     address.Source,
+		TempC:      tempC,
+		TempF:      tempF,
+		TempK:      tempK,
+
+		ObservedAt: obs.ObservedAt.UTC(),
+	}
+	if c := obs.Condition; c != nil {
+		result.response.Condition = &ConditionResponse{Code: c.Code, Text: c.Text, IconURL: s.iconURL(c.IconURL)}
+	}
+	if obs.TimeZone != "" {
+		if loc, err := time.LoadLocation(obs.TimeZone); err == nil {
+			now := time.Now().In(loc).Truncate(time.Second)
+			result.response.TimeZone, result.response.LocalTime = obs.TimeZone, &now
+		} else {
+			trace.SpanFromContext(ctx).RecordError(err)
+		}
+	}
+	result.windKph = obs.WindKph
+	result.airQuality = obs.AirQuality
+	result.address = AddressResponse{DDD: address.DDD, SIAFI: address.SIAFI}
+	if s.zipkinUIURL != "" {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			result.response.ZipkinURL = fmt.Sprintf("%s/traces/%s", strings.TrimRight(s.zipkinUIURL, "/"), sc.TraceID())
+		}
+	}
+}
+
+func (s *server) weatherHandler(w http.ResponseWriter, r *http.Request) {
+	callbackURL := r.URL.Query().Get("callback_url")
+	if callbackURL != "" && !callbackurl.Valid(callbackURL) {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: callback_url must be an absolute http(s) URL")
+		return
+	}
+	opts, ok := s.renderOptions(w, r)
+	if !ok {
+		return
+	}
+	if callbackURL != "" && !s.callbacks.reserve() {
+		w.Header().Set("Retry-After", "1")
+		i18n.Error(w, r, http.StatusServiceUnavailable, "Service Unavailable: too many pending callbacks")
+		return
+	}
+
+	result, err := s.lookupWeather(r.Context(), r.PathValue("cep"))
+	if callbackURL != "" {
+		job := jobFromLookup(asyncjobs.NewJobID(), result, err)
+		w.Header().Set("X-Callback-Job-Id", job.ID)
+		go func(ctx context.Context) {
+			defer s.callbacks.release()
+			if err := s.callbacks.Deliver(ctx, callbackURL, job); err != nil {
+				requestid.Logf(ctx, "Failed to deliver callback for job %s: %v\n", job.ID, err)
+			}
+		}(context.WithoutCancel(r.Context()))
+	}
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	s.writeWeather(w, r, result, opts)
+}
+
+// writeWeather answers with the rendered result, or with 304 when the
+// client's If-None-Match already names its ETag.
+func (s *server) writeWeather(w http.ResponseWriter, r *http.Request, result lookupResult, opts renderOptions) {
+	body, err := renderWeather(result, opts)
+	if err != nil {
+		i18n.Error(w, r, http.StatusInternalServerError, "Internal server error: %v", err)
+		return
+	}
+
+	etag := weatherETag(r, result, opts, s.etagBucket)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", s.cacheControl(result))
+	w.Header().Set("X-Cache", string(result.cacheStatus))
+	w.Header().Set("Age", strconv.Itoa(int(result.age.Seconds())))
+	if result.response.Source != "" {
+		w.Header().Set("X-Resolved-By", result.response.Source)
+	}
+	for _, degraded := range result.degraded {
+		w.Header().Add("X-Degraded", degraded)
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", opts.mediaType)
+	w.WriteHeader(http.StatusOK)
+	if err := opts.encode(w, "weather", body); err != nil {
+		log.Printf("Error encoding %s response: %v\n", opts.mediaType, err)
+	}
+}
+
+func writeLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := http.StatusInternalServerError, err.Error()
+	lang := i18n.Language(r)
+	var lerr *lookupError
+	if errors.As(err, &lerr) {
+		status, message = lerr.status, i18n.T(lang, lerr.format, lerr.args...)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(status)
+	fmt.Fprintln(w, message)
+}
+
+type Options struct {
+	// Stats counts served requests for the shutdown report.
+	Stats *shutdownreport.Collector
+}
+
+// Service is a configured Service B. Its settings are read from the
+// environment by New.
+type Service struct {
+	srv     *server
+	handler http.Handler
+	admin   *http.ServeMux
+	closers []func() error
+
+	baggageKeys []string
+	ready       *readiness
+	// outboxInterval is how often the outbox is replayed.
+	outboxInterval time.Duration
+	// keyCheck is the provider WEATHERAPI_KEY_CHECK validates the key
+	// with, nil without the check.
+	keyCheck provider.WeatherProvider
+}
+
+func New(opts Options) (*Service, error) {
+	if key := os.Getenv("WEATHER_API_KEY"); key != "" {
+		weatherAPIKey = key
+	}
+
+	cassettes, err := vcr.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPSTREAM_VCR_MODE: %w", err)
+	}
+	if cassettes != nil {
+		fmt.Printf("UPSTREAM_VCR_MODE=%s: upstream calls use the cassettes in %s\n", cassettes.Mode(), envconfig.String("UPSTREAM_VCR_DIR", "cassettes"))
+	}
+	var transports []func(http.RoundTripper) http.RoundTripper
+	if cassettes != nil {
+		transports = append(transports, cassettes.Wrap)
+	}
+	viaCEPURL, err := upstreamBaseURL(os.Getenv, "VIACEP_BASE_URL", viaCEPHost)
+	if err != nil {
+		return nil, err
+	}
+	brasilAPIURL, err := upstreamBaseURL(os.Getenv, "BRASILAPI_URL", brasilAPIHost)
+	if err != nil {
+		return nil, err
+	}
+	weatherAPIURL, err := upstreamBaseURL(os.Getenv, "WEATHERAPI_BASE_URL", weatherAPIHost)
+	if err != nil {
+		return nil, err
+	}
+	if budget := envconfig.Int("WEATHERAPI_DAILY_BUDGET", 0); budget > 0 {
+		// Innermost, so only calls that reach WeatherAPI are counted.
+		quota := newQuotaBudget(otel.Meter("service-b/upstream"), "weatherapi", hostOf(weatherAPIURL), int64(budget))
+		transports = append([]func(http.RoundTripper) http.RoundTripper{quota.Wrap}, transports...)
+	}
+	// Keyed by the configured hosts, so faults, metrics and pins follow a
+	// mirror that replaces an upstream.
+	dependencies := map[string]string{
+		hostOf(viaCEPURL):     "viacep",
+		hostOf(brasilAPIURL):  "brasilapi",
+		hostOf(weatherAPIURL): "weatherapi",
+	}
+	chaos, err := faultinject.ChaosFromEnv(dependencies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHAOS_FAULTS: %w", err)
+	}
+	if chaos != nil {
+		transports = append(transports, chaos.Wrap)
+	}
+	latencySLO, defaultLatencySLO, err := parseLatencySLO(os.Getenv("UPSTREAM_LATENCY_SLO"), 500*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPSTREAM_LATENCY_SLO: %w", err)
+	}
+	// Outermost, so injected faults burn the error budget as real ones would.
+	transports = append(transports, newUpstreamMetrics(otel.Meter("service-b/upstream"), dependencies, latencySLO, defaultLatencySLO).Wrap)
+	tlsConfig, err := upstreamTLSConfig(os.Getenv("UPSTREAM_CA_FILE"), os.Getenv("UPSTREAM_TLS_PINS"), dependencies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream TLS settings: %w", err)
+	}
+	client := newHTTPClient(envconfig.Duration("HTTP_CLIENT_TIMEOUT", 10*time.Second), tlsConfig, transports...)
+	// Callbacks and alert webhooks go to client supplied URLs, so they skip
+	// the upstream transports and may only reach public addresses.
+	callbackGuard := callbackurl.NewGuard(strings.Split(os.Getenv("CALLBACK_ALLOWED_HOSTS"), ","))
+	callbackClient := &http.Client{
+		Transport: otelhttp.NewTransport(callbackGuard.Transport()),
+		Timeout:   envconfig.Duration("HTTP_CLIENT_TIMEOUT", 10*time.Second),
+	}
+
+	rules, err := parseDegradationMatrix(os.Getenv("DEGRADATION_MATRIX"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEGRADATION_MATRIX: %w", err)
+	}
+
+	cepProviderName := envconfig.String("CEP_PROVIDER", "viacep")
+	var weatherProvider provider.WeatherProvider = newWeatherAPIProvider(client, weatherAPIURL, weatherAPIKey)
+	demoMode := os.Getenv("DEMO_MODE") == "true"
+	if demoMode {
+		cepProviderName, weatherProvider = "demo", demoWeatherProvider{}
+		fmt.Println("DEMO_MODE enabled: serving fake data, ViaCEP and WeatherAPI are not called")
+	}
+	cepProviders := newCEPProviderSet(provider.Deps{Client: client, Getenv: os.Getenv})
+	cepProviders.hedgeWith = os.Getenv("CEP_HEDGE_PROVIDER")
+	cepProviders.hedgeDelay = envconfig.Duration("CEP_HEDGE_DELAY", 300*time.Millisecond)
+	switch strategy := envconfig.String("CEP_STRATEGY", "single"); strategy {
+	case "single":
+	case "race":
+		if cepProviders.hedgeWith != "" {
+			return nil, errors.New("CEP_HEDGE_PROVIDER cannot be combined with CEP_STRATEGY=race")
+		}
+		for _, name := range strings.Split(envconfig.String("CEP_RACE_PROVIDERS", "viacep,brasilapi"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cepProviders.raceWith = append(cepProviders.raceWith, name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown CEP_STRATEGY %q (available: single, race)", strategy)
+	}
+	if _, err := cepProviders.get(cepProviderName); err != nil {
+		return nil, fmt.Errorf("failed to create CEP provider: %w", err)
+	}
+
+	var geocoder provider.CEPProvider
+	if name := os.Getenv("CEP_GEOCODER"); name != "" && !demoMode {
+		if geocoder, err = provider.NewCEPProvider(name, provider.Deps{Client: client, Getenv: os.Getenv}); err != nil {
+			return nil, fmt.Errorf("failed to create CEP geocoder: %w", err)
+		}
+	}
+
+	stamper := newObservationTimestamper(envconfig.Duration("OBSERVATION_MAX_SKEW", 30*time.Minute))
+	stamped := func(weatherProvider provider.WeatherProvider) func(context.Context, string) (provider.Observation, error) {
+		return func(ctx context.Context, location string) (provider.Observation, error) {
+			obs, err := weatherProvider.Current(ctx, location)
+			if err != nil {
+				return obs, err
+			}
+			obs.ObservedAt, obs.TimestampSource = stamper.Stamp(ctx, "weatherapi", obs.ObservedAt)
+			return obs, nil
+		}
+	}
+	temperature := checkRegion(stamped(weatherProvider))
+
+	cacheTTL := envconfig.Duration("WEATHER_CACHE_TTL", 5*time.Minute)
+	cacheStaleTTL := envconfig.Duration("WEATHER_CACHE_STALE_TTL", 10*time.Minute)
+	var cacheMeter metric.Meter
+	if os.Getenv("CACHE_METRICS") == "true" {
+		cacheMeter = otel.Meter("service-b/cache")
+	}
+	cacheMetrics := cachemetrics.New(cacheMeter, "weather", "memory")
+
+	unitsPreset := os.Getenv("UNITS_PRESET")
+	if _, ok := unitsPresets[unitsPreset]; unitsPreset != "" && !ok {
+		return nil, fmt.Errorf("invalid UNITS_PRESET %q (available: %v)", unitsPreset, unitsPresetNames())
+	}
+
+	cepPolicy, err := cep.ParsePolicy(os.Getenv("CEP_POLICY"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEP_POLICY: %w", err)
+	}
+
+	srv := &server{
+		cepPolicy:      cepPolicy,
+		client:         client,
+		cepProviders:   cepProviders,
+		geocoder:       geocoder,
+		fetchWeather:   temperature,
+		mockWeather:    stamped(demoWeatherProvider{}),
+		cache:          newWeatherCache(cacheTTL, cacheStaleTTL, temperature, cacheMetrics),
+		notFound:       newNotFoundCache(envconfig.Duration("NOT_FOUND_CACHE_TTL", time.Minute)),
+		astronomyCache: newAstronomyCache(),
+		degrader:       newDegradationController(rules),
+		jobs:           newJobStore(),
+		unitsPreset:    unitsPreset,
+		etagBucket:     envconfig.Duration("ETAG_TIME_BUCKET", 15*time.Minute),
+		callbacks: &callbackDeliverer{
+			client:      callbackClient,
+			secret:      []byte(os.Getenv("CALLBACK_SIGNING_SECRET")),
+			maxAttempts: envconfig.Int("CALLBACK_MAX_ATTEMPTS", 3),
+			backoff:     envconfig.Duration("CALLBACK_RETRY_BACKOFF", time.Second),
+			pending:     make(chan struct{}, max(envconfig.Int("CALLBACK_MAX_PENDING", 100), 1)),
+		},
+	}
+	srv.toggles, err = toggles.Open(os.Getenv("TOGGLES_PATH"), toggles.Toggles{
+		CacheEnabled:  cacheTTL > 0,
+		CEPProvider:   cepProviderName,
+		SamplingRatio: 1,
+	}, srv.validateToggles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature toggles: %w", err)
+	}
+	if astronomy, ok := weatherProvider.(provider.AstronomyProvider); ok {
+		srv.astronomy = astronomy
+	}
+	if baseURL := os.Getenv("WEATHER_ICON_BASE_URL"); baseURL != "" {
+		if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid WEATHER_ICON_BASE_URL %q: expected an absolute http(s) URL", baseURL)
+		}
+		srv.icons = newIconProxy(baseURL, client)
+	}
+	svc := &Service{srv: srv}
+	if os.Getenv("WEATHERAPI_KEY_CHECK") == "true" && !demoMode {
+		svc.keyCheck = weatherProvider
+	}
+	svc.ready = newReadiness(svc.keyCheck != nil)
+	if dir := os.Getenv("OUTBOX_DIR"); dir != "" {
+		if srv.outbox, err = outbox.Open(filepath.Join(dir, "service-b"), envconfig.Int("OUTBOX_MAX_ENTRIES", 10000)); err != nil {
+			return nil, err
+		}
+		svc.outboxInterval = envconfig.Duration("OUTBOX_RETRY_INTERVAL", 30*time.Second)
+	}
+	for _, key := range strings.Split(envconfig.String("BAGGAGE_SPAN_ATTRIBUTES", "tenant,client.id,enduser.id"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			svc.baggageKeys = append(svc.baggageKeys, key)
+		}
+	}
+	if path := os.Getenv("HISTORY_DB_PATH"); path != "" {
+		repo, err := history.OpenSQLite(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lookup history: %w", err)
+		}
+		svc.closers = append(svc.closers, repo.Close)
+		srv.history = repo
+		if url := os.Getenv("EVENTS_WEBHOOK_URL"); url != "" {
+			srv.events = &eventRelay{store: repo, client: client, url: url, interval: envconfig.Duration("EVENTS_RELAY_INTERVAL", 5*time.Second)}
+		}
+	} else if os.Getenv("EVENTS_WEBHOOK_URL") != "" {
+		return nil, errors.New("EVENTS_WEBHOOK_URL requires HISTORY_DB_PATH")
+	}
+	if path := os.Getenv("CEP_DB_PATH"); path != "" {
+		db, err := cepdb.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open CEP database: %w", err)
+		}
+		svc.closers = append(svc.closers, db.Close)
+		srv.cepDB = db
+	}
+	if path := os.Getenv("CEP_PRELOAD_CSV"); path != "" {
+		// Without CEP_DB_PATH the dataset is kept in memory.
+		if srv.cepDB == nil {
+			db, err := cepdb.Open(":memory:")
+			if err != nil {
+				return nil, fmt.Errorf("failed to open CEP database: %w", err)
+			}
+			svc.closers = append(svc.closers, db.Close)
+			srv.cepDB = db
+		}
+		if err := preloadCEPs(context.Background(), srv.cepDB, path); err != nil {
+			return nil, err
+		}
+	}
+	if dest := os.Getenv("AUDIT_LOG"); dest != "" {
+		cepHasher, err := privacy.FromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if key := os.Getenv("AUDIT_CEP_HASH_KEY"); key != "" {
+			cepHasher = privacy.NewHasher(key)
+		}
+		logger, err := audit.Open(dest, int64(envconfig.Int("AUDIT_LOG_MAX_BYTES", 100<<20)), envconfig.Int("AUDIT_LOG_MAX_FILES", 10), cepHasher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		svc.closers = append(svc.closers, logger.Close)
+		srv.audit = logger
+	}
+	if srv.prewarmInterval = envconfig.Duration("CACHE_PREWARM_INTERVAL", 0); srv.prewarmInterval > 0 && cacheTTL > 0 {
+		srv.prewarmTop = envconfig.Int("CACHE_PREWARM_TOP", 20)
+		srv.popular = newPopularityTracker(srv.prewarmTop * 10)
+	}
+	if srv.alertInterval = envconfig.Duration("ALERT_CHECK_INTERVAL", 5*time.Minute); srv.alertInterval > 0 {
+		srv.alerts = newAlertStore(envconfig.Int("ALERTS_MAX", 1000))
+		srv.notifier = &alertNotifier{
+			channels: map[string]Notifier{
+				channelWebhook: webhookNotifier{callbacks: srv.callbacks},
+				channelSlack:   slackNotifier{client: callbackClient},
+			},
+			maxAttempts: srv.callbacks.maxAttempts,
+			backoff:     srv.callbacks.backoff,
+		}
+		if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+			srv.notifier.channels[channelEmail] = newMailSender(addr, envconfig.String("SMTP_FROM", "alerts@localhost"),
+				os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+		}
+	}
+	if ceps := os.Getenv("TRACKED_CEPS"); ceps != "" {
+		interval := envconfig.Duration("TRACKED_REFRESH_INTERVAL", 5*time.Minute)
+		if srv.tracked, err = newTrackedSet(strings.Split(ceps, ","), interval,
+			envconfig.Duration("TRACKED_MAX_STALENESS", 3*interval)); err != nil {
+			return nil, fmt.Errorf("invalid TRACKED_CEPS: %w", err)
+		}
+	}
+	if demoMode || os.Getenv("DEBUG_MODE") == "true" {
+		srv.zipkinUIURL = envconfig.String("ZIPKIN_UI_URL", "http://localhost:9411/zipkin")
+	}
+
+	compression := compress.Middleware(envconfig.Int("COMPRESSION_MIN_SIZE", 1024), otel.Meter("service-b/http"))
+	mux := http.NewServeMux()
+	instrument := func(h http.HandlerFunc) http.Handler {
+		return otelhttp.NewHandler(tracing.Recover("service-b/http", tracing.WithRoute(chaos.Middleware(opts.Stats.Middleware(compression(h))))), "ServiceB-HTTP-Request",
+			otelhttp.WithSpanNameFormatter(tracing.RouteSpanName))
+	}
+	mux.Handle("GET /weather/{cep}", instrument(srv.weatherHandler))
+	mux.Handle("GET /weather/jobs/{id}", instrument(srv.jobHandler))
+	mux.Handle("GET /weather/coords", instrument(srv.coordsHandler))
+	if srv.astronomy != nil {
+		mux.Handle("GET /weather/{cep}/{view}", instrument(srv.weatherViewHandler))
+	}
+	if srv.icons != nil {
+		mux.Handle("GET /icons/{size}/{period}/{file}", instrument(srv.icons.handler))
+	}
+	mux.HandleFunc("GET /version", version.Handler("service-b"))
+	mux.HandleFunc("GET /healthz", livenessHandler)
+	mux.HandleFunc("GET /readyz", svc.ready.handler)
+	unmatched.Register(mux, instrument)
+	if srv.alerts != nil {
+		mux.Handle("POST /alerts", instrument(srv.createAlertHandler))
+		mux.Handle("GET /alerts", instrument(srv.listAlertsHandler))
+		mux.Handle("GET /alerts/{id}", instrument(srv.getAlertHandler))
+		mux.Handle("DELETE /alerts/{id}", instrument(srv.deleteAlertHandler))
+	}
+	ips, err := ipfilter.New(os.Getenv("IP_ALLOWLIST"), os.Getenv("IP_DENYLIST"), otel.Meter("service-b/http"))
+	if err != nil {
+		return nil, err
+	}
+	svc.handler = ips.Middleware(requestid.Middleware(apiversion.Middleware(mux)))
+
+	svc.admin = http.NewServeMux()
+	svc.admin.HandleFunc("GET /debug/buildinfo", admin.BuildInfoHandler)
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if srv.history != nil {
+		svc.admin.Handle("GET /admin/stats", admin.RequireToken(adminToken, http.HandlerFunc(srv.statsHandler)))
+	}
+	svc.admin.Handle("GET /admin/cache", admin.RequireToken(adminToken, http.HandlerFunc(srv.cacheHandler)))
+	svc.admin.Handle("DELETE /admin/cache", admin.RequireToken(adminToken, http.HandlerFunc(srv.cacheHandler)))
+	svc.admin.Handle("GET /admin/toggles", admin.RequireToken(adminToken, http.HandlerFunc(srv.togglesHandler)))
+	svc.admin.Handle("PATCH /admin/toggles", admin.RequireToken(adminToken, http.HandlerFunc(srv.togglesHandler)))
+	if srv.outbox != nil {
+		svc.admin.Handle("GET /admin/outbox", admin.RequireToken(adminToken, http.HandlerFunc(srv.outbox.StatusHandler)))
+	}
+	if srv.cepDB != nil {
+		svc.admin.Handle("GET /admin/cepdb", admin.RequireToken(adminToken, http.HandlerFunc(srv.cepDBHandler)))
+		svc.admin.Handle("POST /admin/cepdb", admin.RequireToken(adminToken, http.HandlerFunc(srv.cepDBHandler)))
+	}
+
+	return svc, nil
+}
+
+// Start launches background work: the WeatherAPI key check, the outbox
+// replays, the event relay, the temperature alert scheduler, the cache
+// pre-warmer, the tracked CEP refresher and, when KAFKA_BROKERS is set, the
+// Kafka consumer for asynchronous lookups. It stops when ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	if s.keyCheck != nil {
+		go s.ready.checkWeatherAPIKey(ctx, s.keyCheck)
+	}
+	if s.srv.outbox != nil {
+		go s.srv.outbox.Run(ctx, s.outboxInterval, s.srv.replayOutbox)
+	}
+	if s.srv.events != nil {
+		go s.srv.events.run(ctx)
+	}
+	if s.srv.tracked != nil {
+		go s.srv.runTracked(ctx)
+	}
+	if s.srv.alerts != nil {
+		go s.srv.runAlerts(ctx, s.srv.alertInterval)
+	}
+	if s.srv.popular != nil {
+		go s.srv.runPrewarm(ctx, s.srv.prewarmInterval, s.srv.prewarmTop)
+	}
+
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		return
+	}
+	topic := envconfig.String("KAFKA_LOOKUP_TOPIC", "weather-lookups")
+	consumer := asyncjobs.NewConsumer(strings.Split(brokers, ","), topic, "service-b")
+	s.closers = append(s.closers, consumer.Close)
+	go func() {
+		if err := consumer.Run(ctx, s.srv.resolveJob); err != nil {
+			log.Printf("Async lookup consumer stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("Consuming async lookups from Kafka topic %s\n", topic)
+}
+
+// Handler serves the public API.
+func (s *Service) Handler() http.Handler { return s.handler }
+
+// AdminHandler serves the admin endpoints, meant for a separate port.
+func (s *Service) AdminHandler() http.Handler { return s.admin }
+
+// Client is the instrumented HTTP client used for outgoing calls.
+func (s *Service) Client() *http.Client { return s.srv.client }
+
+// Sampler samples root spans at the ratio set through the feature toggles.
+func (s *Service) Sampler() sdktrace.Sampler {
+	return sdktrace.ParentBased(s.srv.toggles.Sampler())
+}
+
+// SpanProcessor promotes the BAGGAGE_SPAN_ATTRIBUTES baggage members set by
+// Service A to attributes on every span.
+func (s *Service) SpanProcessor() sdktrace.SpanProcessor {
+	return tracing.NewBaggageSpanProcessor(s.baggageKeys)
+}
+
+func (s *Service) CacheStats() map[string]int64 { return s.srv.cache.Stats() }
+
+func (s *Service) Close() error {
+	var errs []error
+	for _, closer := range s.closers {
+		errs = append(errs, closer())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/cep"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// cacheTracked marks responses served by the tracked CEP refresher.
+const cacheTracked cacheStatus = "TRACKED"
+
+type trackedEntry struct {
+	address     provider.Address
+	observation provider.Observation
+	refreshedAt time.Time
+}
+
+// trackedSet keeps the address and weather of a fixed set of CEPs in memory,
+// refreshed every interval, so their reads never wait on an upstream. An
+// entry older than maxStale is not served: reads fall back to the regular
+// lookup until a refresh succeeds again.
+type trackedSet struct {
+	ceps     []string
+	interval time.Duration
+	maxStale time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]trackedEntry
+}
+
+func newTrackedSet(ceps []string, interval, maxStale time.Duration) (*trackedSet, error) {
+	t := &trackedSet{interval: interval, maxStale: maxStale, entries: make(map[string]trackedEntry)}
+	for _, raw := range ceps {
+		code, err := cep.Normalize(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CEP %q", raw)
+		}
+		t.ceps = append(t.ceps, code)
+	}
+	return t, nil
+}
+
+// get returns the entry of cep while it is fresh enough to serve.
+func (t *trackedSet) get(cep string) (trackedEntry, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry, ok := t.entries[cep]
+	if !ok || time.Since(entry.refreshedAt) > t.maxStale {
+		return trackedEntry{}, false
+	}
+	return entry, true
+}
+
+func (t *trackedSet) set(cep string, entry trackedEntry) {
+	t.mu.Lock()
+	t.entries[cep] = entry
+	t.mu.Unlock()
+}
+
+// runTracked refreshes the tracked CEPs right away and then every interval
+// until ctx is done.
+func (s *server) runTracked(ctx context.Context) {
+	ticker := time.NewTicker(s.tracked.interval)
+	defer ticker.Stop()
+	for {
+		s.refreshTracked(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshTracked runs one pass as its own trace. CEPs of the same city
+// share a single weather call.
+func (s *server) refreshTracked(ctx context.Context) {
+	tracer := otel.Tracer("service-b/tracked")
+	ctx, span := tracer.Start(ctx, "refresh-tracked-weather", trace.WithNewRoot(), trace.WithAttributes(
+		attribute.Int("tracked.ceps", len(s.tracked.ceps)),
+	))
+	defer span.End()
+
+	features := s.toggles.Get()
+	cepProvider, err := s.cepProviderFor(features)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "no CEP provider")
+		return
+	}
+	observations := make(map[string]provider.Observation)
+	refreshed := 0
+	for _, code := range s.tracked.ceps {
+		address, err := provider.ResolveAddress(ctx, cepProvider, code)
+		if err != nil {
+			span.RecordError(err, trace.WithAttributes(attribute.String("cep", code)))
+			continue
+		}
+		if address.Latitude == nil && s.geocoder != nil {
+			s.geocode(ctx, &lookupResult{}, code, &address)
+		}
+		query := placename.WeatherQuery(address.City, address.UF)
+		obs, ok := observations[query]
+		if !ok {
+			obs, err = s.fetchWeather(ctx, query)
+			var mismatch *regionMismatchError
+			if errors.As(err, &mismatch) && address.Latitude != nil && address.Longitude != nil {
+				obs, err = s.fetchWeather(ctx, coordinatesQuery(*address.Latitude, *address.Longitude))
+			}
+			if err != nil {
+				span.RecordError(err, trace.WithAttributes(attribute.String("cep", code)))
+				continue
+			}
+			observations[query] = obs
+		}
+		s.tracked.set(code, trackedEntry{address: address, observation: obs, refreshedAt: time.Now()})
+		refreshed++
+	}
+	span.SetAttributes(attribute.Int("tracked.refreshed", refreshed))
+	if refreshed < len(s.tracked.ceps) {
+		span.SetStatus(codes.Error, "some tracked CEPs were not refreshed")
+	}
+}
@@ -0,0 +1,236 @@
+package serviceb
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/apiversion"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/formats"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+)
+
+// unitsPreset selects which temperature and wind fields a response carries
+// and how many decimals they are rounded to.
+type unitsPreset struct {
+	temps     []string
+	wind      string
+	precision int
+}
+
+var unitsPresets = map[string]unitsPreset{
+	"metric":     {temps: []string{"C"}, wind: "kph", precision: 1},
+	"imperial":   {temps: []string{"F"}, wind: "mph", precision: 1},
+	"scientific": {temps: []string{"K"}, wind: "ms", precision: 2},
+}
+
+func unitsPresetNames() []string {
+	names := make([]string, 0, len(unitsPresets))
+	for name := range unitsPresets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PresetWeatherResponse is the body served when a units preset is selected.
+// Fields outside the preset are left out.
+type PresetWeatherResponse struct {
+	City       string   `json:"city"`
+	UF         string   `json:"uf,omitempty"`
+	IBGECode   string   `json:"ibge_code,omitempty"`
+	Latitude   *float64 `json:"latitude,omitempty"`
+	Longitude  *float64 `json:"longitude,omitempty"`
+	Resolution string   `json:"resolution,omitempty"`
+	Source     string   `json:"source,omitempty"`
+
+	Address *AddressResponse `json:"address,omitempty"`
+
+	Units   string   `json:"units"`
+	TempC   *float64 `json:"temp_C,omitempty"`
+	TempF   *float64 `json:"temp_F,omitempty"`
+	TempK   *float64 `json:"temp_K,omitempty"`
+	WindKph *float64 `json:"wind_kph,omitempty"`
+	WindMph *float64 `json:"wind_mph,omitempty"`
+	WindMs  *float64 `json:"wind_ms,omitempty"`
+
+	Condition *ConditionResponse `json:"condition,omitempty"`
+
+	ObservedAt time.Time  `json:"observed_at"`
+	TimeZone   string     `json:"timezone,omitempty"`
+	LocalTime  *time.Time `json:"local_time,omitempty"`
+
+	AirQuality *AirQualityResponse `json:"air_quality,omitempty"`
+
+	ZipkinURL string `json:"zipkin_url,omitempty"`
+}
+
+// renderOptions are the response options a client picks through the query
+// string and, for the format, the Accept header.
+type renderOptions struct {
+	preset     string
+	airQuality bool
+	address    bool
+	fields     []string
+	// version is the API version, which names the fields.
+	version   int
+	mediaType string
+	encode    formats.Encoder
+}
+
+// includeOptions are the values accepted by ?include=.
+var includeOptions = []string{"address", "aqi"}
+
+// renderOptions reads ?units=, ?include= and ?fields=, falling back to the
+// server's default preset, and negotiates the format. Unknown values are answered
+// with 400 and unsupported formats with 406.
+func (s *server) renderOptions(w http.ResponseWriter, r *http.Request) (renderOptions, bool) {
+	opts := renderOptions{preset: s.unitsPreset}
+	opts.version, _ = apiversion.FromContext(r.Context())
+	var ok bool
+	if opts.mediaType, opts.encode, ok = formats.Negotiate(r.Header.Get("Accept")); !ok {
+		i18n.Error(w, r, http.StatusNotAcceptable, "Not Acceptable: available formats are %v", formats.MediaTypes())
+		return opts, false
+	}
+	if units := r.URL.Query().Get("units"); units != "" {
+		opts.preset = units
+	}
+	if _, ok := unitsPresets[opts.preset]; opts.preset != "" && !ok {
+		i18n.Error(w, r, http.StatusBadRequest, "Bad Request: unknown units preset %q (available: %v)", opts.preset, unitsPresetNames())
+		return opts, false
+	}
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "address":
+			opts.address = true
+		case "aqi":
+			opts.airQuality = true
+		default:
+			i18n.Error(w, r, http.StatusBadRequest, "Bad Request: unknown include %q (available: %v)", include, includeOptions)
+			return opts, false
+		}
+	}
+	if opts.fields, ok = parseFields(w, r, opts.preset, opts.version); !ok {
+		return opts, false
+	}
+	return opts, true
+}
+
+// parseFields reads ?fields=, checking each name against the body the
+// preset selects, named as in API version v. The names are returned as in
+// version 1, the naming of the Go types.
+func parseFields(w http.ResponseWriter, r *http.Request, preset string, v int) ([]string, bool) {
+	var fields []string
+	for _, name := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, true
+	}
+	body := reflect.TypeFor[WeatherResponse]()
+	if preset != "" {
+		body = reflect.TypeFor[PresetWeatherResponse]()
+	}
+	known := formats.FieldNames(body)
+	names := known
+	if v >= 2 {
+		names = make([]string, len(known))
+		for i, name := range known {
+			names[i] = snakeCase(name)
+		}
+	}
+	for i, name := range fields {
+		j := slices.Index(names, name)
+		if j < 0 {
+			i18n.Error(w, r, http.StatusBadRequest, "Bad Request: unknown field %q (available: %v)", name, names)
+			return nil, false
+		}
+		fields[i] = known[j]
+	}
+	return fields, true
+}
+
+// renderWeather builds the response body for result. An empty preset keeps
+// the original body with every temperature scale and no wind. Air quality and
+// the CEP's area and SIAFI codes are left out unless requested and known,
+// ?fields= keeps only the fields it names, and the API version names them.
+func renderWeather(result lookupResult, opts renderOptions) (any, error) {
+	body, err := renderPreset(result, opts)
+	if err == nil && len(opts.fields) > 0 {
+		body, err = formats.Select(body, opts.fields)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return versionedBody(body, opts.version)
+}
+
+func renderPreset(result lookupResult, opts renderOptions) (any, error) {
+	var airQuality *AirQualityResponse
+	if aq := result.airQuality; opts.airQuality && aq != nil {
+		airQuality = &AirQualityResponse{PM25: aq.PM25, PM10: aq.PM10, USEPAIndex: aq.USEPAIndex}
+	}
+	var address *AddressResponse
+	if opts.address && result.address != (AddressResponse{}) {
+		address = &result.address
+	}
+	preset := opts.preset
+	if preset == "" {
+		resp := result.response
+		resp.Address, resp.AirQuality = address, airQuality
+		return resp, nil
+	}
+	p, ok := unitsPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown units preset %q (available: %v)", preset, unitsPresetNames())
+	}
+
+	round := func(v float64) *float64 {
+		scale := math.Pow(10, float64(p.precision))
+		v = math.Round(v*scale) / scale
+		return &v
+	}
+	resp := PresetWeatherResponse{
+		City:       result.response.City,
+		UF:         result.response.UF,
+		IBGECode:   result.response.IBGECode,
+		Latitude:   result.response.Latitude,
+		Longitude:  result.response.Longitude,
+		Resolution: result.response.Resolution,
+		Source:     result.response.Source,
+		Address:    address,
+		Units:      preset,
+		Condition:  result.response.Condition,
+		ObservedAt: result.response.ObservedAt,
+		TimeZone:   result.response.TimeZone,
+		LocalTime:  result.response.LocalTime,
+		AirQuality: airQuality,
+		ZipkinURL:  result.response.ZipkinURL,
+	}
+	for _, temp := range p.temps {
+		switch temp {
+		case "C":
+			resp.TempC = round(result.response.TempC)
+		case "F":
+			resp.TempF = round(result.response.TempF)
+		case "K":
+			resp.TempK = round(result.response.TempK)
+		}
+	}
+	switch p.wind {
+	case "kph":
+		resp.WindKph = round(result.windKph)
+	case "mph":
+		resp.WindMph = round(result.windKph / 1.609344)
+	case "ms":
+		resp.WindMs = round(result.windKph / 3.6)
+	}
+	return resp, nil
+}
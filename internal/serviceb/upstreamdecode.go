@@ -0,0 +1,59 @@
+package serviceb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// maxUpstreamBody caps how much of an upstream answer is read.
+	maxUpstreamBody = 1 << 20
+	// upstreamSnippetLen is how much of an undecodable body goes on the span.
+	upstreamSnippetLen = 256
+)
+
+// decodeUpstream decodes the JSON body of resp, upstream's answer, into v.
+// An HTML error page, rate limit text or broken JSON is the upstream's
+// fault, not the caller's: its content type and the start of the body are
+// recorded on span and the error wraps provider.ErrBadResponse.
+func decodeUpstream(span trace.Span, upstream string, resp *http.Response, v any) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBody))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("%w: error reading %s response: %w", provider.ErrUnavailable, upstream, err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); contentType != "" && mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		err = fmt.Errorf("unexpected content type %q", contentType)
+	} else {
+		err = json.Unmarshal(body, v)
+	}
+	if err == nil {
+		return nil
+	}
+
+	span.SetAttributes(
+		attribute.String("upstream.response.content_type", contentType),
+		attribute.String("upstream.response.snippet", snippet(body)),
+	)
+	span.RecordError(err)
+	return fmt.Errorf("%w: %s: %w", provider.ErrBadResponse, upstream, err)
+}
+
+// snippet is the start of body, cut to upstreamSnippetLen bytes without
+// splitting a character.
+func snippet(body []byte) string {
+	if len(body) > upstreamSnippetLen {
+		body = body[:upstreamSnippetLen]
+	}
+	return strings.ToValidUTF8(string(body), "")
+}
@@ -0,0 +1,123 @@
+package serviceb
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// upstreamLatencyBuckets are the boundaries, in seconds, of the upstream
+// duration histogram. The latency objective of each dependency is added to
+// them so its burn rate can be read straight from the buckets too.
+var upstreamLatencyBuckets = []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// upstreamMetrics records, per dependency, how long calls take and whether
+// they met the availability and latency objectives. Burn-rate alerts divide
+// the bad events of upstream.slo.events by all of them over a window.
+type upstreamMetrics struct {
+	targets map[string]string
+	slo     map[string]time.Duration
+	defSLO  time.Duration
+
+	duration metric.Float64Histogram
+	events   metric.Int64Counter
+}
+
+func newUpstreamMetrics(meter metric.Meter, targets map[string]string, slo map[string]time.Duration, defSLO time.Duration) *upstreamMetrics {
+	m := &upstreamMetrics{targets: targets, slo: slo, defSLO: defSLO}
+
+	buckets := slices.Clone(upstreamLatencyBuckets)
+	buckets = append(buckets, defSLO.Seconds())
+	for _, threshold := range slo {
+		buckets = append(buckets, threshold.Seconds())
+	}
+	slices.Sort(buckets)
+	buckets = slices.Compact(buckets)
+
+	var err error
+	if m.duration, err = meter.Float64Histogram("upstream.request.duration",
+		metric.WithDescription("Time until an upstream dependency answered with response headers"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(buckets...),
+	); err != nil {
+		log.Printf("Failed to create upstream duration histogram: %v\n", err)
+	}
+	if m.events, err = meter.Int64Counter("upstream.slo.events",
+		metric.WithDescription("Upstream calls judged against the availability and latency objectives"),
+	); err != nil {
+		log.Printf("Failed to create upstream SLO counter: %v\n", err)
+	}
+	return m
+}
+
+// Wrap records the calls base makes to known dependencies. Transport
+// errors, 5xx and 429 responses count against availability; calls slower
+// than the dependency's objective count against latency.
+func (m *upstreamMetrics) Wrap(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		dependency, ok := m.targets[req.URL.Hostname()]
+		if !ok {
+			return base.RoundTrip(req)
+		}
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		elapsed := time.Since(start)
+
+		ctx := req.Context()
+		outcome := "error"
+		available := err == nil
+		if err == nil {
+			outcome = fmt.Sprintf("%dxx", resp.StatusCode/100)
+			available = resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests
+		}
+		threshold, ok := m.slo[dependency]
+		if !ok {
+			threshold = m.defSLO
+		}
+		dep := attribute.String("upstream.dependency", dependency)
+		if m.duration != nil {
+			m.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(dep, attribute.String("upstream.outcome", outcome)))
+		}
+		if m.events != nil {
+			m.events.Add(ctx, 1, metric.WithAttributes(dep, attribute.String("slo", "availability"), attribute.Bool("slo.good", available)))
+			// Failed calls are already counted against availability; only
+			// the answered ones say something about latency.
+			if available {
+				m.events.Add(ctx, 1, metric.WithAttributes(dep, attribute.String("slo", "latency"), attribute.Bool("slo.good", elapsed <= threshold)))
+			}
+		}
+		return resp, err
+	})
+}
+
+// parseLatencySLO reads specs such as "viacep=300ms,weatherapi=1s". An
+// entry without a dependency sets the default objective.
+func parseLatencySLO(spec string, def time.Duration) (map[string]time.Duration, time.Duration, error) {
+	slo := make(map[string]time.Duration)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		dependency, value, ok := strings.Cut(item, "=")
+		if !ok {
+			dependency, value = "", item
+		}
+		threshold, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || threshold <= 0 {
+			return nil, 0, fmt.Errorf("invalid latency objective %q", item)
+		}
+		if dependency = strings.TrimSpace(dependency); dependency == "" {
+			def = threshold
+		} else {
+			slo[dependency] = threshold
+		}
+	}
+	return slo, def, nil
+}
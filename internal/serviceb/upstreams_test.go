@@ -0,0 +1,101 @@
+package serviceb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/placename"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+)
+
+// Fixtures served by fakeUpstreams.
+const (
+	testKnownCEP   = "01001000"
+	testKnownCity  = "São Paulo"
+	testKnownTempC = 25.0
+	testUnknownCEP = "99999999"
+	// testUnknownCity is a location the fake WeatherAPI does not know.
+	testUnknownCity = "Atlantis"
+)
+
+// fakeUpstreams serves ViaCEP (/ws/{cep}/json/), BrasilAPI
+// (/api/cep/v2/{cep}) and WeatherAPI (/v1/current.json) for the fixtures
+// above. Their paths do not overlap, so its URL is every adapter's base URL.
+func fakeUpstreams(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws/{cep}/json/", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("cep") != testKnownCEP {
+			writeTestJSON(w, http.StatusOK, map[string]bool{"erro": true})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{"cep": "01001-000", "localidade": testKnownCity, "uf": "SP", "ibge": "3550308", "ddd": "11", "siafi": "7107"})
+	})
+	mux.HandleFunc("GET /api/cep/v2/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("cep") != testKnownCEP {
+			writeTestJSON(w, http.StatusNotFound, map[string]string{"name": "CepPromiseError", "message": "Todos os serviços de CEP retornaram erro."})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]any{"cep": testKnownCEP, "state": "SP", "city": testKnownCity,
+			"location": map[string]any{"coordinates": map[string]string{"latitude": "-23.5505", "longitude": "-46.6333"}}})
+	})
+	mux.HandleFunc("GET /v1/current.json", func(w http.ResponseWriter, r *http.Request) {
+		city, _, _ := strings.Cut(r.URL.Query().Get("q"), ",")
+		if !placename.Equal(city, testKnownCity) {
+			writeTestJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]any{"code": 1006, "message": "No matching location found."}})
+			return
+		}
+		current := map[string]any{"temp_c": testKnownTempC, "wind_kph": 10.0, "last_updated_epoch": time.Now().Unix(),
+			"condition": map[string]any{"text": "Sunny", "icon": "//cdn.weatherapi.com/weather/64x64/day/113.png", "code": 1000}}
+		if r.URL.Query().Get("aqi") == "yes" {
+			current["air_quality"] = map[string]any{"pm2_5": 12.5, "pm10": 20.25, "us-epa-index": 2}
+		}
+		writeTestJSON(w, http.StatusOK, map[string]any{
+			"location": map[string]string{"name": testKnownCity, "region": "Sao Paulo", "tz_id": "America/Sao_Paulo"},
+			"current":  current,
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestService starts Service B against upstream, with env on top of
+// settings that keep every lookup going to the upstreams. It sets
+// environment variables, so tests using it must not run in parallel.
+func newTestService(t *testing.T, upstream *httptest.Server, env map[string]string) *Service {
+	t.Helper()
+	settings := map[string]string{
+		"DEMO_MODE":            "",
+		"CEP_PROVIDER":         "viacep",
+		"WEATHER_API_KEY":      "test",
+		"WEATHER_CACHE_TTL":    "0",
+		"NOT_FOUND_CACHE_TTL":  "0",
+		"ALERT_CHECK_INTERVAL": "0",
+		"VIACEP_BASE_URL":      upstream.URL,
+		"WEATHERAPI_BASE_URL":  upstream.URL,
+		"BRASILAPI_URL":        upstream.URL,
+	}
+	for key, value := range env {
+		settings[key] = value
+	}
+	for key, value := range settings {
+		t.Setenv(key, value)
+	}
+	svc, err := New(Options{Stats: shutdownreport.NewCollector()})
+	if err != nil {
+		t.Fatalf("starting Service B: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	return svc
+}
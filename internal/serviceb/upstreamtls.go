@@ -0,0 +1,116 @@
+package serviceb
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// upstreamTLSConfig returns the TLS settings of the upstream client, or nil
+// to keep Go's defaults. caFile adds a PEM bundle to the system roots, for
+// networks that intercept TLS with their own CA. pinSpec lists
+// dependency=pin entries, pin being the base64 SHA-256 of a certificate's
+// SubjectPublicKeyInfo; connections to a pinned dependency must have one of
+// its pins somewhere in the verified chain. dependencies maps the hosts of
+// the upstream base URLs, ports included, to their dependency names.
+func upstreamTLSConfig(caFile, pinSpec string, dependencies map[string]string) (*tls.Config, error) {
+	pins, err := parsePins(pinSpec, dependencies)
+	if err != nil {
+		return nil, err
+	}
+	// TLS names the server without its port, so pins are looked up by host
+	// name. Every dependency served from a pinned host name is held to its
+	// pins. IP addresses are not sent as server names, so a pinned
+	// dependency reached by address could never be checked.
+	pinsByHost := make(map[string]map[string]bool)
+	for host, dependency := range dependencies {
+		name := hostnameOf(host)
+		if _, err := netip.ParseAddr(name); err == nil && len(pins[dependency]) > 0 {
+			return nil, fmt.Errorf("cannot pin %s: its base URL must use a host name, not the address %s", dependency, name)
+		}
+		for pin := range pins[dependency] {
+			if pinsByHost[name] == nil {
+				pinsByHost[name] = make(map[string]bool)
+			}
+			pinsByHost[name][pin] = true
+		}
+	}
+	if caFile == "" && len(pins) == 0 {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = roots
+	}
+	if len(pins) > 0 {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			wanted, ok := pinsByHost[strings.TrimSuffix(strings.ToLower(cs.ServerName), ".")]
+			if !ok {
+				return nil
+			}
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					if wanted[base64.StdEncoding.EncodeToString(sum[:])] {
+						return nil
+					}
+				}
+			}
+			return errors.New("certificate chain of " + cs.ServerName + " matches none of its pinned keys")
+		}
+	}
+	return cfg, nil
+}
+
+// hostnameOf strips the port from host, as url.URL.Hostname does.
+func hostnameOf(host string) string {
+	return strings.TrimSuffix(strings.ToLower((&url.URL{Host: host}).Hostname()), ".")
+}
+
+func parsePins(spec string, dependencies map[string]string) (map[string]map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, name := range dependencies {
+		known[name] = true
+	}
+	pins := make(map[string]map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		dependency, pin, ok := strings.Cut(item, "=")
+		dependency = strings.TrimSpace(dependency)
+		if !ok || !known[dependency] {
+			return nil, fmt.Errorf("invalid pin %q: expected <dependency>=<base64 sha256>", item)
+		}
+		// Base64 pins end in "=", so only the first one separates the name.
+		pin = strings.TrimSpace(pin)
+		if sum, err := base64.StdEncoding.DecodeString(pin); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %q: not a base64 SHA-256 digest", item)
+		}
+		if pins[dependency] == nil {
+			pins[dependency] = make(map[string]bool)
+		}
+		pins[dependency][pin] = true
+	}
+	return pins, nil
+}
@@ -0,0 +1,82 @@
+package serviceb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpstreamTLSPins(t *testing.T) {
+	// The test certificate is valid for example.com, which the client below
+	// resolves to the test server.
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	cert := upstream.Certificate()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+	// The base URL carries a port, which TLS leaves out of the server name.
+	baseURL := "https://example.com:" + port
+	dependencies := map[string]string{hostOf(baseURL): "weatherapi", viaCEPHost: "viacep"}
+
+	tests := []struct {
+		name    string
+		pins    string
+		wantErr bool
+	}{
+		{name: "no pins", pins: ""},
+		{name: "matching pin", pins: "weatherapi=" + pin},
+		{name: "one of the pins matches", pins: "weatherapi=" + otherPin + ",weatherapi=" + pin},
+		{name: "no matching pin", pins: "weatherapi=" + otherPin, wantErr: true},
+		{name: "only another dependency pinned", pins: "viacep=" + otherPin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := upstreamTLSConfig(caFile, tt.pins, dependencies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := &http.Transport{
+				TLSClientConfig: cfg,
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, network, upstream.Listener.Addr().String())
+				},
+			}
+			defer transport.CloseIdleConnections()
+			resp, err := (&http.Client{Transport: transport}).Get(baseURL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "pinned keys") {
+				t.Errorf("error = %v, want a pin mismatch", err)
+			}
+		})
+	}
+}
+
+func TestUpstreamTLSPinsNeedHostNames(t *testing.T) {
+	pin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	dependencies := map[string]string{"127.0.0.1:8443": "weatherapi", viaCEPHost: "viacep"}
+	if _, err := upstreamTLSConfig("", "weatherapi="+pin, dependencies); err == nil {
+		t.Fatal("pinning a dependency reached by IP address was accepted")
+	}
+	if _, err := upstreamTLSConfig("", "viacep="+pin, dependencies); err != nil {
+		t.Fatalf("pinning a dependency reached by host name: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package serviceb
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Default hosts of the upstreams, reached over UPSTREAM_SCHEME unless their
+// base URL is configured.
+const (
+	viaCEPHost     = "viacep.com.br"
+	brasilAPIHost  = "brasilapi.com.br"
+	weatherAPIHost = "api.weatherapi.com"
+)
+
+// upstreamScheme is the scheme of the default upstream endpoints: https unless UPSTREAM_SCHEME asks for plain http.
+func upstreamScheme(getenv func(string) string) (string, error) {
+	switch scheme := getenv("UPSTREAM_SCHEME"); scheme {
+	case "", "https":
+		return "https", nil
+	case "http":
+		return scheme, nil
+	default:
+		return "", fmt.Errorf("invalid UPSTREAM_SCHEME %q: expected http or https", scheme)
+	}
+}
+
+// upstreamBaseURL returns the base URL in the env var key, such as a staging
+// mirror or a test fake, or the default host otherwise. Request paths are
+// appended to it, so it may carry a path prefix but no trailing slash.
+func upstreamBaseURL(getenv func(string) string, key, defaultHost string) (string, error) {
+	raw := getenv(key)
+	if raw == "" {
+		scheme, err := upstreamScheme(getenv)
+		if err != nil {
+			return "", err
+		}
+		return scheme + "://" + defaultHost, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return "", fmt.Errorf("invalid %s %q: expected an absolute http(s) URL without query", key, raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
+
+// hostOf returns the host of a URL returned by upstreamBaseURL.
+func hostOf(baseURL string) string {
+	u, _ := url.Parse(baseURL)
+	return u.Host
+}
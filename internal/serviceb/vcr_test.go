@@ -0,0 +1,47 @@
+package serviceb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWeatherLookupReplaysCassettes(t *testing.T) {
+	upstream := fakeUpstreams(t)
+	dir := t.TempDir()
+	vcr := func(mode string) map[string]string {
+		return map[string]string{
+			"UPSTREAM_VCR_MODE":  mode,
+			"UPSTREAM_VCR_DIR":   dir,
+			"UPSTREAM_VCR_HOSTS": strings.TrimPrefix(upstream.URL, "http://"),
+		}
+	}
+	lookup := func(svc *Service) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		svc.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather/"+testKnownCEP, nil))
+		return w
+	}
+
+	recorded := lookup(newTestService(t, upstream, vcr("record")))
+	if recorded.Code != http.StatusOK {
+		t.Fatalf("recording lookup answered %d: %s", recorded.Code, recorded.Body)
+	}
+	// The cassettes must be enough on their own.
+	upstream.Close()
+	replayed := lookup(newTestService(t, upstream, vcr("replay")))
+	if replayed.Code != http.StatusOK {
+		t.Fatalf("replayed lookup answered %d: %s", replayed.Code, replayed.Body)
+	}
+	// local_time is the time of the lookup; the rest comes from upstream.
+	var want, got map[string]any
+	json.Unmarshal(recorded.Body.Bytes(), &want)
+	json.Unmarshal(replayed.Body.Bytes(), &got)
+	delete(want, "local_time")
+	delete(got, "local_time")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+}
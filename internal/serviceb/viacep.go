@@ -0,0 +1,125 @@
+package serviceb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ViaCEPResponse struct {
+	Localidade string `json:"localidade"`
+	UF         string `json:"uf"`
+	IBGE       string `json:"ibge"`
+	DDD        string `json:"ddd"`
+	SIAFI      string `json:"siafi"`
+	Erro       bool   `json:"erro,omitempty"`
+}
+
+func init() {
+	provider.RegisterCEPProvider("viacep", func(deps provider.Deps) (provider.CEPProvider, error) {
+		baseURL, err := upstreamBaseURL(deps.Getenv, "VIACEP_BASE_URL", viaCEPHost)
+		if err != nil {
+			return nil, err
+		}
+		return &viaCEPProvider{name: "viacep", baseURL: baseURL, client: deps.Client}, nil
+	})
+	// viacep-mirror talks to a ViaCEP compatible mirror, typically used as
+	// the hedging target for viacep.
+	provider.RegisterCEPProvider("viacep-mirror", func(deps provider.Deps) (provider.CEPProvider, error) {
+		baseURL := deps.Getenv("VIACEP_MIRROR_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("VIACEP_MIRROR_URL is required for the viacep-mirror provider")
+		}
+		return &viaCEPProvider{name: "viacep-mirror", baseURL: strings.TrimRight(baseURL, "/"), client: deps.Client}, nil
+	})
+}
+
+type viaCEPProvider struct {
+	name    string
+	baseURL string
+	client  *http.Client
+}
+
+func (p *viaCEPProvider) Name() string { return p.name }
+
+func (p *viaCEPProvider) Locate(ctx context.Context, cep string) (string, error) {
+	address, err := p.Address(ctx, cep)
+	return address.City, err
+}
+
+func (p *viaCEPProvider) Address(ctx context.Context, cep string) (provider.Address, error) {
+	tracer := otel.Tracer("service-b/viacep-client")
+	ctx, span := tracer.Start(ctx, "call-viacep-api", trace.WithAttributes(
+		attribute.String("provider.name", p.Name()),
+		attribute.String("cep.input", cep),
+	))
+	defer span.End()
+
+	apiURL := fmt.Sprintf("%s/ws/%s/json/", p.baseURL, cep)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create viacep request")
+		return provider.Address{}, fmt.Errorf("error creating ViaCEP request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to call viacep api")
+		return provider.Address{}, fmt.Errorf("%w: error fetching CEP data: %w", provider.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	// ViaCEP answers an unknown CEP with 200 and "erro", and a malformed one
+	// with 400.
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		span.SetStatus(codes.Error, "viacep rejected cep")
+		return provider.Address{}, fmt.Errorf("%w: ViaCEP responded %s", provider.ErrInvalidCEP, resp.Status)
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		span.SetStatus(codes.Error, "viacep unavailable")
+		return provider.Address{}, provider.NewStatusError("ViaCEP", resp)
+	case resp.StatusCode != http.StatusOK:
+		span.SetStatus(codes.Error, "viacep rejected request")
+		return provider.Address{}, provider.NewStatusError("ViaCEP", resp)
+	}
+
+	var viaCEPResp ViaCEPResponse
+	if err := decodeUpstream(span, "ViaCEP", resp, &viaCEPResp); err != nil {
+		span.SetStatus(codes.Error, "failed to decode viacep response")
+		return provider.Address{}, err
+	}
+
+	if viaCEPResp.Erro {
+		span.SetAttributes(attribute.Bool("viacep.error", true))
+		span.SetStatus(codes.Error, "viacep returned error flag")
+		return provider.Address{}, provider.ErrNotFound
+	}
+
+	if viaCEPResp.Localidade == "" {
+		span.SetStatus(codes.Error, "viacep returned empty location")
+		return provider.Address{}, provider.ErrNotFound
+	}
+
+	span.SetAttributes(attribute.String("viacep.location", viaCEPResp.Localidade))
+	span.SetStatus(codes.Ok, "location found")
+	return provider.Address{
+		City:     viaCEPResp.Localidade,
+		UF:       viaCEPResp.UF,
+		IBGECode: viaCEPResp.IBGE,
+		DDD:      viaCEPResp.DDD,
+		SIAFI:    viaCEPResp.SIAFI,
+	}, nil
+}
@@ -0,0 +1,217 @@
+package serviceb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type WeatherAPIResponse struct {
+	Location struct {
+		Name   string `json:"name"`
+		Region string `json:"region"`
+		TzID   string `json:"tz_id"`
+	} `json:"location"`
+	Current struct {
+		TempC            float64 `json:"temp_c"`
+		WindKph          float64 `json:"wind_kph"`
+		LastUpdatedEpoch int64   `json:"last_updated_epoch"`
+		Condition        *struct {
+			Text string `json:"text"`
+			Icon string `json:"icon"`
+			Code int    `json:"code"`
+		} `json:"condition"`
+		AirQuality *struct {
+			PM25       float64 `json:"pm2_5"`
+			PM10       float64 `json:"pm10"`
+			USEPAIndex int     `json:"us-epa-index"`
+		} `json:"air_quality"`
+	} `json:"current"`
+	Error *weatherAPIError `json:"error,omitempty"`
+}
+
+type weatherAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type weatherAPIProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	errors  metric.Int64Counter
+}
+
+func newWeatherAPIProvider(client *http.Client, baseURL, apiKey string) *weatherAPIProvider {
+	errors, err := otel.Meter("service-b/upstream").Int64Counter("weatherapi.errors",
+		metric.WithDescription("WeatherAPI answers that were not an observation, by error type"),
+	)
+	if err != nil {
+		log.Printf("Failed to create WeatherAPI error counter: %v\n", err)
+	}
+	return &weatherAPIProvider{client: client, baseURL: baseURL, apiKey: apiKey, errors: errors}
+}
+
+// weatherAPIFailure is how a WeatherAPI error is reported: kind labels the
+// weatherapi.errors counter and the span, err is what the provider returns.
+type weatherAPIFailure struct {
+	kind string
+	err  error
+}
+
+// weatherAPIErrorCodes maps the error codes WeatherAPI sends with its 400,
+// 401 and 403 answers to the provider error taxonomy.
+var weatherAPIErrorCodes = map[int]weatherAPIFailure{
+	1002: {"unauthorized", provider.ErrUnauthorized},    // API key not provided
+	1003: {"rejected", provider.ErrRejected},            // q not provided
+	1005: {"rejected", provider.ErrRejected},            // invalid request URL
+	1006: {"not_found", provider.ErrNotFound},           // no matching location
+	2006: {"unauthorized", provider.ErrUnauthorized},    // invalid API key
+	2007: {"quota_exceeded", provider.ErrQuotaExceeded}, // monthly calls exceeded
+	2008: {"unauthorized", provider.ErrUnauthorized},    // API key disabled
+	2009: {"rejected", provider.ErrRejected},            // plan has no access to the resource
+	9999: {"unavailable", provider.ErrUnavailable},      // internal application error
+}
+
+// weatherAPIStatuses classifies error answers without a known error code.
+// Any other 4xx is a rejection.
+var weatherAPIStatuses = map[int]weatherAPIFailure{
+	http.StatusUnauthorized: {"unauthorized", provider.ErrUnauthorized},
+	http.StatusForbidden:    {"quota_exceeded", provider.ErrQuotaExceeded},
+}
+
+// fail classifies a non-observation answer by apiErr's code, then by status,
+// records it on span and the weatherapi.errors counter, and returns the
+// provider error for it. apiErr is nil when the body carried none.
+func (p *weatherAPIProvider) fail(ctx context.Context, span trace.Span, resp *http.Response, apiErr *weatherAPIError) error {
+	f, ok := weatherAPIStatuses[resp.StatusCode]
+	if !ok {
+		f = weatherAPIFailure{"rejected", provider.ErrRejected}
+	}
+	if apiErr != nil {
+		if byCode, ok := weatherAPIErrorCodes[apiErr.Code]; ok {
+			f = byCode
+		}
+	}
+
+	span.SetAttributes(attribute.String("weatherapi.error.type", f.kind))
+	span.SetStatus(codes.Error, "weatherapi "+strings.ReplaceAll(f.kind, "_", " "))
+	p.countError(ctx, f.kind, resp.StatusCode)
+
+	switch {
+	case f.err == provider.ErrNotFound:
+		return provider.ErrNotFound
+	case apiErr != nil:
+		return fmt.Errorf("%w: WeatherAPI error (%d): %s", f.err, apiErr.Code, apiErr.Message)
+	}
+	return fmt.Errorf("%w: WeatherAPI responded %s", f.err, resp.Status)
+}
+
+func (p *weatherAPIProvider) countError(ctx context.Context, kind string, status int) {
+	if p.errors != nil {
+		p.errors.Add(ctx, 1, metric.WithAttributes(attribute.String("error.type", kind), semconv.HTTPResponseStatusCode(status)))
+	}
+}
+
+func (p *weatherAPIProvider) Name() string { return "weatherapi" }
+
+// get calls the WeatherAPI endpoint with params and decodes its answer into
+// v, whose error object apiErr points to. Every failure is recorded on span
+// and returned as a provider error.
+func (p *weatherAPIProvider) get(ctx context.Context, span trace.Span, endpoint string, params url.Values, v any, apiErr **weatherAPIError) error {
+	params.Set("key", p.apiKey)
+	apiURL := fmt.Sprintf("%s/v1/%s?%s", p.baseURL, endpoint, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create weatherapi request")
+		return fmt.Errorf("error creating WeatherAPI request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to call weatherapi")
+		return fmt.Errorf("%w: error fetching weather data: %w", provider.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		span.SetStatus(codes.Error, "weatherapi unavailable")
+		p.countError(ctx, "unavailable", resp.StatusCode)
+		return provider.NewStatusError("WeatherAPI", resp)
+	}
+
+	if err := decodeUpstream(span, "WeatherAPI", resp, v); err != nil {
+		// An error answer is classified by its status even when its body is
+		// unreadable, so a bad key is not reported as a bad response.
+		if resp.StatusCode != http.StatusOK {
+			return p.fail(ctx, span, resp, nil)
+		}
+		span.SetStatus(codes.Error, "failed to decode weatherapi response")
+		return err
+	}
+
+	if e := *apiErr; e != nil {
+		span.SetAttributes(
+			attribute.Bool("weatherapi.error", true),
+			attribute.Int("weatherapi.error.code", e.Code),
+			attribute.String("weatherapi.error.message", e.Message),
+		)
+		return p.fail(ctx, span, resp, e)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return p.fail(ctx, span, resp, nil)
+	}
+	return nil
+}
+
+func (p *weatherAPIProvider) Current(ctx context.Context, location string) (provider.Observation, error) {
+	tracer := otel.Tracer("service-b/weatherapi-client")
+	ctx, span := tracer.Start(ctx, "call-weather-api", trace.WithAttributes(
+		attribute.String("provider.name", p.Name()),
+		attribute.String("weather.location.input", location),
+	))
+	defer span.End()
+
+	var weatherResp WeatherAPIResponse
+	params := url.Values{"q": {location}, "aqi": {"yes"}}
+	if err := p.get(ctx, span, "current.json", params, &weatherResp, &weatherResp.Error); err != nil {
+		return provider.Observation{}, err
+	}
+
+	span.SetAttributes(attribute.Float64("weather.temp_c", weatherResp.Current.TempC))
+	span.SetStatus(codes.Ok, "temperature found")
+	obs := provider.Observation{TempC: weatherResp.Current.TempC, WindKph: weatherResp.Current.WindKph, Location: weatherResp.Location.Name, Region: weatherResp.Location.Region,
+		TimeZone: weatherResp.Location.TzID}
+	if aq := weatherResp.Current.AirQuality; aq != nil {
+		obs.AirQuality = &provider.AirQuality{PM25: aq.PM25, PM10: aq.PM10, USEPAIndex: aq.USEPAIndex}
+	}
+	if c := weatherResp.Current.Condition; c != nil {
+		// Icons come protocol relative, as //cdn.weatherapi.com/...
+		icon := c.Icon
+		if strings.HasPrefix(icon, "//") {
+			icon = "https:" + icon
+		}
+		obs.Condition = &provider.Condition{Code: c.Code, Text: c.Text, IconURL: icon}
+	}
+	if weatherResp.Current.LastUpdatedEpoch > 0 {
+		obs.ObservedAt = time.Unix(weatherResp.Current.LastUpdatedEpoch, 0)
+	}
+	return obs, nil
+}
@@ -0,0 +1,100 @@
+package serviceb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/provider"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWeatherAPIErrorMapping(t *testing.T) {
+	apiError := func(code int) string {
+		return fmt.Sprintf(`{"error":{"code":%d,"message":"test"}}`, code)
+	}
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantErr  error
+		wantKind string
+	}{
+		// By error code, whatever the status.
+		{"no matching location", http.StatusBadRequest, apiError(1006), provider.ErrNotFound, "not_found"},
+		{"key not provided", http.StatusUnauthorized, apiError(1002), provider.ErrUnauthorized, "unauthorized"},
+		{"q not provided", http.StatusBadRequest, apiError(1003), provider.ErrRejected, "rejected"},
+		{"invalid request url", http.StatusBadRequest, apiError(1005), provider.ErrRejected, "rejected"},
+		{"invalid key", http.StatusUnauthorized, apiError(2006), provider.ErrUnauthorized, "unauthorized"},
+		{"monthly calls exceeded", http.StatusForbidden, apiError(2007), provider.ErrQuotaExceeded, "quota_exceeded"},
+		{"key disabled", http.StatusForbidden, apiError(2008), provider.ErrUnauthorized, "unauthorized"},
+		{"plan without access", http.StatusForbidden, apiError(2009), provider.ErrRejected, "rejected"},
+		{"internal application error", http.StatusBadRequest, apiError(9999), provider.ErrUnavailable, "unavailable"},
+		{"error code with 200", http.StatusOK, apiError(1006), provider.ErrNotFound, "not_found"},
+		// By status, when the code is unknown or missing.
+		{"401 with unknown code", http.StatusUnauthorized, apiError(1234), provider.ErrUnauthorized, "unauthorized"},
+		{"401 without body", http.StatusUnauthorized, "", provider.ErrUnauthorized, "unauthorized"},
+		{"403 with html", http.StatusForbidden, "<html>Forbidden</html>", provider.ErrQuotaExceeded, "quota_exceeded"},
+		{"400 with unknown code", http.StatusBadRequest, apiError(1234), provider.ErrRejected, "rejected"},
+		{"404 without body", http.StatusNotFound, "", provider.ErrRejected, "rejected"},
+		// Server errors and rate limiting never reach the mapping.
+		{"500", http.StatusInternalServerError, apiError(1006), provider.ErrUnavailable, ""},
+		{"429", http.StatusTooManyRequests, "", provider.ErrUnavailable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer upstream.Close()
+			spans := tracetest.NewSpanRecorder()
+			previous := otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+			defer otel.SetTracerProvider(previous)
+
+			_, err := newWeatherAPIProvider(upstream.Client(), upstream.URL, "test").Current(context.Background(), testKnownCity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var kind string
+			for _, span := range spans.Ended() {
+				for _, attr := range span.Attributes() {
+					if attr.Key == "weatherapi.error.type" {
+						kind = attr.Value.AsString()
+					}
+				}
+			}
+			if kind != tt.wantKind {
+				t.Errorf("weatherapi.error.type = %q, want %q", kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestLookupErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{provider.ErrNotFound, http.StatusNotFound},
+		{provider.ErrInvalidCEP, http.StatusUnprocessableEntity},
+		{provider.ErrBadResponse, http.StatusBadGateway},
+		{provider.ErrUnauthorized, http.StatusBadGateway},
+		{provider.ErrQuotaExceeded, http.StatusServiceUnavailable},
+		{provider.ErrRejected, http.StatusBadGateway},
+		{provider.ErrUnavailable, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("%w: WeatherAPI error (1): test", tt.err)
+		if got := lookupErrorFor(wrapped, "unexpected: %v").status; got != tt.want {
+			t.Errorf("lookupErrorFor(%v) status = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package shutdownreport collects process-wide counters while a service runs
+// and summarises them when it stops, so rollouts can be checked for a clean
+// exit.
+package shutdownreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type Collector struct {
+	startedAt time.Time
+
+	requests     atomic.Int64
+	clientErrors atomic.Int64
+	serverErrors atomic.Int64
+
+	spansExported atomic.Int64
+	spansDropped  atomic.Int64
+	exporters     sync.Map // exporter name -> *exporterCounts
+}
+
+type exporterCounts struct {
+	exported, dropped, buffered atomic.Int64
+}
+
+// ErrSpansBuffered is returned by a wrapped exporter that could not deliver
+// spans but saved them locally; they are counted as buffered, not dropped.
+var ErrSpansBuffered = errors.New("spans buffered locally")
+
+func NewCollector() *Collector {
+	return &Collector{startedAt: time.Now()}
+}
+
+// Report is the summary logged, and optionally posted to a webhook, on
+// shutdown.
+type Report struct {
+	Service        string    `json:"service"`
+	StartedAt      time.Time `json:"started_at"`
+	StoppedAt      time.Time `json:"stopped_at"`
+	UptimeSeconds  float64   `json:"uptime_seconds"`
+	RequestsServed int64     `json:"requests_served"`
+	ClientErrors   int64     `json:"client_errors"`
+	ServerErrors   int64     `json:"server_errors"`
+	SpansExported  int64     `json:"spans_exported"`
+	SpansDropped   int64     `json:"spans_dropped"`
+	// Exporters breaks the span counts down per exporter; the totals above
+	// add up every exporter.
+	Exporters map[string]ExporterReport `json:"exporters,omitempty"`
+	Cache     map[string]int64          `json:"cache,omitempty"`
+}
+
+func (c *Collector) Report(service string) Report {
+	now := time.Now()
+	return Report{
+		Service:        service,
+		StartedAt:      c.startedAt.UTC(),
+		StoppedAt:      now.UTC(),
+		UptimeSeconds:  now.Sub(c.startedAt).Seconds(),
+		RequestsServed: c.requests.Load(),
+		ClientErrors:   c.clientErrors.Load(),
+		ServerErrors:   c.serverErrors.Load(),
+		SpansExported:  c.spansExported.Load(),
+		SpansDropped:   c.spansDropped.Load(),
+		Exporters:      c.exporterReports(),
+	}
+}
+
+type ExporterReport struct {
+	SpansExported int64 `json:"spans_exported"`
+	SpansDropped  int64 `json:"spans_dropped"`
+	SpansBuffered int64 `json:"spans_buffered,omitempty"`
+}
+
+func (c *Collector) exporterReports() map[string]ExporterReport {
+	reports := make(map[string]ExporterReport)
+	c.exporters.Range(func(name, counts any) bool {
+		reports[name.(string)] = ExporterReport{
+			SpansExported: counts.(*exporterCounts).exported.Load(),
+			SpansDropped:  counts.(*exporterCounts).dropped.Load(),
+			SpansBuffered: counts.(*exporterCounts).buffered.Load(),
+		}
+		return true
+	})
+	return reports
+}
+
+func (r Report) Log() {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("Failed to encode shutdown report: %v\n", err)
+		return
+	}
+	log.Printf("Shutdown report: %s\n", payload)
+}
+
+// Send posts the report as JSON to webhookURL.
+func (r Report) Send(ctx context.Context, client *http.Client, webhookURL string) error {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding shutdown report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting shutdown report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("shutdown report webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Middleware counts served requests and their 4xx/5xx outcomes.
+func (c *Collector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			c.requests.Add(1)
+			switch {
+			case rec.status >= 500:
+				c.serverErrors.Add(1)
+			case rec.status >= 400:
+				c.clientErrors.Add(1)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// WrapExporter counts spans the exporter delivered or failed to deliver,
+// under name in the report.
+func (c *Collector) WrapExporter(name string, exporter sdktrace.SpanExporter) sdktrace.SpanExporter {
+	counts, _ := c.exporters.LoadOrStore(name, &exporterCounts{})
+	return &countingExporter{SpanExporter: exporter, collector: c, counts: counts.(*exporterCounts)}
+}
+
+type countingExporter struct {
+	sdktrace.SpanExporter
+	collector *Collector
+	counts    *exporterCounts
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if errors.Is(err, ErrSpansBuffered) {
+		e.counts.buffered.Add(int64(len(spans)))
+		return nil
+	}
+	if err != nil {
+		e.collector.spansDropped.Add(int64(len(spans)))
+		e.counts.dropped.Add(int64(len(spans)))
+	} else {
+		e.collector.spansExported.Add(int64(len(spans)))
+		e.counts.exported.Add(int64(len(spans)))
+	}
+	return err
+}
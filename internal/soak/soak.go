@@ -0,0 +1,214 @@
+// Package soak samples heap and goroutine counts during long soak tests and
+// flags values that keep growing, which usually means a cache, pool or
+// worker is leaking.
+package soak
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type Sample struct {
+	At          time.Time `json:"at"`
+	HeapAlloc   uint64    `json:"heap_alloc_bytes"`
+	HeapInuse   uint64    `json:"heap_inuse_bytes"`
+	HeapObjects uint64    `json:"heap_objects"`
+	Goroutines  int       `json:"goroutines"`
+	NumGC       uint32    `json:"num_gc"`
+}
+
+// Status is published under the "soak" expvar and lists the series that grew
+// on every sample of the last window.
+type Status struct {
+	Service  string   `json:"service"`
+	Interval string   `json:"interval"`
+	Window   int      `json:"window"`
+	Latest   Sample   `json:"latest"`
+	Growing  []string `json:"growing"`
+	Warnings int64    `json:"warnings"`
+}
+
+type Monitor struct {
+	service  string
+	interval time.Duration
+	window   int
+
+	mu       sync.Mutex
+	samples  []Sample
+	growing  []string
+	warnings int64
+
+	leaks metric.Int64Counter
+}
+
+// FromEnv returns a monitor when SOAK_MODE is enabled, and nil otherwise.
+func FromEnv(service string) *Monitor {
+	if envconfig.String("SOAK_MODE", "false") != "true" {
+		return nil
+	}
+	window := envconfig.Int("SOAK_WINDOW", 10)
+	if window < 3 {
+		log.Printf("SOAK_WINDOW must be at least 3, using 3\n")
+		window = 3
+	}
+	return New(service, envconfig.Duration("SOAK_INTERVAL", time.Minute), window)
+}
+
+func New(service string, interval time.Duration, window int) *Monitor {
+	m := &Monitor{service: service, interval: interval, window: window}
+
+	meter := otel.Meter(service + "/soak")
+	var err error
+	if m.leaks, err = meter.Int64Counter("soak.growth.detected",
+		metric.WithDescription("Windows in which a runtime series grew on every sample"),
+	); err != nil {
+		log.Printf("Failed to create soak growth counter: %v\n", err)
+	}
+	heap, err := meter.Int64ObservableGauge("soak.heap.inuse",
+		metric.WithDescription("Bytes in in-use heap spans at the last soak sample"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		log.Printf("Failed to create soak heap gauge: %v\n", err)
+	}
+	goroutines, err := meter.Int64ObservableGauge("soak.goroutines",
+		metric.WithDescription("Goroutines at the last soak sample"),
+	)
+	if err != nil {
+		log.Printf("Failed to create soak goroutine gauge: %v\n", err)
+	}
+	if heap != nil && goroutines != nil {
+		if _, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			latest, ok := m.latest()
+			if ok {
+				o.ObserveInt64(heap, int64(latest.HeapInuse))
+				o.ObserveInt64(goroutines, int64(latest.Goroutines))
+			}
+			return nil
+		}, heap, goroutines); err != nil {
+			log.Printf("Failed to register soak gauges: %v\n", err)
+		}
+	}
+
+	expvar.Publish("soak", expvar.Func(func() any { return m.Status() }))
+	return m
+}
+
+// Start samples every interval until ctx is done. A nil monitor does nothing.
+func (m *Monitor) Start(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	log.Printf("Soak mode enabled for %s: sampling every %s, flagging growth over %d samples\n", m.service, m.interval, m.window)
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		m.sample(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.sample(ctx)
+			}
+		}
+	}()
+}
+
+func (m *Monitor) sample(ctx context.Context) {
+	// Collecting first measures what is retained rather than garbage not yet
+	// swept, which would otherwise grow between every GC cycle.
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	s := Sample{
+		At:          time.Now().UTC(),
+		HeapAlloc:   stats.HeapAlloc,
+		HeapInuse:   stats.HeapInuse,
+		HeapObjects: stats.HeapObjects,
+		Goroutines:  runtime.NumGoroutine(),
+		NumGC:       stats.NumGC,
+	}
+
+	m.mu.Lock()
+	m.samples = append(m.samples, s)
+	if len(m.samples) > m.window {
+		m.samples = m.samples[len(m.samples)-m.window:]
+	}
+	var growing []string
+	if len(m.samples) == m.window {
+		growing = m.growingSeries()
+	}
+	m.growing = growing
+	if len(growing) > 0 {
+		m.warnings++
+	}
+	m.mu.Unlock()
+
+	log.Printf("Soak sample: heap_alloc=%d heap_inuse=%d heap_objects=%d goroutines=%d gc=%d\n",
+		s.HeapAlloc, s.HeapInuse, s.HeapObjects, s.Goroutines, s.NumGC)
+	for _, series := range growing {
+		log.Printf("Soak warning: %s grew on each of the last %d samples (%s)\n", series, m.window, time.Duration(m.window-1)*m.interval)
+		if m.leaks != nil {
+			m.leaks.Add(ctx, 1, metric.WithAttributes(attribute.String("soak.series", series)))
+		}
+	}
+}
+
+// growingSeries must be called with m.mu held and a full window.
+func (m *Monitor) growingSeries() []string {
+	series := []struct {
+		name  string
+		value func(Sample) uint64
+	}{
+		{"heap_inuse", func(s Sample) uint64 { return s.HeapInuse }},
+		{"heap_objects", func(s Sample) uint64 { return s.HeapObjects }},
+		{"goroutines", func(s Sample) uint64 { return uint64(s.Goroutines) }},
+	}
+	var growing []string
+	for _, s := range series {
+		monotonic := true
+		for i := 1; i < len(m.samples); i++ {
+			if s.value(m.samples[i]) <= s.value(m.samples[i-1]) {
+				monotonic = false
+				break
+			}
+		}
+		if monotonic {
+			growing = append(growing, s.name)
+		}
+	}
+	return growing
+}
+
+func (m *Monitor) latest() (Sample, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.samples) == 0 {
+		return Sample{}, false
+	}
+	return m.samples[len(m.samples)-1], true
+}
+
+func (m *Monitor) Status() Status {
+	latest, _ := m.latest()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Status{
+		Service:  m.service,
+		Interval: m.interval.String(),
+		Window:   m.window,
+		Latest:   latest,
+		Growing:  append([]string{}, m.growing...),
+		Warnings: m.warnings,
+	}
+}
@@ -0,0 +1,148 @@
+package tenants
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Usage is what a tenant consumed in a day: the requests let through and
+// those turned away for exceeding the quota.
+type Usage struct {
+	Used     int64 `json:"used"`
+	Rejected int64 `json:"rejected"`
+}
+
+// Store counts usage per tenant and day. Take lets a request through and
+// counts it as used when the tenant is under quota (zero meaning
+// unlimited), and counts it as rejected otherwise.
+type Store interface {
+	Take(ctx context.Context, tenant, day string, quota int64) (used int64, ok bool, err error)
+	Usage(ctx context.Context, day string) (map[string]Usage, error)
+	Close() error
+}
+
+// MemoryStore counts usage in process, so each replica enforces the quota
+// on its own share of the traffic. Only the current and previous days are
+// kept.
+type MemoryStore struct {
+	mu   sync.Mutex
+	days map[string]map[string]*Usage
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{days: make(map[string]map[string]*Usage)}
+}
+
+func (m *MemoryStore) Take(_ context.Context, tenant, day string, quota int64) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, ok := m.days[day]
+	if !ok {
+		for old := range m.days {
+			if old < day {
+				delete(m.days, old)
+			}
+		}
+		usage = make(map[string]*Usage)
+		m.days[day] = usage
+	}
+	u, ok := usage[tenant]
+	if !ok {
+		u = &Usage{}
+		usage[tenant] = u
+	}
+	if quota > 0 && u.Used >= quota {
+		u.Rejected++
+		return u.Used, false, nil
+	}
+	u.Used++
+	return u.Used, true, nil
+}
+
+func (m *MemoryStore) Usage(_ context.Context, day string) (map[string]Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := make(map[string]Usage, len(m.days[day]))
+	for tenant, u := range m.days[day] {
+		usage[tenant] = *u
+	}
+	return usage, nil
+}
+
+func (m *MemoryStore) Close() error { return nil }
+
+const rejectedSuffix = ":rejected"
+
+// redisRetention keeps a day's counters long enough to report on it the
+// next day.
+const redisRetention = 48 * time.Hour
+
+// takeScript checks and increments a tenant's counter in one step, so
+// replicas racing on the last request of a quota cannot both get it.
+var takeScript = redis.NewScript(`
+local used = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local quota = tonumber(ARGV[2])
+local ok = 1
+if quota > 0 and used >= quota then
+  redis.call('HINCRBY', KEYS[1], ARGV[1] .. ':rejected', 1)
+  ok = 0
+else
+  used = redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+end
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return {used, ok}
+`)
+
+// RedisStore shares the counters between replicas, in a hash per day.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the Redis at url, e.g. redis://localhost:6379/0.
+func NewRedisStore(url, prefix string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, tenant, day string, quota int64) (int64, bool, error) {
+	result, err := takeScript.Run(ctx, s.client, []string{s.prefix + day}, tenant, quota, int(redisRetention.Seconds())).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return result[0], result[1] == 1, nil
+}
+
+func (s *RedisStore) Usage(ctx context.Context, day string) (map[string]Usage, error) {
+	fields, err := s.client.HGetAll(ctx, s.prefix+day).Result()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]Usage)
+	for field, value := range fields {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if tenant, ok := strings.CutSuffix(field, rejectedSuffix); ok {
+			u := usage[tenant]
+			u.Rejected = n
+			usage[tenant] = u
+			continue
+		}
+		u := usage[field]
+		u.Used = n
+		usage[field] = u
+	}
+	return usage, nil
+}
+
+func (s *RedisStore) Close() error { return s.client.Close() }
@@ -0,0 +1,91 @@
+// Package tenants maps API keys to the tenants that own them, each with a
+// daily request quota, and counts their usage in a Store that can be shared
+// by every replica (Redis) or kept per process (memory).
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Tenant owns one or more API keys. A DailyQuota of zero means unlimited.
+type Tenant struct {
+	Name       string   `json:"name"`
+	APIKeys    []string `json:"api_keys"`
+	DailyQuota int64    `json:"daily_quota"`
+}
+
+// Registry resolves API keys to tenants.
+type Registry struct {
+	tenants []Tenant
+	byKey   map[string]Tenant
+}
+
+// Load reads a JSON array of tenants from path.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return NewRegistry(tenants)
+}
+
+// NewRegistry indexes tenants by API key. Names and keys must be unique.
+func NewRegistry(tenants []Tenant) (*Registry, error) {
+	r := &Registry{tenants: tenants, byKey: make(map[string]Tenant)}
+	names := make(map[string]bool)
+	for _, t := range tenants {
+		if t.Name == "" || names[t.Name] {
+			return nil, fmt.Errorf("tenant names must be unique and non-empty, got %q", t.Name)
+		}
+		names[t.Name] = true
+		if t.DailyQuota < 0 {
+			return nil, fmt.Errorf("tenant %s has a negative daily quota", t.Name)
+		}
+		for _, key := range t.APIKeys {
+			if _, dup := r.byKey[key]; dup || key == "" {
+				return nil, fmt.Errorf("tenant %s has an empty or duplicated API key", t.Name)
+			}
+			r.byKey[key] = t
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the tenant owning apiKey.
+func (r *Registry) Lookup(apiKey string) (Tenant, bool) {
+	t, ok := r.byKey[apiKey]
+	return t, ok
+}
+
+// Tenants lists every tenant in the order they were configured.
+func (r *Registry) Tenants() []Tenant { return r.tenants }
+
+// Day names the quota period t falls in. Quotas reset at midnight UTC.
+func Day(t time.Time) string { return t.UTC().Format(time.DateOnly) }
+
+// UntilReset is how long after t the quota period ends.
+func UntilReset(t time.Time) time.Duration {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC).Sub(t)
+}
+
+type contextKey struct{}
+
+// ContextWithTenant records the tenant a request was authenticated as.
+func ContextWithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant of the request, if it was authenticated.
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(Tenant)
+	return t, ok
+}
@@ -0,0 +1,117 @@
+// Package tlsconfig builds the TLS configuration the services serve HTTPS
+// with, either from a certificate and key on disk, reloaded when the files
+// change, or from Let's Encrypt through autocert.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// FromEnv returns the TLS configuration described by TLS_CERT_FILE and
+// TLS_KEY_FILE, or by TLS_AUTOCERT_DOMAINS. It returns nil when neither is
+// set, meaning plain HTTP.
+func FromEnv() (*tls.Config, error) {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	domains := os.Getenv("TLS_AUTOCERT_DOMAINS")
+
+	switch {
+	case certFile != "" || keyFile != "":
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		if domains != "" {
+			return nil, fmt.Errorf("TLS_AUTOCERT_DOMAINS cannot be combined with TLS_CERT_FILE")
+		}
+		reloader, err := NewReloader(certFile, keyFile, envconfig.Duration("TLS_RELOAD_INTERVAL", 5*time.Second))
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: reloader.GetCertificate}, nil
+	case domains != "":
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(domains, ",")...),
+			Cache:      autocert.DirCache(envconfig.String("TLS_AUTOCERT_CACHE_DIR", "autocert-cache")),
+			Email:      os.Getenv("TLS_AUTOCERT_EMAIL"),
+		}
+		cfg := m.TLSConfig()
+		cfg.MinVersion = tls.VersionTLS12
+		return cfg, nil
+	}
+	return nil, nil
+}
+
+// Reloader serves a certificate from disk and loads it again once either
+// file's modification time changes. A certificate that fails to load is
+// logged and the previous one keeps being served.
+type Reloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewReloader loads the certificate once and then checks the files at most
+// every interval, on the next handshake.
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("error reading TLS certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := time.Now(); now.Sub(r.lastCheck) >= r.interval {
+		r.lastCheck = now
+		if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				log.Printf("Failed to reload TLS certificate, keeping the current one: %v\n", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from %s\n", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
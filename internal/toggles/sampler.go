@@ -0,0 +1,26 @@
+package toggles
+
+import (
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Sampler samples root spans at the store's current sampling ratio, so the
+// ratio can be changed at runtime. Wrap it in sdktrace.ParentBased to keep
+// traces whole across services.
+func (s *Store) Sampler() sdktrace.Sampler {
+	return ratioSampler{store: s}
+}
+
+type ratioSampler struct {
+	store *Store
+}
+
+func (r ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.TraceIDRatioBased(r.store.Get().SamplingRatio).ShouldSample(p)
+}
+
+func (r ratioSampler) Description() string {
+	return fmt.Sprintf("ToggleRatioBased{%g}", r.store.Get().SamplingRatio)
+}
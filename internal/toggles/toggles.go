@@ -0,0 +1,143 @@
+// Package toggles holds runtime feature switches that can be changed through
+// the admin API without restarting the service.
+package toggles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Toggles struct {
+	CacheEnabled  bool    `json:"cache_enabled"`
+	CEPProvider   string  `json:"cep_provider"`
+	SamplingRatio float64 `json:"sampling_ratio"`
+	MockMode      bool    `json:"mock_mode"`
+}
+
+// Patch is a partial update; nil fields are left unchanged.
+type Patch struct {
+	CacheEnabled  *bool    `json:"cache_enabled"`
+	CEPProvider   *string  `json:"cep_provider"`
+	SamplingRatio *float64 `json:"sampling_ratio"`
+	MockMode      *bool    `json:"mock_mode"`
+}
+
+func (t Toggles) Attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Bool("feature.cache_enabled", t.CacheEnabled),
+		attribute.String("feature.cep_provider", t.CEPProvider),
+		attribute.Float64("feature.sampling_ratio", t.SamplingRatio),
+		attribute.Bool("feature.mock_mode", t.MockMode),
+	}
+}
+
+// Store keeps the current toggles and, when path is set, persists them as
+// JSON so they survive restarts.
+type Store struct {
+	path     string
+	validate func(Toggles) error
+
+	mu      sync.RWMutex
+	current Toggles
+}
+
+// Open returns a store seeded with defaults, overridden by the toggles saved
+// at path if that file exists. validate, when non-nil, rejects updates and
+// saved toggles alike.
+func Open(path string, defaults Toggles, validate func(Toggles) error) (*Store, error) {
+	s := &Store{path: path, validate: validate, current: defaults}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading toggles: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.current); err != nil {
+		return nil, fmt.Errorf("error decoding toggles: %w", err)
+	}
+	if err := s.check(s.current); err != nil {
+		return nil, fmt.Errorf("invalid toggles in %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *Store) Get() Toggles {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+func (s *Store) Apply(p Patch) (Toggles, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.current
+	if p.CacheEnabled != nil {
+		next.CacheEnabled = *p.CacheEnabled
+	}
+	if p.CEPProvider != nil {
+		next.CEPProvider = *p.CEPProvider
+	}
+	if p.SamplingRatio != nil {
+		next.SamplingRatio = *p.SamplingRatio
+	}
+	if p.MockMode != nil {
+		next.MockMode = *p.MockMode
+	}
+
+	if err := s.check(next); err != nil {
+		return s.current, err
+	}
+	if err := s.save(next); err != nil {
+		return s.current, err
+	}
+	s.current = next
+	return next, nil
+}
+
+func (s *Store) check(t Toggles) error {
+	if t.SamplingRatio < 0 || t.SamplingRatio > 1 {
+		return fmt.Errorf("sampling_ratio must be between 0 and 1")
+	}
+	if s.validate != nil {
+		return s.validate(t)
+	}
+	return nil
+}
+
+func (s *Store) save(t Toggles) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding toggles: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".toggles-*")
+	if err != nil {
+		return fmt.Errorf("error saving toggles: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving toggles: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving toggles: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error saving toggles: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AdaptiveSampling configures the tail sampling done by AdaptiveProcessor.
+type AdaptiveSampling struct {
+	// SuccessRatio is the fraction of fast, successful traces kept.
+	SuccessRatio float64
+	// Window is how many recent durations per root span name the p95 is
+	// computed over.
+	Window int
+	// MaxPendingSpans bounds the spans held while their trace is undecided.
+	MaxPendingSpans int
+}
+
+// minLatencySamples is how many durations a root span name needs before
+// its p95 is trusted; until then every trace is kept.
+const minLatencySamples = 20
+
+// decidedTraces is how many past decisions are remembered for spans that
+// end after their local root, such as background callbacks.
+const decidedTraces = 4096
+
+// adaptiveSamplingFromEnv reads TRACE_SUCCESS_SAMPLE_RATIO,
+// TRACE_LATENCY_WINDOW and TRACE_SAMPLING_MAX_PENDING_SPANS. ok is false when
+// every trace is kept anyway.
+func adaptiveSamplingFromEnv() (cfg AdaptiveSampling, ok bool) {
+	ratio := 1.0
+	if value := envconfig.String("TRACE_SUCCESS_SAMPLE_RATIO", ""); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Printf("Ignoring TRACE_SUCCESS_SAMPLE_RATIO %q: want a number between 0 and 1\n", value)
+		} else {
+			ratio = parsed
+		}
+	}
+	return AdaptiveSampling{
+		SuccessRatio:    ratio,
+		Window:          max(envconfig.Int("TRACE_LATENCY_WINDOW", 1000), minLatencySamples),
+		MaxPendingSpans: envconfig.Int("TRACE_SAMPLING_MAX_PENDING_SPANS", 10000),
+	}, ratio < 1
+}
+
+// AdaptiveProcessor holds ended spans until the local root span of their
+// trace ends, then hands the whole trace to next only when it failed, was
+// slower than the p95 of recent traces with the same root span name, or
+// falls in SuccessRatio. The ratio is applied to the trace ID, so services
+// sharing it keep the same fast traces.
+type AdaptiveProcessor struct {
+	next  []sdktrace.SpanProcessor
+	cfg   AdaptiveSampling
+	ratio sdktrace.Sampler
+
+	mu           sync.Mutex
+	pending      map[trace.TraceID][]sdktrace.ReadOnlySpan
+	pendingOrder []trace.TraceID
+	pendingSpans int
+	decided      map[trace.TraceID]bool
+	decidedOrder []trace.TraceID
+	latencies    map[string]*latencyWindow
+
+	decisions metric.Int64Counter
+}
+
+func NewAdaptiveProcessor(cfg AdaptiveSampling, next ...sdktrace.SpanProcessor) *AdaptiveProcessor {
+	p := &AdaptiveProcessor{
+		next:      next,
+		cfg:       cfg,
+		ratio:     sdktrace.TraceIDRatioBased(cfg.SuccessRatio),
+		pending:   make(map[trace.TraceID][]sdktrace.ReadOnlySpan),
+		decided:   make(map[trace.TraceID]bool),
+		latencies: make(map[string]*latencyWindow),
+	}
+	var err error
+	if p.decisions, err = otel.Meter("tracing").Int64Counter("trace.sampling.decisions",
+		metric.WithDescription("Traces kept or dropped by adaptive sampling, by reason"),
+	); err != nil {
+		log.Printf("Failed to create sampling decisions counter: %v\n", err)
+	}
+	return p
+}
+
+func (p *AdaptiveProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	for _, next := range p.next {
+		next.OnStart(ctx, span)
+	}
+}
+
+func (p *AdaptiveProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	traceID := span.SpanContext().TraceID()
+	parent := span.Parent()
+
+	p.mu.Lock()
+	if keep, ok := p.decided[traceID]; ok {
+		p.mu.Unlock()
+		if keep || span.Status().Code == codes.Error {
+			p.forward(span)
+		}
+		return
+	}
+	if _, ok := p.pending[traceID]; !ok {
+		p.pendingOrder = append(p.pendingOrder, traceID)
+	}
+	p.pending[traceID] = append(p.pending[traceID], span)
+	p.pendingSpans++
+
+	var keep []sdktrace.ReadOnlySpan
+	if !parent.IsValid() || parent.IsRemote() {
+		spans := p.take(traceID)
+		reason := p.decide(span, spans)
+		kept := reason != "fast"
+		p.remember(traceID, kept)
+		if p.decisions != nil {
+			p.decisions.Add(context.Background(), 1, metric.WithAttributes(
+				attribute.String("sampling.reason", reason), attribute.Bool("sampling.kept", kept)))
+		}
+		if kept {
+			keep = spans
+		}
+	}
+	// Keep the oldest undecided traces rather than buffer without bound.
+	for p.pendingSpans > p.cfg.MaxPendingSpans && len(p.pendingOrder) > 0 {
+		oldest := p.pendingOrder[0]
+		keep = append(keep, p.take(oldest)...)
+		p.remember(oldest, true)
+	}
+	p.mu.Unlock()
+
+	for _, s := range keep {
+		p.forward(s)
+	}
+}
+
+// decide returns why the trace of root is kept ("error", "slow", "warmup" or
+// "sampled"), or "fast" when it is dropped.
+func (p *AdaptiveProcessor) decide(root sdktrace.ReadOnlySpan, spans []sdktrace.ReadOnlySpan) string {
+	window := p.latencies[root.Name()]
+	if window == nil {
+		window = &latencyWindow{size: p.cfg.Window}
+		p.latencies[root.Name()] = window
+	}
+	duration := root.EndTime().Sub(root.StartTime())
+	p95, warm := window.p95()
+	window.add(duration)
+
+	for _, span := range spans {
+		if span.Status().Code == codes.Error {
+			return "error"
+		}
+	}
+	for _, kv := range root.Attributes() {
+		if kv.Key == semconv.HTTPResponseStatusCodeKey && kv.Value.AsInt64() >= 500 {
+			return "error"
+		}
+	}
+	switch {
+	case !warm:
+		return "warmup"
+	case duration > p95:
+		return "slow"
+	case p.ratio.ShouldSample(sdktrace.SamplingParameters{TraceID: root.SpanContext().TraceID()}).Decision == sdktrace.RecordAndSample:
+		return "sampled"
+	}
+	return "fast"
+}
+
+// take removes the pending spans of traceID. p.mu must be held.
+func (p *AdaptiveProcessor) take(traceID trace.TraceID) []sdktrace.ReadOnlySpan {
+	spans := p.pending[traceID]
+	delete(p.pending, traceID)
+	p.pendingSpans -= len(spans)
+	if i := slices.Index(p.pendingOrder, traceID); i >= 0 {
+		p.pendingOrder = slices.Delete(p.pendingOrder, i, i+1)
+	}
+	return spans
+}
+
+// remember records the decision for traceID, forgetting the oldest past
+// decisions. p.mu must be held.
+func (p *AdaptiveProcessor) remember(traceID trace.TraceID, keep bool) {
+	if _, ok := p.decided[traceID]; !ok {
+		p.decidedOrder = append(p.decidedOrder, traceID)
+	}
+	p.decided[traceID] = keep
+	if len(p.decidedOrder) > decidedTraces {
+		delete(p.decided, p.decidedOrder[0])
+		p.decidedOrder = p.decidedOrder[1:]
+	}
+}
+
+func (p *AdaptiveProcessor) forward(span sdktrace.ReadOnlySpan) {
+	for _, next := range p.next {
+		next.OnEnd(span)
+	}
+}
+
+// Shutdown keeps the traces still undecided and shuts next down.
+func (p *AdaptiveProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	var undecided []sdktrace.ReadOnlySpan
+	for _, traceID := range p.pendingOrder {
+		undecided = append(undecided, p.pending[traceID]...)
+	}
+	p.pending = make(map[trace.TraceID][]sdktrace.ReadOnlySpan)
+	p.pendingOrder, p.pendingSpans = nil, 0
+	p.mu.Unlock()
+
+	for _, span := range undecided {
+		p.forward(span)
+	}
+	var errs []error
+	for _, next := range p.next {
+		errs = append(errs, next.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *AdaptiveProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, next := range p.next {
+		errs = append(errs, next.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// latencyWindow keeps the last size durations of a root span name.
+type latencyWindow struct {
+	size      int
+	durations []time.Duration
+	next      int
+	// cached is the p95 computed after stale additions ago.
+	cached time.Duration
+	stale  int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	if len(w.durations) < w.size {
+		w.durations = append(w.durations, d)
+	} else {
+		w.durations[w.next] = d
+		w.next = (w.next + 1) % w.size
+	}
+	w.stale++
+}
+
+// p95 is recomputed every twentieth of the window, which is precise enough
+// for a sampling threshold.
+func (w *latencyWindow) p95() (time.Duration, bool) {
+	if len(w.durations) < minLatencySamples {
+		return 0, false
+	}
+	if w.cached == 0 || w.stale >= max(w.size/20, 1) {
+		sorted := slices.Clone(w.durations)
+		slices.Sort(sorted)
+		w.cached = sorted[len(sorted)*95/100]
+		w.stale = 0
+	}
+	return w.cached, true
+}
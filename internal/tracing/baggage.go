@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BaggageSpanProcessor copies the listed baggage members of a span's parent
+// context onto the span as attributes when it starts, so every span of a
+// request can be filtered by caller. Members outside keys are ignored,
+// since baggage arrives from callers and must not add arbitrary attributes.
+type BaggageSpanProcessor struct {
+	keys []string
+}
+
+func NewBaggageSpanProcessor(keys []string) *BaggageSpanProcessor {
+	return &BaggageSpanProcessor{keys: keys}
+}
+
+func (p *BaggageSpanProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	bag := baggage.FromContext(ctx)
+	for _, key := range p.keys {
+		if member := bag.Member(key); member.Key() != "" {
+			span.SetAttributes(attribute.String(key, member.Value()))
+		}
+	}
+}
+
+func (p *BaggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (p *BaggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (p *BaggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// initLogs installs the global logger provider when LOG_EXPORTERS names an
+// exporter, sharing the tracer provider's resource, and makes slog's default
+// logger write every record both to stderr and to the provider. The log
+// package goes through slog from then on, so existing log.Printf calls are
+// exported too. Without exporters it changes nothing and returns a nil
+// shutdown function.
+func initLogs(serviceName string, res *resource.Resource, ceps *privacy.Hasher) (func(context.Context) error, error) {
+	var opts []sdklog.LoggerProviderOption
+	for _, name := range splitList(envconfig.String("LOG_EXPORTERS", "")) {
+		switch name {
+		case "otlp":
+			// Configured through the standard OTEL_EXPORTER_OTLP_* variables.
+			exporter, err := otlploghttp.New(context.Background())
+			if err != nil {
+				return nil, fmt.Errorf("failed to create log exporter %s: %w", name, err)
+			}
+			opts = append(opts, sdklog.WithProcessor(&redactingLogProcessor{next: sdklog.NewBatchProcessor(exporter), ceps: ceps}))
+		default:
+			return nil, fmt.Errorf("unknown log exporter %q (available: otlp)", name)
+		}
+	}
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	lp := sdklog.NewLoggerProvider(append(opts, sdklog.WithResource(res))...)
+	global.SetLoggerProvider(lp)
+
+	// log.Writer is stderr, already scrubbed in privacy mode.
+	stderr := &lineHandler{logger: log.New(log.Writer(), log.Prefix(), log.Flags())}
+	bridge := otelslog.NewHandler(serviceName, otelslog.WithLoggerProvider(lp))
+	slog.SetDefault(slog.New(teeHandler{stderr, bridge}))
+	requestid.LogThroughSlog()
+	return lp.Shutdown, nil
+}
+
+// lineHandler writes records the way the log package does, the message
+// followed by any attributes as key=value, so stderr reads the same with the
+// bridge installed. Levels other than info prefix the message.
+type lineHandler struct {
+	logger *log.Logger
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h *lineHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h *lineHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if r.Level != slog.LevelInfo {
+		b.WriteString(r.Level.String() + " ")
+	}
+	b.WriteString(r.Message)
+	for _, attr := range h.attrs {
+		fmt.Fprintf(&b, " %s=%v", attr.Key, attr.Value)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		fmt.Fprintf(&b, " %s%s=%v", h.group, attr.Key, attr.Value)
+		return true
+	})
+	return h.logger.Output(0, b.String())
+}
+
+func (h *lineHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		next.attrs = append(next.attrs, slog.Attr{Key: h.group + attr.Key, Value: attr.Value})
+	}
+	return &next
+}
+
+func (h *lineHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.group = h.group + name + "."
+	return &next
+}
+
+// teeHandler hands every record to all of its handlers.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithGroup(name)
+	}
+	return next
+}
+
+// redactingLogProcessor masks query string credentials and, in privacy mode,
+// CEPs in the body and string attributes of log records before next exports
+// them, as RedactingProcessor does for spans.
+type redactingLogProcessor struct {
+	next sdklog.Processor
+	ceps *privacy.Hasher
+}
+
+func (p *redactingLogProcessor) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	if body := record.Body(); body.Kind() == otellog.KindString {
+		record.SetBody(otellog.StringValue(p.scrub(body.AsString())))
+	}
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen())
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		if kv.Value.Kind() == otellog.KindString {
+			kv.Value = otellog.StringValue(p.scrub(kv.Value.AsString()))
+		}
+		attrs = append(attrs, kv)
+		return true
+	})
+	record.SetAttributes(attrs...)
+	return p.next.OnEmit(ctx, record)
+}
+
+func (p *redactingLogProcessor) scrub(s string) string {
+	s = secretParams.ReplaceAllString(s, "${1}"+redacted)
+	if p.ceps != nil {
+		s = p.ceps.Scrub(s)
+	}
+	return s
+}
+
+func (p *redactingLogProcessor) Shutdown(ctx context.Context) error   { return p.next.Shutdown(ctx) }
+func (p *redactingLogProcessor) ForceFlush(ctx context.Context) error { return p.next.ForceFlush(ctx) }
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Recover turns handler panics into 500 responses and records them on the
+// active span, counting them by route on the http.server.panics counter of
+// meterName. It wraps every other middleware, directly inside the otelhttp
+// handler, so their panics are recovered as well.
+func Recover(meterName string, next http.Handler) http.Handler {
+	panics, err := otel.Meter(meterName).Int64Counter("http.server.panics",
+		metric.WithDescription("Number of panics recovered while serving requests"),
+	)
+	if err != nil {
+		log.Printf("Failed to create panic counter: %v\n", err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			stack := string(debug.Stack())
+			span := trace.SpanFromContext(r.Context())
+			span.RecordError(fmt.Errorf("panic: %v", rec), trace.WithAttributes(
+				attribute.String("exception.stacktrace", stack),
+			))
+			span.SetStatus(codes.Error, "panic recovered")
+			if panics != nil {
+				panics.Add(r.Context(), 1, metric.WithAttributes(attribute.String("http.route", r.Pattern)))
+			}
+
+			requestid.Logf(r.Context(), "Recovered panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rec, stack)
+			i18n.Error(w, r, http.StatusInternalServerError, "Internal Server Error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
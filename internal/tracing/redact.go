@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// secretParams matches query parameters that carry credentials, such as the
+// WeatherAPI key in url.full and in error messages quoting the request URL.
+var secretParams = regexp.MustCompile(`(?i)([?&](?:key|api_key|apikey|access_token|token|password|secret|signature)=)[^&\s"'#]*`)
+
+const redacted = "REDACTED"
+
+// cepAttributes hold nothing but a CEP, so privacy mode hashes them whole.
+var cepAttributes = map[attribute.Key]bool{"cep": true, "cep.input": true}
+
+// Redaction lists the attributes dropped or hashed before export. Query
+// string credentials are masked in every string attribute regardless.
+type Redaction struct {
+	Drop []string
+	Hash []string
+	// CEPs, when set, replaces every CEP in attributes, events and status
+	// descriptions, including those inside URLs and error messages.
+	CEPs *privacy.Hasher
+}
+
+// redactionFromEnv reads TRACE_REDACT_ATTRIBUTES, TRACE_HASH_ATTRIBUTES and
+// PRIVACY_MODE. Client addresses are hashed by default.
+func redactionFromEnv() (Redaction, error) {
+	hasher, err := privacy.FromEnv()
+	if err != nil {
+		return Redaction{}, err
+	}
+	return Redaction{
+		Drop: splitList(envconfig.String("TRACE_REDACT_ATTRIBUTES", "")),
+		Hash: splitList(envconfig.String("TRACE_HASH_ATTRIBUTES", "client.address,network.peer.address,client.id,enduser.id")),
+		CEPs: hasher,
+	}, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// RedactingProcessor hands ended spans to next with credentials masked and
+// the configured attributes dropped or replaced by a hash, so they never
+// reach an exporter or the fallback file.
+type RedactingProcessor struct {
+	next sdktrace.SpanProcessor
+	drop map[attribute.Key]bool
+	hash map[attribute.Key]bool
+	ceps *privacy.Hasher
+}
+
+func NewRedactingProcessor(next sdktrace.SpanProcessor, r Redaction) *RedactingProcessor {
+	p := &RedactingProcessor{next: next, drop: make(map[attribute.Key]bool), hash: make(map[attribute.Key]bool), ceps: r.CEPs}
+	for _, key := range r.Drop {
+		p.drop[attribute.Key(key)] = true
+	}
+	for _, key := range r.Hash {
+		p.hash[attribute.Key(key)] = true
+	}
+	return p
+}
+
+func (p *RedactingProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, span)
+}
+
+func (p *RedactingProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	events := make([]sdktrace.Event, len(span.Events()))
+	for i, event := range span.Events() {
+		event.Attributes = p.redact(event.Attributes)
+		events[i] = event
+	}
+	status := span.Status()
+	status.Description = secretParams.ReplaceAllString(status.Description, "${1}"+redacted)
+	if p.ceps != nil {
+		status.Description = p.ceps.Scrub(status.Description)
+	}
+	p.next.OnEnd(&redactedSpan{ReadOnlySpan: span, attributes: p.redact(span.Attributes()), events: events, status: status})
+}
+
+func (p *RedactingProcessor) Shutdown(ctx context.Context) error   { return p.next.Shutdown(ctx) }
+func (p *RedactingProcessor) ForceFlush(ctx context.Context) error { return p.next.ForceFlush(ctx) }
+
+func (p *RedactingProcessor) redact(attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		switch {
+		case p.drop[kv.Key]:
+			continue
+		case p.hash[kv.Key]:
+			sum := sha256.Sum256([]byte(kv.Value.Emit()))
+			kv.Value = attribute.StringValue("sha256:" + hex.EncodeToString(sum[:8]))
+		case p.ceps != nil && cepAttributes[kv.Key]:
+			kv.Value = attribute.StringValue(p.ceps.Hash(kv.Value.Emit()))
+		case kv.Value.Type() == attribute.STRING:
+			value := secretParams.ReplaceAllString(kv.Value.AsString(), "${1}"+redacted)
+			if p.ceps != nil {
+				value = p.ceps.Scrub(value)
+			}
+			kv.Value = attribute.StringValue(value)
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+	status     sdktrace.Status
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue { return s.attributes }
+func (s *redactedSpan) Events() []sdktrace.Event         { return s.events }
+func (s *redactedSpan) Status() sdktrace.Status          { return s.status }
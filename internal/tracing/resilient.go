@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// resilientExporter retries failed exports with exponential backoff and,
+// when every attempt fails, hands the batch to the fallback file exporter and
+// returns shutdownreport.ErrSpansBuffered. Batches that cannot be delivered
+// anywhere are logged and counted.
+type resilientExporter struct {
+	sdktrace.SpanExporter
+	name     string
+	retries  int
+	backoff  time.Duration
+	fallback *fileExporter
+
+	healthy atomic.Bool
+	dropped metric.Int64Counter
+}
+
+func newResilientExporter(name string, exporter sdktrace.SpanExporter, retries int, backoff time.Duration, fallback *fileExporter) *resilientExporter {
+	e := &resilientExporter{SpanExporter: exporter, name: name, retries: retries, backoff: backoff, fallback: fallback}
+	e.healthy.Store(true)
+	var err error
+	if e.dropped, err = otel.Meter("tracing").Int64Counter("trace.spans.dropped",
+		metric.WithDescription("Spans that no exporter nor the fallback file accepted"),
+	); err != nil {
+		log.Printf("Failed to create dropped spans counter: %v\n", err)
+	}
+	return e
+}
+
+func (e *resilientExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	backoff := e.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = e.SpanExporter.ExportSpans(ctx, spans); err == nil {
+			if !e.healthy.Swap(true) {
+				log.Printf("Trace exporter %s recovered\n", e.name)
+			}
+			return nil
+		}
+		if attempt >= e.retries {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			err = errors.Join(err, ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if e.healthy.Swap(false) {
+		log.Printf("Trace exporter %s is failing: %v\n", e.name, err)
+	}
+
+	if e.fallback != nil {
+		fbErr := e.fallback.ExportSpans(context.WithoutCancel(ctx), spans)
+		if fbErr == nil {
+			return shutdownreport.ErrSpansBuffered
+		}
+		err = errors.Join(err, fbErr)
+	}
+	log.Printf("Dropped %d spans for trace exporter %s: %v\n", len(spans), e.name, err)
+	if e.dropped != nil {
+		e.dropped.Add(ctx, int64(len(spans)), metric.WithAttributes(attribute.String("exporter", e.name)))
+	}
+	return err
+}
+
+// fileExporter writes spans as JSON lines to a local file, up to maxBytes,
+// so they survive a backend outage. It is shared by every exporter.
+type fileExporter struct {
+	file *os.File
+	sdktrace.SpanExporter
+}
+
+func newFileExporter(path string, maxBytes int64) (*fileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(&limitedWriter{w: file, written: info.Size(), max: maxBytes}))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileExporter{file: file, SpanExporter: exporter}, nil
+}
+
+func (f *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(f.SpanExporter.Shutdown(ctx), f.file.Close())
+}
+
+var errFallbackFull = errors.New("trace fallback file is full")
+
+type limitedWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	written int64
+	max     int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.written+int64(len(p)) > l.max {
+		return 0, errFallbackFull
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+// checkReachable dials the host of endpoint so an unreachable backend is
+// reported at startup instead of surfacing only as failed exports.
+func checkReachable(name, endpoint string, fallback *fileExporter) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, 2*time.Second)
+	if err != nil {
+		hint := ""
+		if fallback != nil {
+			hint = fmt.Sprintf(" and written to %s if delivery keeps failing", fallback.file.Name())
+		}
+		log.Printf("Trace exporter %s: %s is not reachable yet (%v); spans will be retried%s\n", name, host, err, hint)
+		return
+	}
+	conn.Close()
+}
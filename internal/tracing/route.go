@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// route returns the method and path template of the ServeMux pattern that
+// matched r, e.g. "GET" and "/weather/{cep}".
+func route(r *http.Request) (method, path string) {
+	if r.Pattern == "" {
+		return "", ""
+	}
+	method, path, found := strings.Cut(r.Pattern, " ")
+	if !found {
+		method, path = r.Method, r.Pattern
+	}
+	// Drop the host of host-specific patterns.
+	if i := strings.Index(path, "/"); i > 0 {
+		path = path[i:]
+	}
+	// "/{$}" only anchors the match; the route is "/".
+	return method, strings.TrimSuffix(path, "{$}")
+}
+
+// RouteSpanName is an otelhttp span name formatter that names server spans
+// after the matched route template, e.g. "GET /weather/{cep}", falling back
+// to operation outside a ServeMux.
+func RouteSpanName(operation string, r *http.Request) string {
+	method, path := route(r)
+	if path == "" {
+		return operation
+	}
+	return method + " " + path
+}
+
+// WithRoute sets http.route on the server span and on the otelhttp metrics,
+// so neither carries raw request paths. It must run inside otelhttp.NewHandler.
+func WithRoute(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, path := route(r); path != "" {
+			attr := semconv.HTTPRoute(path)
+			trace.SpanFromContext(r.Context()).SetAttributes(attr)
+			if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+				labeler.Add(attr)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,146 @@
+// Package tracing installs the global tracer provider that exports spans to
+// Zipkin and, optionally, to an OTLP backend at the same time, and the
+// logger provider that exports logs alongside them.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// newExporter creates the exporter called name. OTLP is configured through
+// the standard OTEL_EXPORTER_OTLP_* variables.
+func newExporter(name, zipkinEndpoint string) (sdktrace.SpanExporter, string, error) {
+	switch name {
+	case "zipkin":
+		exporter, err := zipkin.New(zipkinEndpoint)
+		return exporter, zipkinEndpoint, err
+	case "otlp":
+		exporter, err := otlptracehttp.New(context.Background())
+		endpoint := envconfig.String("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", envconfig.String("OTEL_EXPORTER_OTLP_ENDPOINT", "https://localhost:4318"))
+		return exporter, endpoint, err
+	default:
+		return nil, "", fmt.Errorf("unknown trace exporter %q (available: zipkin, otlp)", name)
+	}
+}
+
+// Init sets the global tracer provider and propagator and returns the
+// provider's shutdown function. TRACE_EXPORTERS lists the exporters; each
+// gets its own batch processor, so a slow or unreachable backend only drops
+// its own spans. Failed exports are retried and then written to
+// TRACE_FALLBACK_FILE when set. Exported and dropped spans are counted on
+// stats per exporter. Credentials in query strings and the attributes in
+// TRACE_REDACT_ATTRIBUTES and TRACE_HASH_ATTRIBUTES are scrubbed before any
+// exporter sees a span, and with TRACE_SUCCESS_SAMPLE_RATIO below 1 only a
+// fraction of fast, successful traces is exported. processors run before
+// the exporting processors. With LOG_EXPORTERS set, logs are exported too,
+// under the same resource.
+func Init(serviceName, zipkinEndpoint string, sampler sdktrace.Sampler, stats *shutdownreport.Collector, processors ...sdktrace.SpanProcessor) (func(context.Context) error, error) {
+	var fallback *fileExporter
+	if path := os.Getenv("TRACE_FALLBACK_FILE"); path != "" {
+		var err error
+		if fallback, err = newFileExporter(path, int64(envconfig.Int("TRACE_FALLBACK_MAX_BYTES", 100<<20))); err != nil {
+			return nil, fmt.Errorf("failed to open trace fallback file: %w", err)
+		}
+	}
+	retries := envconfig.Int("TRACE_EXPORT_RETRIES", 3)
+	backoff := envconfig.Duration("TRACE_EXPORT_BACKOFF", time.Second)
+
+	redaction, err := redactionFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var exporting []sdktrace.SpanProcessor
+	var destinations []string
+	for _, name := range strings.Split(envconfig.String("TRACE_EXPORTERS", "zipkin"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		exporter, destination, err := newExporter(name, zipkinEndpoint)
+		if err != nil {
+			log.Printf("Skipping trace exporter %s: %v\n", name, err)
+			continue
+		}
+		checkReachable(name, destination, fallback)
+		resilient := newResilientExporter(name, exporter, retries, backoff, fallback)
+		exporting = append(exporting, NewRedactingProcessor(sdktrace.NewBatchSpanProcessor(stats.WrapExporter(name, resilient)), redaction))
+		destinations = append(destinations, destination)
+	}
+	if len(exporting) == 0 {
+		return nil, fmt.Errorf("no usable trace exporter in TRACE_EXPORTERS")
+	}
+	if adaptive, ok := adaptiveSamplingFromEnv(); ok {
+		exporting = []sdktrace.SpanProcessor{NewAdaptiveProcessor(adaptive, exporting...)}
+		log.Printf("Adaptive sampling: keeping failed traces, traces slower than p95 and %g of the rest\n", adaptive.SuccessRatio)
+	}
+
+	build := version.Get(serviceName)
+	host, err := resource.New(context.Background(), resource.WithHost())
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect host resource: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), host)
+	if err == nil {
+		res, err = resource.Merge(res, resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersion(build.Version),
+			semconv.DeploymentEnvironment(build.Environment),
+		))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithSampler(sampler), sdktrace.WithResource(res)}
+	processors = append([]sdktrace.SpanProcessor{requestid.SpanProcessor{}}, processors...)
+	for _, processor := range append(processors, exporting...) {
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(tp)
+
+	logShutdown, err := initLogs(serviceName, res, redaction.CEPs)
+	if err != nil {
+		return nil, errors.Join(err, tp.Shutdown(context.Background()))
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	log.Printf("Tracer initialized for service 	'%s'	 (version %s, environment %s), exporting to %s\n", serviceName, build.Version, build.Environment, strings.Join(destinations, ", "))
+
+	shutdowns := []func(context.Context) error{tp.Shutdown}
+	if logShutdown != nil {
+		shutdowns = append(shutdowns, logShutdown)
+	}
+	if fallback != nil {
+		shutdowns = append(shutdowns, fallback.Shutdown)
+	}
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, shutdown := range shutdowns {
+			errs = append(errs, shutdown(ctx))
+		}
+		return errors.Join(errs...)
+	}, nil
+}
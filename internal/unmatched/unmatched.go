@@ -0,0 +1,77 @@
+// Package unmatched answers the requests no route of a ServeMux serves with
+// a JSON error envelope instead of the mux's plain text: 404 for unknown
+// paths, and 405 with Allow for paths routed only under other methods.
+package unmatched
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/i18n"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// methods are the methods probed to build the Allow header.
+var methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// Response has the shape of Service A's error envelope.
+type Response struct {
+	Error Detail `json:"error"`
+}
+
+type Detail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Register routes every request no other pattern of mux matches to the
+// fallback handler, wrapped by instrument like the service's own routes.
+// The "/" pattern is hidden from instrument, so spans and metrics of
+// unmatched requests carry no http.route.
+func Register(mux *http.ServeMux, instrument func(http.HandlerFunc) http.Handler) {
+	h := instrument(func(w http.ResponseWriter, r *http.Request) {
+		serve(mux, w, r)
+	})
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Pattern = ""
+		h.ServeHTTP(w, r)
+	}))
+}
+
+func serve(mux *http.ServeMux, w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, method := range methods {
+		probe := &http.Request{Method: method, URL: r.URL, Host: r.Host, Header: http.Header{}}
+		if _, pattern := mux.Handler(probe); pattern != "" && pattern != "/" {
+			allowed = append(allowed, method)
+		}
+	}
+
+	lang := i18n.Language(r)
+	status, detail := http.StatusNotFound, Detail{Code: "not_found", Message: i18n.T(lang, "Not Found")}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		status, detail = http.StatusMethodNotAllowed, Detail{Code: "method_not_allowed", Message: i18n.T(lang, "Method Not Allowed")}
+	}
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(attribute.String("request.rejected.reason", detail.Code))
+	span.SetStatus(codes.Error, detail.Message)
+
+	detail.RequestID = requestid.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(Response{Error: detail}); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
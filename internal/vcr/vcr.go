@@ -0,0 +1,168 @@
+// Package vcr records upstream HTTP interactions to fixture files
+// ("cassettes") and replays them, so the real providers can run without
+// network access or upstream quota.
+package vcr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+)
+
+type Mode string
+
+const (
+	Record Mode = "record"
+	Replay Mode = "replay"
+)
+
+// secretParams are left out of cassette names and contents, so recording
+// never writes the WeatherAPI key to disk.
+var secretParams = []string{"key", "api_key", "apikey", "token", "access_token"}
+
+// Cassette is one recorded interaction.
+type Cassette struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+	RecordedAt time.Time   `json:"recorded_at"`
+}
+
+// Recorder records or replays the interactions with hosts under dir.
+type Recorder struct {
+	mode  Mode
+	dir   string
+	hosts map[string]bool
+}
+
+// New returns a recorder for hosts. Requests to other hosts, such as
+// callbacks, always go to the network.
+func New(mode Mode, dir string, hosts []string) (*Recorder, error) {
+	if mode != Record && mode != Replay {
+		return nil, fmt.Errorf("unknown mode %q (available: %s, %s)", mode, Record, Replay)
+	}
+	if mode == Record {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	r := &Recorder{mode: mode, dir: dir, hosts: make(map[string]bool)}
+	for _, host := range hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			r.hosts[host] = true
+		}
+	}
+	return r, nil
+}
+
+// FromEnv returns a recorder configured by UPSTREAM_VCR_MODE,
+// UPSTREAM_VCR_DIR and UPSTREAM_VCR_HOSTS, or nil when the mode is unset.
+func FromEnv() (*Recorder, error) {
+	mode := os.Getenv("UPSTREAM_VCR_MODE")
+	if mode == "" {
+		return nil, nil
+	}
+	return New(Mode(mode), envconfig.String("UPSTREAM_VCR_DIR", "cassettes"),
+		strings.Split(envconfig.String("UPSTREAM_VCR_HOSTS", "viacep.com.br,brasilapi.com.br,api.weatherapi.com"), ","))
+}
+
+func (r *Recorder) Mode() Mode { return r.mode }
+
+// Wrap returns a transport that records the responses base returns, or
+// answers from cassettes without calling base.
+func (r *Recorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &transport{recorder: r, base: base}
+}
+
+type transport struct {
+	recorder *Recorder
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.recorder.hosts[req.URL.Host] {
+		return t.base.RoundTrip(req)
+	}
+	path, key := t.recorder.path(req)
+	if t.recorder.mode == Replay {
+		return t.replay(req, path, key)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	cassette := Cassette{
+		Method:     req.Method,
+		URL:        key,
+		Status:     resp.StatusCode,
+		Header:     http.Header{"Content-Type": resp.Header.Values("Content-Type")},
+		Body:       string(body),
+		RecordedAt: time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vcr: recording %s: %w", key, err)
+	}
+	return resp, nil
+}
+
+func (t *transport) replay(req *http.Request, path, key string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: no cassette for %s %s: %w", req.Method, key, err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("vcr: reading %s: %w", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cassette.Status, http.StatusText(cassette.Status)),
+		StatusCode:    cassette.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cassette.Header,
+		Body:          io.NopCloser(strings.NewReader(cassette.Body)),
+		ContentLength: int64(len(cassette.Body)),
+		Request:       req,
+	}, nil
+}
+
+// path names the cassette of req after its host and a hash of the method
+// and the URL without secrets, which is returned as key.
+func (r *Recorder) path(req *http.Request) (path, key string) {
+	u := *req.URL
+	query := u.Query()
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+	u.RawQuery = query.Encode()
+	u.User = nil
+	key = (&u).String()
+	sum := sha256.Sum256([]byte(req.Method + " " + key))
+	host := strings.ReplaceAll(u.Host, ":", "_")
+	return filepath.Join(r.dir, fmt.Sprintf("%s_%s.json", host, hex.EncodeToString(sum[:8]))), key
+}
@@ -0,0 +1,135 @@
+package vcr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// offline fails every request that reaches the network.
+func offline(t *testing.T) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("%s %s reached the network", req.Method, req.URL)
+		return nil, http.ErrHandlerTimeout
+	})
+}
+
+func get(t *testing.T, rt http.RoundTripper, rawURL string) (*http.Response, string, error) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: rt}).Get(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, string(body), err
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	var hits int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "not recorded")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":1006,"message":"No matching location found."}}`))
+	}))
+	defer upstream.Close()
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	dir := t.TempDir()
+
+	recorder, err := New(Record, dir, []string{host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, recordedBody, err := get(t, recorder.Wrap(http.DefaultTransport), upstream.URL+"/v1/current.json?q=Atlantis&key=s3cr3t")
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded %d cassettes, want 1", len(files))
+	}
+	if data, _ := os.ReadFile(files[0]); strings.Contains(string(data), "s3cr3t") || strings.Contains(string(data), "not recorded") {
+		t.Errorf("cassette keeps the API key or extra headers: %s", data)
+	}
+
+	replayer, err := New(Replay, dir, []string{host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another key names the same cassette.
+	replayed, replayedBody, err := get(t, replayer.Wrap(offline(t)), upstream.URL+"/v1/current.json?q=Atlantis&key=other")
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if replayed.StatusCode != recorded.StatusCode || replayedBody != recordedBody ||
+		replayed.Header.Get("Content-Type") != recorded.Header.Get("Content-Type") {
+		t.Errorf("replayed %d %q %q, want %d %q %q", replayed.StatusCode, replayed.Header.Get("Content-Type"), replayedBody,
+			recorded.StatusCode, recorded.Header.Get("Content-Type"), recordedBody)
+	}
+	if hits != 1 {
+		t.Errorf("upstream got %d requests, want 1", hits)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	replayer, err := New(Replay, dir, []string{"api.weatherapi.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://api.weatherapi.com/v1/current.json?q=Sao+Paulo", nil)
+	path, key := replayer.path(req)
+	if err := os.WriteFile(path, []byte(`{"method":"GET","url":"`+key+`","status":200,"header":{"Content-Type":["application/json"]},"body":"{\"current\":{\"temp_c\":25}}"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	passthrough := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+	})
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+		wantErr    bool
+	}{
+		{name: "recorded", url: "https://api.weatherapi.com/v1/current.json?q=Sao+Paulo", wantStatus: http.StatusOK, wantBody: `{"current":{"temp_c":25}}`},
+		{name: "recorded, query reordered", url: "https://api.weatherapi.com/v1/current.json?key=x&q=Sao+Paulo", wantStatus: http.StatusOK, wantBody: `{"current":{"temp_c":25}}`},
+		{name: "not recorded", url: "https://api.weatherapi.com/v1/current.json?q=Rio", wantErr: true},
+		{name: "other host", url: "https://hooks.example.com/callback", wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body, err := get(t, replayer.Wrap(passthrough), tt.url)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "no cassette") {
+					t.Fatalf("error = %v, want a missing cassette", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus || body != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNewRejectsUnknownMode(t *testing.T) {
+	if _, err := New("rewind", t.TempDir(), nil); err == nil {
+		t.Fatal("New accepted an unknown mode")
+	}
+}
@@ -0,0 +1,66 @@
+// Package version reports which build of a service is running, for the
+// tracing resource and the /version endpoint.
+package version
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+)
+
+// Version is set at build time with
+// -ldflags "-X github.com/brunocordeiro180/go-cep-telemetry/internal/version.Version=v1.2.3".
+var Version string
+
+type Info struct {
+	Service     string `json:"service"`
+	Version     string `json:"version"`
+	Environment string `json:"environment"`
+	Host        string `json:"host"`
+	GoVersion   string `json:"go_version"`
+	Commit      string `json:"commit,omitempty"`
+}
+
+// Get describes the running build of service. Without an ldflags version
+// it falls back to the module version and then to the VCS revision.
+func Get(service string) Info {
+	info := Info{
+		Service:     service,
+		Version:     Version,
+		Environment: envconfig.String("DEPLOYMENT_ENVIRONMENT", "development"),
+	}
+	info.Host, _ = os.Hostname()
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = build.GoVersion
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+		if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+	}
+	if info.Version == "" && len(info.Commit) >= 12 {
+		info.Version = info.Commit[:12]
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// Handler serves Get(service) as JSON.
+func Handler(service string) http.HandlerFunc {
+	info := Get(service)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			log.Printf("Error encoding JSON response: %v\n", err)
+		}
+	}
+}
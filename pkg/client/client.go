@@ -0,0 +1,96 @@
+// Package client is a Go SDK for Service A. Failed lookups return *Error,
+// which reports through Temporary and RetryAfter whether and when the call
+// is worth retrying, so callers never need to parse error messages.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Weather struct {
+	City      string   `json:"city"`
+	UF        string   `json:"uf,omitempty"`
+	IBGECode  string   `json:"ibge_code,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// Resolution is "approximate" when the address was inferred from the
+	// CEP range because the CEP providers were down.
+	Resolution string `json:"resolution,omitempty"`
+	// Source is where Service B got the address from, such as "viacep",
+	// "local-db" or "cache".
+	Source string  `json:"source,omitempty"`
+	TempC  float64 `json:"temp_C"`
+	TempF  float64 `json:"temp_F"`
+	TempK  float64 `json:"temp_K"`
+	// Condition is nil when the weather provider does not describe the
+	// weather.
+	Condition  *Condition `json:"condition,omitempty"`
+	ObservedAt time.Time  `json:"observed_at"`
+	TimeZone   string     `json:"timezone,omitempty"`
+	// LocalTime is the time in the city when the weather was looked up,
+	// zero when Service B knows no time zone for it.
+	LocalTime time.Time `json:"local_time"`
+	ZipkinURL string    `json:"zipkin_url,omitempty"`
+}
+
+// Condition describes the weather in words, such as "Partly cloudy", and as
+// an icon.
+type Condition struct {
+	Code    int    `json:"code"`
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New returns a client for the Service A at baseURL. A nil httpClient uses
+// http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+// Weather looks up the current weather for cep.
+func (c *Client) Weather(ctx context.Context, cep string) (*Weather, error) {
+	payload, err := json.Marshal(map[string]string{"cep": cep})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &Error{Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &Error{StatusCode: resp.StatusCode, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError(resp, body)
+	}
+
+	var weather Weather
+	if err := json.Unmarshal(body, &weather); err != nil {
+		return nil, fmt.Errorf("malformed response: %w", err)
+	}
+	return &weather, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error describes a failed call. StatusCode is zero when no response was
+// received, in which case Err holds the transport error.
+type Error struct {
+	StatusCode int
+	Message    string
+	Err        error
+
+	retryAfter time.Duration
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("request failed: %v", e.Err)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Temporary reports whether the same call may succeed if retried: transport
+// failures other than cancellation, timeouts, rate limiting and server side
+// errors. Client errors such as an invalid or unknown CEP are permanent.
+func (e *Error) Temporary() bool {
+	if e.StatusCode == 0 {
+		return !errors.Is(e.Err, context.Canceled)
+	}
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= http.StatusInternalServerError && e.StatusCode != http.StatusNotImplemented
+}
+
+// RetryAfter returns how long the server asked callers to wait before
+// retrying, taken from the Retry-After header, or zero when it gave no hint.
+func (e *Error) RetryAfter() time.Duration { return e.retryAfter }
+
+// IsTemporary reports whether err, or any error it wraps, is temporary.
+func IsTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// RetryAfter returns the wait hinted by err, if any error in its chain
+// carries one.
+func RetryAfter(err error) (time.Duration, bool) {
+	var r interface{ RetryAfter() time.Duration }
+	if errors.As(err, &r) && r.RetryAfter() > 0 {
+		return r.RetryAfter(), true
+	}
+	return 0, false
+}
+
+// parseRetryAfter accepts both forms of the header: delay seconds and an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -1,25 +0,0 @@
-module github.com/brunocordeiro180/go-cep-telemetry/service-a
-
-go 1.23.0
-
-toolchain go1.23.9
-
-require (
-	github.com/joho/godotenv v1.5.1
-	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.61.0
-	go.opentelemetry.io/otel v1.36.0
-	go.opentelemetry.io/otel/exporters/zipkin v1.36.0
-	go.opentelemetry.io/otel/sdk v1.36.0
-)
-
-require (
-	github.com/felixge/httpsnoop v1.0.4 // indirect
-	github.com/go-logr/logr v1.4.2 // indirect
-	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/openzipkin/zipkin-go v0.4.3 // indirect
-	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
-	go.opentelemetry.io/otel/metric v1.36.0 // indirect
-	go.opentelemetry.io/otel/trace v1.36.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
-)
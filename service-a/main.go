@@ -2,167 +2,84 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
-	"regexp"
-
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/zipkin"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/admin"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/envconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/privacy"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/runner"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/servicea"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/shutdownreport"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/soak"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tlsconfig"
+	"github.com/brunocordeiro180/go-cep-telemetry/internal/tracing"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-type CEPRequest struct {
-	CEP string `json:"cep"`
-}
-
-var serviceBURL = "http://localhost:8081"
-
-var zipkinURL = "http://zipkin:9411/api/v2/spans"
-
-func initTracer(serviceName, zipkinEndpoint string) (func(context.Context) error, error) {
-	exporter, err := zipkin.New(
-		zipkinEndpoint,
-	)
+func main() {
+	// Log lines quote URLs and errors, so privacy mode scrubs them as a whole.
+	cepHasher, err := privacy.FromEnv()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create zipkin exporter: %w", err)
+		log.Fatalf("Invalid privacy settings: %v", err)
 	}
-
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
-		),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+	if cepHasher != nil {
+		log.SetOutput(cepHasher.Writer(os.Stderr))
 	}
+	serviceBURL := envconfig.String("SERVICE_B_URL", "http://localhost:8081")
+	zipkinURL := envconfig.String("OTEL_EXPORTER_ZIPKIN_ENDPOINT", "http://zipkin:9411/api/v2/spans")
 
-	bsp := sdktrace.NewBatchSpanProcessor(exporter)
-
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
-
-	otel.SetTracerProvider(tp)
-
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
-
-	log.Printf("Tracer initialized for service 	'%s'	, exporting to %s\n", serviceName, zipkinEndpoint)
-
-	return tp.Shutdown, nil
-}
-
-func isValidCEPInput(cep string) bool {
-	match, _ := regexp.MatchString(`^\d{8}$`, cep)
-	return match
-}
-
-func handleCEPRequest(w http.ResponseWriter, r *http.Request) {
-	tracer := otel.Tracer("service-a/handler")
-	ctx := r.Context()
-
-	if r.Method != http.MethodPost {
-		w.Header().Set("Allow", http.MethodPost)
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req CEPRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad Request: Malformed JSON", http.StatusBadRequest)
-		return
-	}
-
-	if !isValidCEPInput(req.CEP) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusUnprocessableEntity) // 422
-		fmt.Fprintln(w, "invalid zipcode")
-		return
-	}
-
-	ctx, span := tracer.Start(ctx, "call-service-b")
-	defer span.End()
-
-	targetURL := fmt.Sprintf("%s/weather/%s", serviceBURL, req.CEP)
-
-	serviceBReq, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	stats := shutdownreport.NewCollector()
+	shutdown, err := tracing.Init("service-a", zipkinURL, sdktrace.AlwaysSample(), stats)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to create request to Service B")
-		http.Error(w, fmt.Sprintf("Internal Server Error: Failed to create request to Service B: %v", err), http.StatusInternalServerError)
-		return
+		log.Fatalf("Failed to initialize tracer: %v", err)
 	}
 
-	client := http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
-	serviceBResp, err := client.Do(serviceBReq)
+	fmt.Println("Starting Service A...")
+	svc, err := servicea.New(servicea.Options{ServiceBURL: serviceBURL, ServiceBSocket: os.Getenv("SERVICE_B_SOCKET"), Stats: stats})
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to reach Service B")
-		http.Error(w, fmt.Sprintf("Internal Server Error: Failed to reach Service B: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer serviceBResp.Body.Close()
-
-	span.SetAttributes(semconv.HTTPResponseStatusCode(serviceBResp.StatusCode))
-
-	for key, values := range serviceBResp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
-		}
-	}
-
-	w.WriteHeader(serviceBResp.StatusCode)
-
-	if _, err := io.Copy(w, serviceBResp.Body); err != nil {
-		log.Printf("Error copying response body from Service B: %v\n", err)
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to copy response body")
+		log.Fatalf("Failed to start Service A: %v", err)
 	}
-}
+	defer svc.Close()
 
-func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	soak.FromEnv("service-a").Start(ctx)
 
-	if url := os.Getenv("SERVICE_B_URL"); url != "" {
-		serviceBURL = url
+	port := envconfig.String("PORT", "8080")
+	tlsConfig, err := tlsconfig.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
 	}
-	if url := os.Getenv("OTEL_EXPORTER_ZIPKIN_ENDPOINT"); url != "" {
-		zipkinURL = url
+	limits := runner.LimitsFromEnv()
+	listeners := []runner.Listener{{Name: "Service A", Addr: envconfig.String("LISTEN_ADDR", ":"+port), Handler: svc.Handler(), TLS: tlsConfig, Limits: limits, OnShutdown: svc.CloseStreams}}
+	if adminPort := os.Getenv("ADMIN_PORT"); adminPort != "" {
+		listeners = append(listeners, runner.Listener{Name: "Service A admin endpoints", Addr: ":" + adminPort, Handler: svc.AdminHandler(), TLS: tlsConfig, Limits: limits})
 	}
-
-	shutdown, err := initTracer("service-a", zipkinURL)
-	if err != nil {
-		log.Fatalf("Failed to initialize tracer: %v", err)
+	if debugPort := os.Getenv("DEBUG_PORT"); debugPort != "" {
+		listeners = append(listeners, runner.Listener{Name: "Service A pprof/expvar endpoints", Addr: ":" + debugPort, Handler: admin.DebugMux()})
 	}
-	defer func() {
-		if err := shutdown(context.Background()); err != nil {
-			log.Printf("Failed to shutdown tracer: %v", err)
-		}
-	}()
 
-	fmt.Println("Starting Service A...")
+	fmt.Printf("Service A forwarding to Service B at %s, exporting traces to %s\n", serviceBURL, zipkinURL)
+	shutdownTimeout := envconfig.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	runner.Run(ctx, shutdownTimeout, listeners...)
+	fmt.Println("Shutting down Service A...")
 
-	httpHandler := otelhttp.NewHandler(http.HandlerFunc(handleCEPRequest), "ServiceA-HTTP-Request")
-	http.Handle("/", httpHandler)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shutdown tracer: %v", err)
 	}
 
-	fmt.Printf("Service A listening on port %s, forwarding to Service B at %s, exporting traces to %s\n", port, serviceBURL, zipkinURL)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Error starting Service A: %s\n", err)
+	report := stats.Report("service-a")
+	report.Log()
+	if webhook := os.Getenv("SHUTDOWN_REPORT_WEBHOOK"); webhook != "" {
+		if err := report.Send(shutdownCtx, svc.Client(), webhook); err != nil {
+			log.Printf("Failed to send shutdown report: %v", err)
+		}
 	}
 }